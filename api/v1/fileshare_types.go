@@ -0,0 +1,196 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FileShareFinalizer is the finalizer applied to FileShare resources by its managing controller.
+const FileShareFinalizer = "fileshare.gcore-sfs-controller.io"
+
+// FileShareUIDMetadataKey is the Gcore Cloud metadata key holding the UID of the FileShare resource
+// which created the file share, it finds the file share when the create task ID is lost.
+const FileShareUIDMetadataKey = "gcore-sfs-controller.io/fileshare-uid"
+
+// PersistentVolumeMetadataKey is the Gcore Cloud metadata key holding the name of the PersistentVolume of a
+// dynamically provisioned file share, NfsProvisioners never deploy a provisioner for these file shares.
+const PersistentVolumeMetadataKey = "gcore-sfs-controller.io/persistent-volume"
+
+// FileShare condition reasons.
+const (
+	FileShareCreatingReason   = "Creating"
+	FileShareResizingReason   = "Resizing"
+	FileShareDeletingReason   = "Deleting"
+	FileShareAvailableReason  = "Available"
+	FileShareNotFoundReason   = "FileShareNotFound"
+	TaskFailedReason          = "TaskFailed"
+	ProvisionerNotFoundReason = "ProvisionerNotFound"
+	CloudAPIFailedReason      = "CloudAPIFailed"
+)
+
+// FileSharePhase is the lifecycle phase of a FileShare.
+type FileSharePhase string
+
+const (
+	FileSharePhasePending   FileSharePhase = "Pending"
+	FileSharePhaseCreating  FileSharePhase = "Creating"
+	FileSharePhaseAvailable FileSharePhase = "Available"
+	FileSharePhaseResizing  FileSharePhase = "Resizing"
+	FileSharePhaseDeleting  FileSharePhase = "Deleting"
+	FileSharePhaseFailed    FileSharePhase = "Failed"
+)
+
+// FileShareReclaimPolicy is what happens to the Gcore Cloud file share when the FileShare is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain
+type FileShareReclaimPolicy string
+
+const (
+	// FileShareReclaimDelete deletes the file share in Gcore Cloud with the FileShare.
+	FileShareReclaimDelete FileShareReclaimPolicy = "Delete"
+	// FileShareReclaimRetain keeps the file share in Gcore Cloud, it can be adopted by its ID.
+	FileShareReclaimRetain FileShareReclaimPolicy = "Retain"
+)
+
+// FileShareNetwork is the network the file share is attached to.
+type FileShareNetwork struct {
+	// NetworkID is the ID of the Gcore Cloud network.
+	NetworkID string `json:"networkID"`
+	// SubnetID is the ID of the subnet of the network, the first subnet is used when it is not set.
+	// +optional
+	SubnetID string `json:"subnetID,omitempty"`
+}
+
+// FileShareSpec defines the desired state of FileShare
+type FileShareSpec struct {
+	// ProvisionerRef references the NfsProvisioner in the FileShare namespace. Its region, project and API
+	// token are used to manage the file share, and it deploys the provisioner and storage class of the file share.
+	ProvisionerRef corev1.LocalObjectReference `json:"provisionerRef"`
+
+	// Name of the file share in Gcore Cloud. Defaults to the FileShare name.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// ID of an existing Gcore Cloud file share managed by the FileShare instead of creating a new one.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Size of the file share in GiB. It can be increased to extend the file share but never decreased.
+	// +kubebuilder:validation:Minimum=2
+	Size int `json:"size"`
+
+	// VolumeType of the file share, e.g. default_share_type. Defaults to the Gcore Cloud default.
+	// +optional
+	VolumeType string `json:"volumeType,omitempty"`
+
+	// Protocol of the file share, only NFS is supported.
+	// +kubebuilder:validation:Enum=NFS
+	// +kubebuilder:default=NFS
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// Network the file share is attached to.
+	Network FileShareNetwork `json:"network"`
+
+	// Metadata of the file share in Gcore Cloud, it can be matched by spec.fileShareSelector.matchMetadata
+	// of the NfsProvisioner.
+	// +optional
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// ReclaimPolicy is what happens to the Gcore Cloud file share when the FileShare is deleted.
+	// +kubebuilder:default=Delete
+	// +optional
+	ReclaimPolicy FileShareReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// FileShareResourceStatus defines the observed state of FileShare.
+type FileShareResourceStatus struct {
+	// ID of the file share in Gcore Cloud.
+	// +optional
+	ID string `json:"id,omitempty"`
+	// Phase of the file share lifecycle.
+	// +optional
+	Phase FileSharePhase `json:"phase,omitempty"`
+	// Size of the file share in GiB reported by Gcore Cloud.
+	// +optional
+	Size int `json:"size,omitempty"`
+	// CloudStatus is the file share status in Gcore Cloud.
+	// +optional
+	CloudStatus string `json:"cloudStatus,omitempty"`
+	// ConnectionPoint is the NFS export of the file share, e.g. "10.33.20.241:/shares/share-e1dca5e4".
+	// +optional
+	ConnectionPoint string `json:"connectionPoint,omitempty"`
+	// TaskID is the Gcore Cloud task creating, extending or deleting the file share.
+	// +optional
+	TaskID string `json:"taskID,omitempty"`
+	// StorageClassName is the storage class deployed for the file share by the NfsProvisioner.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// AccessRules are the access rules of a file share not selected by the NfsProvisioner, such as the file share
+	// of a claim, in FromNodes access rules mode. The NfsProvisioner reports the rules of the selected file shares.
+	// +optional
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+	// LastAccessRulesChange is the last change of the access rules made to match the nodes.
+	// +optional
+	LastAccessRulesChange *AccessRulesChange `json:"lastAccessRulesChange,omitempty"`
+	// ObservedGeneration is the last reconciled generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions of the FileShare.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".status.size"
+//+kubebuilder:printcolumn:name="Storage Class",type="string",JSONPath=".status.storageClassName"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// FileShare is the Schema for the fileshares API
+type FileShare struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FileShareSpec           `json:"spec,omitempty"`
+	Status FileShareResourceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// FileShareList contains a list of FileShare
+type FileShareList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FileShare `json:"items"`
+}
+
+// CloudName returns the name of the file share in Gcore Cloud.
+func (r *FileShare) CloudName() string {
+	if r.Spec.Name != "" {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+func init() {
+	SchemeBuilder.Register(&FileShare{}, &FileShareList{})
+}
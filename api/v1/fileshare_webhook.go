@@ -0,0 +1,88 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (r *FileShare) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-crd-gcore-sfs-controller-io-v1-fileshare,mutating=false,failurePolicy=fail,sideEffects=None,groups=crd.gcore-sfs-controller.io,resources=fileshares,verbs=create;update,versions=v1,name=vfileshare.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &FileShare{}
+
+// ValidateFileShareUpdate rejects shrinking the file share and changing the fields which are only used
+// to create it, Gcore Cloud can not change them on an existing file share.
+func ValidateFileShareUpdate(r *FileShare, old *FileShare) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if r.Spec.Size < old.Spec.Size {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("size"), r.Spec.Size,
+			fmt.Sprintf("must not be less than %d, file shares can not be shrunk", old.Spec.Size)))
+	}
+	immutableFields := []struct {
+		name     string
+		value    interface{}
+		oldValue interface{}
+	}{
+		{"provisionerRef", r.Spec.ProvisionerRef, old.Spec.ProvisionerRef},
+		{"name", r.Spec.Name, old.Spec.Name},
+		{"id", r.Spec.ID, old.Spec.ID},
+		{"volumeType", r.Spec.VolumeType, old.Spec.VolumeType},
+		{"protocol", r.Spec.Protocol, old.Spec.Protocol},
+		{"network", r.Spec.Network, old.Spec.Network},
+	}
+	for _, immutableField := range immutableFields {
+		if immutableField.value != immutableField.oldValue {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child(immutableField.name), "field is immutable"))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: "crd.gcore-sfs-controller.io", Kind: "FileShare"},
+		r.Name, allErrs)
+}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *FileShare) ValidateCreate() (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *FileShare) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	return nil, ValidateFileShareUpdate(r, old.(*FileShare))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *FileShare) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
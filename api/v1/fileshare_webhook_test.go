@@ -0,0 +1,49 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("FileShare webhooks", func() {
+	It("Check FileShare webhook rejects shrinking and immutable fields", func() {
+		fileShare := FileShare{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "FileShare",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "fileshare1",
+				Namespace: "default",
+			},
+			Spec: FileShareSpec{
+				ProvisionerRef: corev1.LocalObjectReference{Name: "provisioner1"},
+				Size:           10,
+				Network:        FileShareNetwork{NetworkID: "5b2b6c1e-3d4f-4a5b-8c6d-7e8f9a0b1c2d"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &fileShare)).To(Succeed())
+		Expect(fileShare.Spec.Protocol).To(Equal("NFS"))
+		Expect(fileShare.Spec.ReclaimPolicy).To(Equal(FileShareReclaimDelete))
+
+		fileShare.Spec.Size = 20
+		Expect(k8sClient.Update(ctx, &fileShare)).To(Succeed())
+
+		shrunk := fileShare.DeepCopy()
+		shrunk.Spec.Size = 15
+		err := k8sClient.Update(ctx, shrunk)
+		Expect(err).To(MatchError(ContainSubstring("file shares can not be shrunk")))
+
+		moved := fileShare.DeepCopy()
+		moved.Spec.Network.SubnetID = "9c8b7a6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d"
+		moved.Spec.VolumeType = "ssd_share_type"
+		err = k8sClient.Update(ctx, moved)
+		Expect(err).To(MatchError(ContainSubstring("spec.network: Forbidden: field is immutable")))
+		Expect(err).To(MatchError(ContainSubstring("spec.volumeType: Forbidden: field is immutable")))
+
+		Expect(k8sClient.Delete(ctx, &fileShare)).To(Succeed())
+	})
+})
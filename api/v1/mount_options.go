@@ -0,0 +1,213 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// DefaultMountOptions are the NFS mount options used when neither spec.mountOptions nor a file share
+// override sets them. Soft mounts allow unmounting the volumes of a deleted file share.
+var DefaultMountOptions = []string{"soft"}
+
+// mountOptionFlags are the NFS mount options without a value.
+var mountOptionFlags = map[string]bool{
+	"soft": true, "hard": true, "softreval": true, "nosoftreval": true, "intr": true, "nointr": true,
+	"resvport": true, "noresvport": true, "ac": true, "noac": true, "cto": true, "nocto": true,
+	"lock": true, "nolock": true, "sharecache": true, "nosharecache": true, "acl": true, "noacl": true,
+	"rdirplus": true, "nordirplus": true, "fsc": true, "nofsc": true, "ro": true, "rw": true,
+	"sync": true, "async": true, "atime": true, "noatime": true, "diratime": true, "nodiratime": true,
+	"relatime": true, "norelatime": true, "strictatime": true, "bg": true, "fg": true, "tcp": true, "udp": true,
+}
+
+// conflictingMountOptionFlags are the pairs of NFS mount options which can not be used together.
+var conflictingMountOptionFlags = [][2]string{
+	{"soft", "hard"}, {"intr", "nointr"}, {"resvport", "noresvport"}, {"ac", "noac"}, {"cto", "nocto"},
+	{"lock", "nolock"}, {"sharecache", "nosharecache"}, {"acl", "noacl"}, {"rdirplus", "nordirplus"},
+	{"fsc", "nofsc"}, {"ro", "rw"}, {"sync", "async"}, {"bg", "fg"}, {"tcp", "udp"},
+}
+
+// mountOptionValues validate the values of the NFS mount options taking a value.
+var mountOptionValues = map[string]func(string) error{
+	"nfsvers":      oneOfMountOptionValues("3", "4", "4.0", "4.1", "4.2"),
+	"vers":         oneOfMountOptionValues("3", "4", "4.0", "4.1", "4.2"),
+	"minorversion": mountOptionRange(0, 2),
+	"proto":        oneOfMountOptionValues("tcp", "tcp6", "udp", "udp6", "rdma", "rdma6"),
+	"mountproto":   oneOfMountOptionValues("tcp", "tcp6", "udp", "udp6"),
+	"sec":          oneOfMountOptionValues("none", "sys", "krb5", "krb5i", "krb5p"),
+	"local_lock":   oneOfMountOptionValues("none", "all", "flock", "posix"),
+	"lookupcache":  oneOfMountOptionValues("all", "none", "pos", "positive"),
+	"timeo":        mountOptionRange(1, 600000),
+	"retrans":      mountOptionRange(0, 1000),
+	"rsize":        mountOptionRange(1024, 1048576),
+	"wsize":        mountOptionRange(1024, 1048576),
+	"nconnect":     mountOptionRange(1, 16),
+	"actimeo":      mountOptionRange(0, 3600),
+	"acregmin":     mountOptionRange(0, 3600),
+	"acregmax":     mountOptionRange(0, 3600),
+	"acdirmin":     mountOptionRange(0, 3600),
+	"acdirmax":     mountOptionRange(0, 3600),
+	"retry":        mountOptionRange(0, 100000),
+	"port":         mountOptionRange(0, 65535),
+	"mountport":    mountOptionRange(0, 65535),
+	"namlen":       mountOptionRange(1, 65535),
+	"clientaddr":   nonEmptyMountOptionValue,
+	"mounthost":    nonEmptyMountOptionValue,
+	"mountaddr":    nonEmptyMountOptionValue,
+}
+
+// databaseNamePattern matches file share and storage class names of typical database workloads.
+var databaseNamePattern = regexp.MustCompile(`(?i)(^|[^a-z])(db|database|postgres|postgresql|pg|mysql|mariadb|mongo|mongodb|redis|etcd|elasticsearch|cassandra|clickhouse)([^a-z]|$)`)
+
+func oneOfMountOptionValues(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, allowedValue := range allowed {
+			if value == allowedValue {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+func mountOptionRange(min int, max int) func(string) error {
+	return func(value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < min || number > max {
+			return fmt.Errorf("must be an integer between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+func nonEmptyMountOptionValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+// ParseMountOptions splits the NFS mount options into their names and values, a flag has an empty value.
+func ParseMountOptions(options []string) map[string]string {
+	parsed := map[string]string{}
+	for _, option := range options {
+		name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		parsed[name] = value
+	}
+	return parsed
+}
+
+// GetEffectiveMountOptions returns the mount options of a file share matched by the override,
+// the override options replace the spec options which replace the default ones.
+func GetEffectiveMountOptions(spec *NfsProvisionerSpec, override *FileShareOverride) []string {
+	switch {
+	case override != nil && len(override.MountOptions) > 0:
+		return override.MountOptions
+	case len(spec.MountOptions) > 0:
+		return spec.MountOptions
+	default:
+		return DefaultMountOptions
+	}
+}
+
+// validateMountOptions parses the NFS mount options and rejects malformed values, duplicated
+// options with different values and options which can not be used together.
+func validateMountOptions(fldPath *field.Path, options []string) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]string{}
+	for i, option := range options {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(option), "=")
+		switch {
+		case name == "":
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), option, "must not be empty"))
+			continue
+		case strings.ContainsAny(option, ", \t"):
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), option, "must be a single option, list the options separately"))
+			continue
+		case mountOptionFlags[name] && hasValue:
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), option, fmt.Sprintf("%s does not take a value", name)))
+		case mountOptionValues[name] != nil && !hasValue:
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), option, fmt.Sprintf("%s requires a value", name)))
+		case mountOptionValues[name] != nil:
+			if err := mountOptionValues[name](value); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), option, fmt.Sprintf("%s %v", name, err)))
+			}
+		}
+		if previous, ok := seen[name]; ok && previous != value {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(i), option))
+		}
+		seen[name] = value
+	}
+	for _, pair := range conflictingMountOptionFlags {
+		_, first := seen[pair[0]]
+		_, second := seen[pair[1]]
+		if first && second {
+			allErrs = append(allErrs, field.Invalid(fldPath, strings.Join(options, ","),
+				fmt.Sprintf("%s and %s can not be used together", pair[0], pair[1])))
+		}
+	}
+	version := seen["nfsvers"]
+	if version == "" {
+		version = seen["vers"]
+	}
+	if nfsvers, vers := seen["nfsvers"], seen["vers"]; nfsvers != "" && vers != "" && nfsvers != vers {
+		allErrs = append(allErrs, field.Invalid(fldPath, strings.Join(options, ","), "nfsvers and vers must be equal"))
+	}
+	_, udp := seen["udp"]
+	if strings.HasPrefix(version, "4") && (udp || strings.HasPrefix(seen["proto"], "udp")) {
+		allErrs = append(allErrs, field.Invalid(fldPath, strings.Join(options, ","), "NFSv4 does not support UDP"))
+	}
+	return allErrs
+}
+
+// getMountOptionWarnings returns the warnings about risky NFS mount options. name is the
+// file share or storage class name the options apply to, it is empty for the spec options, which apply to
+// every file share.
+func getMountOptionWarnings(fldPath *field.Path, options []string, name string) []string {
+	var warnings []string
+	parsed := ParseMountOptions(options)
+	for _, option := range options {
+		key, _, _ := strings.Cut(strings.TrimSpace(option), "=")
+		if !mountOptionFlags[key] && mountOptionValues[key] == nil && key != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown NFS mount option %q is passed to the mount as is", fldPath, key))
+		}
+	}
+	if _, soft := parsed["soft"]; soft {
+		switch {
+		case name == "":
+			warnings = append(warnings, fmt.Sprintf("%s: soft applies to every file share, including database file "+
+				"shares, and reports I/O errors to the databases on timeouts which may corrupt their data, "+
+				"use hard for them with a file share override", fldPath))
+		case databaseNamePattern.MatchString(name):
+			warnings = append(warnings, fmt.Sprintf("%s: soft mount of database file share %q reports I/O errors to the "+
+				"database on timeouts and may corrupt its data, use hard", fldPath, name))
+		}
+	}
+	if _, nolock := parsed["nolock"]; nolock {
+		warnings = append(warnings, fmt.Sprintf("%s: nolock keeps file locks local to the node, "+
+			"pods on different nodes can write the same files concurrently", fldPath))
+	}
+	if _, noac := parsed["noac"]; noac {
+		warnings = append(warnings, fmt.Sprintf("%s: noac disables attribute caching and slows down file access", fldPath))
+	}
+	return warnings
+}
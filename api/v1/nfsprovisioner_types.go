@@ -17,17 +17,120 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // NfsProvisionerFinalizer is the finalizer applied to NfsProvisioner resources
 // by its managing controller.
 const NfsProvisionerFinalizer = "nfsprovisioner.gcore-sfs-controller.io"
 
+// NfsProvisioner condition types.
+const (
+	// ReadyCondition denotes that the file shares are listed, their releases are synced
+	// and all provisioners are running.
+	ReadyCondition = "Ready"
+	// CloudAPIReachableCondition denotes that the file shares were listed from the Gcore Cloud API.
+	CloudAPIReachableCondition = "CloudAPIReachable"
+	// HelmReleasesSyncedCondition denotes that the provisioner releases of all file shares are installed
+	// and the releases of removed file shares are uninstalled. In Native deployment mode it reports the
+	// provisioner objects applied by the controller.
+	HelmReleasesSyncedCondition = "HelmReleasesSynced"
+	// DegradedCondition denotes that some file shares failed to be provisioned.
+	DegradedCondition = "Degraded"
+	// ChartSourceAvailableCondition denotes that the provisioner chart was resolved from the configured source.
+	ChartSourceAvailableCondition = "ChartSourceAvailable"
+	// ConflictCondition denotes that some file shares are not provisioned because their storage class
+	// or release is already claimed by another NfsProvisioner or another file share, or that the storage
+	// class of spec.dynamicProvisioning is not managed by the NfsProvisioner.
+	ConflictCondition = "Conflict"
+	// DefaultStorageClassCondition denotes that the storage class of spec.defaultStorageClass is the only
+	// default storage class of the cluster. It is not reported when spec.defaultStorageClass is not set.
+	DefaultStorageClassCondition = "DefaultStorageClass"
+	// DeletionBlockedCondition denotes that the provisioners of removed file shares, or of all file shares
+	// while the NfsProvisioner is deleted, are kept because PersistentVolumes or claims still use their
+	// storage classes. It is only reported with the BlockWhileInUse deletion policy, or while a deleted
+	// NfsProvisioner waits for the FileShares referencing it to be deleted.
+	DeletionBlockedCondition = "DeletionBlocked"
+	// AccessRulesSyncedCondition denotes that the access rules of the file shares match the nodes.
+	// It is only reported in FromNodes access rules mode.
+	AccessRulesSyncedCondition = "AccessRulesSynced"
+	// UsageThresholdExceededCondition denotes that the usage of some file shares passed the threshold of
+	// spec.usageMonitoring. It is only reported when spec.usageMonitoring is set.
+	UsageThresholdExceededCondition = "UsageThresholdExceeded"
+)
+
+// NfsProvisioner condition reasons.
+const (
+	ReconciledReason               = "Reconciled"
+	FileSharesListedReason         = "FileSharesListed"
+	ListFileSharesFailedReason     = "ListFileSharesFailed"
+	ReleasesSyncedReason           = "ReleasesSynced"
+	ReleaseInstallFailedReason     = "ReleaseInstallFailed"
+	ReleaseUninstallFailedReason   = "ReleaseUninstallFailed"
+	ProvisionersNotReadyReason     = "ProvisionersNotReady"
+	FileSharesFailedReason         = "FileSharesFailed"
+	ChartUnavailableReason         = "ChartUnavailable"
+	ChartResolvedReason            = "ChartResolved"
+	BundledChartUsedReason         = "BundledChartUsed"
+	FileSharesClaimedReason        = "FileSharesClaimed"
+	StorageClassClaimedReason      = "StorageClassClaimed"
+	DefaultFileShareNotFoundReason = "DefaultFileShareNotFound"
+	MultipleDefaultClassesReason   = "MultipleDefaultStorageClasses"
+	VolumesInUseReason             = "VolumesInUse"
+	FileSharesReferencedReason     = "FileSharesReferenced"
+	AccessRulesSyncedReason        = "AccessRulesSynced"
+	AccessRulesSyncFailedReason    = "AccessRulesSyncFailed"
+	UsageAboveThresholdReason      = "UsageAboveThreshold"
+	UsageBelowThresholdReason      = "UsageBelowThreshold"
+)
+
+// DeploymentMode is the way the provisioners of the file shares are deployed.
+// +kubebuilder:validation:Enum=Helm;Native
+type DeploymentMode string
+
+const (
+	// DeploymentModeHelm deploys a Helm release of the provisioner chart for every file share.
+	DeploymentModeHelm DeploymentMode = "Helm"
+	// DeploymentModeNative applies the provisioner objects built by the controller for every file share.
+	DeploymentModeNative DeploymentMode = "Native"
+)
+
+// DeletionPolicy is what happens to the provisioner of a file share when the file share is removed
+// from the project or the selection, or the NfsProvisioner is deleted.
+// +kubebuilder:validation:Enum=Delete;Orphan;BlockWhileInUse
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete uninstalls the provisioner and deletes the storage classes of the file share.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the provisioner and the storage classes of the file share in place.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyBlockWhileInUse deletes the provisioner and the storage classes of the file share
+	// once no PersistentVolume or PersistentVolumeClaim uses the storage classes.
+	DeletionPolicyBlockWhileInUse DeletionPolicy = "BlockWhileInUse"
+)
+
 // NfsProvisionerSpec defines the desired state of NfsProvisioner
 type NfsProvisionerSpec struct {
 	// APIToken is the API token used to authenticate with Gcore Cloud.
-	APIToken string `json:"apiToken"`
+	// Deprecated: use APITokenSecretRef instead, the token is stored in plain text in the resource.
+	// +optional
+	APIToken string `json:"apiToken,omitempty"`
+	// APITokenSecretRef references a Secret key holding the API token used to authenticate with Gcore Cloud.
+	// Exactly one of APIToken and APITokenSecretRef must be set.
+	// +optional
+	APITokenSecretRef *SecretKeyReference `json:"apiTokenSecretRef,omitempty"`
 	// APIURL is the URL of the Gcore Cloud API.
 	// +optional
 	APIURL string `json:"apiURL,omitempty"`
@@ -38,15 +141,39 @@ type NfsProvisionerSpec struct {
 	// File share project ID
 	ProjectID int `json:"project"`
 
-	// Provisioner helm repository
+	// DeploymentMode selects how the provisioners are deployed. Helm installs a release of the provisioner
+	// chart per file share. Native applies the provisioner Deployment, ServiceAccount, RBAC and StorageClass
+	// built by the controller with server-side apply, without a chart repository and Helm release Secrets.
+	// The Helm settings (helmRepository, chartName, chartVersion, values, valuesFrom) are not used by Native.
+	// +kubebuilder:default=Helm
+	// +optional
+	DeploymentMode DeploymentMode `json:"deploymentMode,omitempty"`
+
+	// Provisioner helm repository. Both HTTP chart repositories and OCI registries (oci://) are supported.
 	// +optional
 	HelmRepository string `json:"helmRepository,omitempty"`
 
+	// HelmRepositorySecretRef references a Secret in the NfsProvisioner namespace holding the credentials
+	// (username and password keys) and the CA certificate (ca.crt key) of the chart repository or chart URL.
+	// +optional
+	HelmRepositorySecretRef *corev1.LocalObjectReference `json:"helmRepositorySecretRef,omitempty"`
+
+	// ChartURL is the URL of the provisioner chart archive, for example served in-cluster.
+	// It takes precedence over helmRepository.
+	// +optional
+	ChartURL string `json:"chartURL,omitempty"`
+
+	// ChartConfigMapRef references a ConfigMap key in the NfsProvisioner namespace holding the provisioner
+	// chart archive in its binary data. It takes precedence over chartURL and helmRepository.
+	// +optional
+	ChartConfigMapRef *ChartConfigMapReference `json:"chartConfigMapRef,omitempty"`
+
 	// Provisioner Helm chart name
 	// +optional
 	ChartName string `json:"chartName,omitempty"`
 
-	// Provisioner Helm chart version
+	// Provisioner Helm chart version. Semver ranges such as "~4.0" are supported,
+	// the latest chart version satisfying the range is installed.
 	// +optional
 	ChartVersion string `json:"chartVersion,omitempty"`
 
@@ -54,19 +181,544 @@ type NfsProvisionerSpec struct {
 	// +optional
 	ImageVersion string `json:"imageVersion,omitempty"`
 
+	// Values are Helm values for the provisioner chart. They are merged over the
+	// controller defaults and ValuesFrom. Values managed by the controller, such as
+	// nfs.server, nfs.path, storageClass.name and the provisioner labels, can not be overridden.
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+
+	// ValuesFrom references ConfigMaps and Secrets in the NfsProvisioner namespace holding Helm values
+	// for the provisioner chart. They are merged in the listed order over the controller defaults,
+	// a later reference takes precedence over an earlier one.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+
+	// FileShareSelector selects the file shares of the project the provisioner deploys storage classes for.
+	// All NFS file shares of the project are selected when it is not set.
+	// +optional
+	FileShareSelector *FileShareSelector `json:"fileShareSelector,omitempty"`
+
+	// StorageClassNameTemplate is a Go template of the storage class names, e.g. "sfs-{{ .Name }}".
+	// It is rendered with the file share Name, ID, Region, Project and Size (in GiB) and the result is
+	// sanitized to a DNS-1123 name. Defaults to "nfs-{{ .ID }}", fileShareOverrides[].storageClassName
+	// takes precedence. A renamed storage class is kept until no PersistentVolume or claim uses it.
+	// +optional
+	StorageClassNameTemplate string `json:"storageClassNameTemplate,omitempty"`
+
+	// DefaultStorageClass is the ID or name of the file share whose storage class is annotated as the default
+	// storage class of the cluster. The storage classes of the other file shares are never default. When several
+	// selected file shares have the name, the first one listed by the Gcore Cloud API is used.
+	// +optional
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty"`
+
+	// MountOptions are the NFS mount options of the file share storage classes and the provisioner
+	// mounts, e.g. "nfsvers=4.1", "hard", "timeo=600", "retrans=2", "noresvport", "rsize=1048576".
+	// Defaults to "soft", fileShareOverrides[].mountOptions take precedence. A change is rolled out
+	// by recreating the storage classes and restarting the provisioners, bound volumes keep their options.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+
+	// StorageClassParameters are the parameters of the file share storage classes,
+	// they can be overridden per file share by fileShareOverrides.
+	StorageClassParameters `json:",inline"`
+
+	// FileShareOverrides change the provisioner configuration of the matching file shares.
+	// All matching overrides are applied in the listed order, a later override takes precedence.
+	// +optional
+	FileShareOverrides []FileShareOverride `json:"fileShareOverrides,omitempty"`
+
+	// AutoExpand extends the selected file shares in Gcore Cloud when the storage requested by the claims
+	// of their storage classes passes a threshold of their size. File shares are never extended when it is not set.
+	// +optional
+	AutoExpand *AutoExpand `json:"autoExpand,omitempty"`
+
+	// AccessRules manage the access rules of the file shares in Gcore Cloud. They are left as they are
+	// when it is not set.
+	// +optional
+	AccessRules *AccessRules `json:"accessRules,omitempty"`
+
+	// UsageMonitoring measures the usage of the selected file shares with a probe Job mounting them.
+	// The usage is not measured when it is not set.
+	// +optional
+	UsageMonitoring *UsageMonitoring `json:"usageMonitoring,omitempty"`
+
+	// DynamicProvisioning deploys a storage class creating a dedicated file share for every PersistentVolumeClaim.
+	// +optional
+	DynamicProvisioning *DynamicProvisioning `json:"dynamicProvisioning,omitempty"`
+
+	// DeletionPolicy is what happens to the provisioner and the storage classes of a file share removed from
+	// the project or the selection, or of all file shares when the NfsProvisioner is deleted. Delete removes
+	// them, Orphan leaves them in place and BlockWhileInUse removes them once no PersistentVolume or claim
+	// uses the storage classes, keeping the NfsProvisioner finalizer until then.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// HelmConcurrency is the number of Helm releases of the provisioner installed, upgraded
+	// or uninstalled in parallel. Defaults to the --helm-concurrency flag of the controller.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	// +optional
+	HelmConcurrency *int32 `json:"helmConcurrency,omitempty"`
+
 	// Paused can be used to prevent controllers from processing the Provisioner and all its associated objects.
 	// +optional
 	Paused bool `json:"paused"`
 }
 
+// AutoExpand configures the extension of the file shares by the storage requested by their claims.
+type AutoExpand struct {
+	// ThresholdPercent is the percentage of the file share size the requested storage has to pass
+	// to extend the file share. The file share is extended so the requested storage is at the threshold.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=80
+	// +optional
+	ThresholdPercent int32 `json:"thresholdPercent,omitempty"`
+
+	// MaxSize is the size in GiB the file shares are never extended beyond.
+	// +kubebuilder:validation:Minimum=2
+	MaxSize int `json:"maxSize"`
+}
+
+// AccessRulesMode is the way the access rules of the file shares are managed.
+// +kubebuilder:validation:Enum=Manual;FromNodes
+type AccessRulesMode string
+
+const (
+	// AccessRulesModeManual leaves the access rules of the file shares to the user.
+	AccessRulesModeManual AccessRulesMode = "Manual"
+	// AccessRulesModeFromNodes allows the internal IPs of the cluster nodes and the extra rules, the other
+	// access rules of the file shares are removed.
+	AccessRulesModeFromNodes AccessRulesMode = "FromNodes"
+)
+
+// File share access modes of the access rules.
+const (
+	AccessModeReadWrite = "rw"
+	AccessModeReadOnly  = "ro"
+)
+
+// AccessRules configures the access rules of the file shares.
+type AccessRules struct {
+	// Mode of the access rules management.
+	// +kubebuilder:default=Manual
+	// +optional
+	Mode AccessRulesMode `json:"mode,omitempty"`
+
+	// AccessMode of the node access rules, rw or ro.
+	// +kubebuilder:validation:Enum=rw;ro
+	// +kubebuilder:default=rw
+	// +optional
+	AccessMode string `json:"accessMode,omitempty"`
+
+	// NodeSelector selects the nodes allowed to access the file shares. All nodes are selected when it is not set.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// PrefixLength allows the CIDRs of the given prefix length holding the node IPs instead of the node IPs,
+	// e.g. 24 allows the /24 networks of the nodes.
+	// +kubebuilder:validation:Minimum=8
+	// +kubebuilder:validation:Maximum=32
+	// +optional
+	PrefixLength *int32 `json:"prefixLength,omitempty"`
+
+	// ExtraRules are allowed in addition to the nodes, e.g. for clients outside of the cluster.
+	// +optional
+	ExtraRules []AccessRule `json:"extraRules,omitempty"`
+}
+
+// AccessRule allows an IP address or CIDR to access a file share.
+type AccessRule struct {
+	// IPAddress is an IPv4 address or CIDR.
+	IPAddress string `json:"ipAddress"`
+	// AccessMode of the rule, rw or ro.
+	// +kubebuilder:validation:Enum=rw;ro
+	// +kubebuilder:default=rw
+	// +optional
+	AccessMode string `json:"accessMode,omitempty"`
+}
+
+// ParseAccessRuleAddress parses the IPv4 address or CIDR of an access rule, an address is a /32 prefix.
+func ParseAccessRuleAddress(ipAddress string) (netip.Prefix, error) {
+	var prefix netip.Prefix
+	if strings.Contains(ipAddress, "/") {
+		var err error
+		if prefix, err = netip.ParsePrefix(ipAddress); err != nil {
+			return netip.Prefix{}, err
+		}
+	} else {
+		addr, err := netip.ParseAddr(ipAddress)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if !prefix.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%s is not an IPv4 address or CIDR", ipAddress)
+	}
+	return prefix.Masked(), nil
+}
+
+// AccessRulesChange is a change of the access rules of a file share made by the controller.
+type AccessRulesChange struct {
+	// Time of the change.
+	Time metav1.Time `json:"time"`
+	// Added are the missing access rules, of new nodes or removed out of band.
+	// +optional
+	Added []AccessRule `json:"added,omitempty"`
+	// Removed are the access rules which are not desired, of removed nodes or added out of band.
+	// +optional
+	Removed []AccessRule `json:"removed,omitempty"`
+}
+
+// UsageMonitoring configures the probes measuring the usage of the file shares.
+type UsageMonitoring struct {
+	// Interval is the time between two probes of a file share, it is at least one minute.
+	// +kubebuilder:default="10m"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// ThresholdPercent is the percentage of the file share capacity the used storage has to pass
+	// to raise the UsageThresholdExceeded condition.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=90
+	// +optional
+	ThresholdPercent int32 `json:"thresholdPercent,omitempty"`
+
+	// Image is the image of the probe container, it runs "stat -f" on the mounted file share.
+	// +kubebuilder:default="busybox:1.36"
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// FileShareUsage is the usage of a file share measured by the statfs of a probe mounting it.
+type FileShareUsage struct {
+	// Used is the storage used on the file share.
+	Used resource.Quantity `json:"used"`
+	// Available is the storage available on the file share.
+	Available resource.Quantity `json:"available"`
+	// UsedPercent is the used storage in percent of the file share capacity.
+	UsedPercent int32 `json:"usedPercent"`
+	// ProbeTime is the time the usage was measured.
+	ProbeTime metav1.Time `json:"probeTime"`
+}
+
+// DynamicProvisioning configures the storage class provisioning a dedicated file share for every claim.
+type DynamicProvisioning struct {
+	// StorageClassName is the name of the storage class.
+	StorageClassName string `json:"storageClassName"`
+
+	// Network the file shares are attached to.
+	Network FileShareNetwork `json:"network"`
+
+	// VolumeType of the file shares. Defaults to the Gcore Cloud default.
+	// +optional
+	VolumeType string `json:"volumeType,omitempty"`
+
+	// ReclaimPolicy of the volumes. Delete deletes the file share of a released volume, Retain keeps
+	// the volume and its FileShare.
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +kubebuilder:default=Delete
+	// +optional
+	ReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// SecretKeyReference references a key of a Secret in the NfsProvisioner namespace.
+type SecretKeyReference struct {
+	// Name of the Secret in the NfsProvisioner namespace.
+	Name string `json:"name"`
+	// Key in the Secret data.
+	Key string `json:"key"`
+}
+
+// ChartConfigMapReference references a key of a ConfigMap holding a chart archive.
+type ChartConfigMapReference struct {
+	// Name of the ConfigMap in the NfsProvisioner namespace.
+	Name string `json:"name"`
+	// Key in the ConfigMap binary data. Defaults to chart.tgz.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ValuesReference references a key of a ConfigMap or Secret holding Helm values.
+type ValuesReference struct {
+	// Kind of the values source.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Name of the values source in the NfsProvisioner namespace.
+	Name string `json:"name"`
+	// ValuesKey is the data key of the values YAML document. Defaults to values.yaml.
+	// +optional
+	ValuesKey string `json:"valuesKey,omitempty"`
+	// Optional marks the values source as optional, a missing source or key is ignored.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// FileShareSelector selects file shares by ID, name and metadata.
+// A file share is selected when it matches all the set criteria.
+type FileShareSelector struct {
+	// IDs of the selected file shares.
+	// +optional
+	IDs []string `json:"ids,omitempty"`
+	// ExcludeIDs are the IDs of file shares which are never selected.
+	// +optional
+	ExcludeIDs []string `json:"excludeIDs,omitempty"`
+	// IncludeNames are regular expressions, a file share is selected when its name matches any of them.
+	// +optional
+	IncludeNames []string `json:"includeNames,omitempty"`
+	// ExcludeNames are regular expressions, a file share is not selected when its name matches any of them.
+	// +optional
+	ExcludeNames []string `json:"excludeNames,omitempty"`
+	// MatchMetadata selects file shares whose Gcore metadata contains all the key-value pairs.
+	// +optional
+	MatchMetadata map[string]string `json:"matchMetadata,omitempty"`
+}
+
+// StorageClassNameData is the data spec.storageClassNameTemplate is rendered with.
+// +kubebuilder:object:generate=false
+type StorageClassNameData struct {
+	// Name of the file share.
+	Name string
+	// ID of the file share.
+	ID string
+	// Region ID of the file share.
+	Region int
+	// Project ID of the file share.
+	Project int
+	// Size of the file share in GiB.
+	Size int
+}
+
+// ParseStorageClassNameTemplate parses spec.storageClassNameTemplate.
+func ParseStorageClassNameTemplate(text string) (*template.Template, error) {
+	return template.New("storageClassName").Option("missingkey=error").Parse(text)
+}
+
+// StorageClassParameters are the settings of the file share storage classes.
+type StorageClassParameters struct {
+	// ReclaimPolicy of the storage class. Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +optional
+	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// ArchiveOnDelete archives the volume directory instead of deleting it when the volume is deleted.
+	// Defaults to true.
+	// +optional
+	ArchiveOnDelete *bool `json:"archiveOnDelete,omitempty"`
+	// OnDelete is the action on the volume directory when the volume is deleted, it takes precedence
+	// over archiveOnDelete. "delete" deletes the directory and "retain" keeps it as is.
+	// +kubebuilder:validation:Enum=delete;retain
+	// +optional
+	OnDelete string `json:"onDelete,omitempty"`
+	// PathPattern is the path of the volume directories within the file share, built from the claim
+	// metadata, e.g. "${.PVC.namespace}/${.PVC.name}" or "${.PVC.annotations.nfs.io/storage-path}".
+	// +optional
+	PathPattern string `json:"pathPattern,omitempty"`
+	// VolumeBindingMode of the storage class. Defaults to Immediate.
+	// +kubebuilder:validation:Enum=Immediate;WaitForFirstConsumer
+	// +optional
+	VolumeBindingMode *storagev1.VolumeBindingMode `json:"volumeBindingMode,omitempty"`
+	// StorageClassLabels are extra labels of the storage class. In Helm deployment mode they are set on the
+	// storage class of the release, not on the other provisioner objects. The labels managed by the controller
+	// can not be overridden.
+	// +optional
+	StorageClassLabels map[string]string `json:"storageClassLabels,omitempty"`
+	// StorageClassAnnotations are extra annotations of the storage class.
+	// +optional
+	StorageClassAnnotations map[string]string `json:"storageClassAnnotations,omitempty"`
+}
+
+// FileShareOverride overrides the provisioner configuration of the file shares matching its ID or name.
+type FileShareOverride struct {
+	// ID of the matching file share.
+	// +optional
+	ID string `json:"id,omitempty"`
+	// Name of the matching file share. Glob patterns such as "db-*" are supported.
+	// When both id and name are set, the override matches a file share by either of them.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// StorageClassName is the name of the file share storage class.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// MountOptions are the NFS mount options of the file share, e.g. "hard" or "nfsvers=4.1".
+	// They replace spec.mountOptions, the last matching override with mount options takes precedence.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+	// StorageClassParameters override the storage class parameters of spec for the file share.
+	StorageClassParameters `json:",inline"`
+	// Values are Helm values of the file share provisioner, they are merged over spec.values.
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+}
+
+// Matches reports whether the override applies to the file share, matched by its ID or by its name.
+func (o *FileShareOverride) Matches(fileShareID string, fileShareName string) bool {
+	if o.ID != "" && o.ID == fileShareID {
+		return true
+	}
+	if o.Name != "" {
+		matched, err := path.Match(o.Name, fileShareName)
+		return err == nil && matched
+	}
+	return false
+}
+
+// FileShareStatus defines the observed state of a file share selected by the NfsProvisioner.
+type FileShareStatus struct {
+	// ID of the file share.
+	ID string `json:"id"`
+	// Name of the file share.
+	Name string `json:"name"`
+	// ConnectionPoint is the NFS export of the file share, e.g. "10.33.20.241:/shares/share-e1dca5e4".
+	// +optional
+	ConnectionPoint string `json:"connectionPoint,omitempty"`
+	// Size of the file share in GiB.
+	// +optional
+	Size int `json:"size,omitempty"`
+	// CloudStatus is the file share status in Gcore Cloud.
+	// +optional
+	CloudStatus string `json:"cloudStatus,omitempty"`
+	// ReleaseName is the name of the provisioner release of the file share,
+	// in Native deployment mode it is the name of the provisioner Deployment.
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+	// StorageClassName is the name of the file share storage class.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// ChartVersion is the provisioner chart version installed for the file share.
+	// +optional
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// Ready denotes that the file share provisioner has been deployed and running.
+	Ready bool `json:"ready"`
+	// Message explains why the file share provisioner is not ready.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// ClaimedBy is the owner of the StorageClass or release of the file share when they are already
+	// claimed by another NfsProvisioner or another file share of the NfsProvisioner.
+	// +optional
+	ClaimedBy string `json:"claimedBy,omitempty"`
+	// Failures is the number of consecutive failed attempts to deploy the file share provisioner.
+	// +optional
+	Failures int32 `json:"failures,omitempty"`
+	// LastFailureTime is the time of the last failed attempt to deploy the file share provisioner.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// NextRetryTime is the earliest time the failed file share provisioner is deployed again.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+	// MountOptions are the NFS mount options in effect for the storage class and the provisioner of the file share.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+	// RetainedStorageClassNames are the former storage classes of the file share, they are kept
+	// until no PersistentVolume or PersistentVolumeClaim uses them.
+	// +optional
+	RetainedStorageClassNames []string `json:"retainedStorageClassNames,omitempty"`
+	// AppliedHash is the hash of the chart reference, chart version and values of the last
+	// successful Helm install or upgrade of the file share provisioner.
+	// +optional
+	AppliedHash string `json:"appliedHash,omitempty"`
+	// RequestedSize is the storage in GiB requested by the claims of the file share storage classes.
+	// +optional
+	RequestedSize int `json:"requestedSize,omitempty"`
+	// LastResize is the last extension of the file share by spec.autoExpand.
+	// +optional
+	LastResize *FileShareResize `json:"lastResize,omitempty"`
+	// AccessRules are the access rules of the file share in FromNodes access rules mode.
+	// +optional
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+	// LastAccessRulesChange is the last change of the access rules made to match the nodes.
+	// +optional
+	LastAccessRulesChange *AccessRulesChange `json:"lastAccessRulesChange,omitempty"`
+	// Capacity is the size of the file share in Gcore Cloud.
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+	// Usage is the last usage of the file share measured with spec.usageMonitoring.
+	// +optional
+	Usage *FileShareUsage `json:"usage,omitempty"`
+	// UsageMessage explains why the last usage probe of the file share failed.
+	// +optional
+	UsageMessage string `json:"usageMessage,omitempty"`
+}
+
+// FileShareResizeState is the state of a file share extension.
+type FileShareResizeState string
+
+const (
+	FileShareResizeRunning   FileShareResizeState = "Running"
+	FileShareResizeSucceeded FileShareResizeState = "Succeeded"
+	FileShareResizeFailed    FileShareResizeState = "Failed"
+)
+
+// FileShareResize is an extension of a file share in Gcore Cloud.
+type FileShareResize struct {
+	// FromSize is the size of the file share in GiB before the extension.
+	FromSize int `json:"fromSize"`
+	// ToSize is the size of the file share in GiB after the extension.
+	ToSize int `json:"toSize"`
+	// RequestedSize is the storage in GiB requested by the claims which triggered the extension.
+	// +optional
+	RequestedSize int `json:"requestedSize,omitempty"`
+	// TaskID is the Gcore Cloud task extending the file share.
+	// +optional
+	TaskID string `json:"taskID,omitempty"`
+	// State of the extension.
+	State FileShareResizeState `json:"state"`
+	// Message explains why the extension failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// StartTime is the time the extension was requested.
+	StartTime metav1.Time `json:"startTime"`
+	// CompletionTime is the time the extension succeeded or failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
 // NfsProvisionerStatus defines the observed state of NfsProvisioner
 type NfsProvisionerStatus struct {
 	// Ready denotes that all nfs file share provisioners has been deployed and running
 	ProvisionersReady bool `json:"provisionersReady"`
+
+	// ChartVersion is the provisioner chart version installed for the file shares.
+	// It is empty while the releases run different chart versions.
+	// +optional
+	ChartVersion string `json:"chartVersion,omitempty"`
+
+	// ObservedGeneration is the latest NfsProvisioner generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is the time of the last successful synchronization of the file shares.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// DefaultStorageClassName is the name of the storage class annotated as the cluster default.
+	// +optional
+	DefaultStorageClassName string `json:"defaultStorageClassName,omitempty"`
+
+	// Conditions of the NfsProvisioner.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// FileShares are the file shares selected by the NfsProvisioner.
+	// +optional
+	FileShares []FileShareStatus `json:"fileShares,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason"
+//+kubebuilder:printcolumn:name="Last Sync",type="date",JSONPath=".status.lastSyncTime"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // NfsProvisioner is the Schema for the nfsprovisioners API
 type NfsProvisioner struct {
@@ -86,6 +738,15 @@ type NfsProvisionerList struct {
 	Items           []NfsProvisioner `json:"items"`
 }
 
+// APITokenSecretName returns the namespaced name of the Secret referenced by APITokenSecretRef,
+// it is always resolved in the NfsProvisioner namespace.
+func (r *NfsProvisioner) APITokenSecretName() types.NamespacedName {
+	if r.Spec.APITokenSecretRef == nil {
+		return types.NamespacedName{}
+	}
+	return types.NamespacedName{Namespace: r.Namespace, Name: r.Spec.APITokenSecretRef.Name}
+}
+
 func init() {
 	SchemeBuilder.Register(&NfsProvisioner{}, &NfsProvisionerList{})
 }
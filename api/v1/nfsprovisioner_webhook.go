@@ -17,9 +17,22 @@ limitations under the License.
 package v1
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -32,6 +45,29 @@ const (
 	DefaultApiUrl                     = "https://api.gcore.com/cloud"
 	DefaultHelmRepository             = "https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner"
 	DefaultHelmChartName              = "nfs-subdir-external-provisioner"
+	DefaultValuesKey                  = "values.yaml"
+	DefaultChartKey                   = "chart.tgz"
+)
+
+// Keys of the Secret referenced by spec.helmRepositorySecretRef.
+const (
+	HelmRepositoryUsernameKey = "username"
+	HelmRepositoryPasswordKey = "password"
+	HelmRepositoryCAKey       = "ca.crt"
+)
+
+// Actions of spec.onDelete.
+const (
+	OnDeleteDelete = "delete"
+	OnDeleteRetain = "retain"
+)
+
+// IsDefaultStorageClassAnnotation marks the default storage class of the cluster.
+const IsDefaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+const (
+	ValuesKindConfigMap = "ConfigMap"
+	ValuesKindSecret    = "Secret"
 )
 
 // log is for logging in this package.
@@ -54,6 +90,9 @@ func (r *NfsProvisioner) Default() {
 		r.Spec.APIURL = DefaultApiUrl
 	}
 	nfsprovisionerlog.Info("default", "apiURL", r.Spec.APIURL)
+	if r.Spec.DeploymentMode == "" {
+		r.Spec.DeploymentMode = DeploymentModeHelm
+	}
 	if r.Spec.HelmRepository == "" {
 		r.Spec.HelmRepository = DefaultHelmRepository
 	}
@@ -66,6 +105,14 @@ func (r *NfsProvisioner) Default() {
 		r.Spec.ImageVersion = DefaultNfsProvisionerImageVersion
 	}
 	nfsprovisionerlog.Info("default", "imageVersion", r.Spec.ImageVersion)
+	if r.Spec.ChartConfigMapRef != nil && r.Spec.ChartConfigMapRef.Key == "" {
+		r.Spec.ChartConfigMapRef.Key = DefaultChartKey
+	}
+	for i := range r.Spec.ValuesFrom {
+		if r.Spec.ValuesFrom[i].ValuesKey == "" {
+			r.Spec.ValuesFrom[i].ValuesKey = DefaultValuesKey
+		}
+	}
 }
 
 //+kubebuilder:webhook:path=/validate-crd-gcore-sfs-controller-io-v1-nfsprovisioner,mutating=false,failurePolicy=fail,sideEffects=None,groups=crd.gcore-sfs-controller.io,resources=nfsprovisioners,verbs=create;update,versions=v1,name=vnfsprovisioner.kb.io,admissionReviewVersions=v1
@@ -82,6 +129,62 @@ func ValidateNfsProvisioner(r *NfsProvisioner) error {
 		projectErr := field.Invalid(field.NewPath("spec").Child("project"), r.Spec.RegionID, "must be positive")
 		allErrs = append(allErrs, projectErr)
 	}
+	if r.Spec.APIToken == "" && r.Spec.APITokenSecretRef == nil {
+		tokenErr := field.Required(field.NewPath("spec").Child("apiTokenSecretRef"), "one of apiToken or apiTokenSecretRef must be set")
+		allErrs = append(allErrs, tokenErr)
+	}
+	if r.Spec.APIToken != "" && r.Spec.APITokenSecretRef != nil {
+		tokenErr := field.Forbidden(field.NewPath("spec").Child("apiToken"), "apiToken and apiTokenSecretRef are mutually exclusive")
+		allErrs = append(allErrs, tokenErr)
+	}
+	if r.Spec.ChartVersion != "" {
+		if _, err := semver.NewConstraint(r.Spec.ChartVersion); err != nil {
+			versionErr := field.Invalid(field.NewPath("spec").Child("chartVersion"), r.Spec.ChartVersion, err.Error())
+			allErrs = append(allErrs, versionErr)
+		}
+	}
+	if r.Spec.ChartURL != "" {
+		if chartURL, err := url.Parse(r.Spec.ChartURL); err != nil || (chartURL.Scheme != "http" && chartURL.Scheme != "https") || chartURL.Host == "" {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("chartURL"), r.Spec.ChartURL, "must be an http or https URL"))
+		}
+		if r.Spec.ChartConfigMapRef != nil {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec").Child("chartURL"), "chartURL and chartConfigMapRef are mutually exclusive"))
+		}
+	}
+	if r.Spec.Values != nil {
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(r.Spec.Values.Raw, &values); err != nil {
+			valuesErr := field.Invalid(field.NewPath("spec").Child("values"), string(r.Spec.Values.Raw), "must be an object")
+			allErrs = append(allErrs, valuesErr)
+		}
+	}
+	if r.Spec.DeploymentMode == DeploymentModeNative {
+		allErrs = append(allErrs, validateNativeDeploymentMode(field.NewPath("spec"), &r.Spec)...)
+	}
+	if r.Spec.StorageClassNameTemplate != "" {
+		allErrs = append(allErrs, validateStorageClassNameTemplate(field.NewPath("spec").Child("storageClassNameTemplate"), r.Spec.StorageClassNameTemplate)...)
+	}
+	allErrs = append(allErrs, validateMountOptions(field.NewPath("spec").Child("mountOptions"), r.Spec.MountOptions)...)
+	allErrs = append(allErrs, validateStorageClassParameters(field.NewPath("spec"), &r.Spec.StorageClassParameters)...)
+	if r.Spec.FileShareSelector != nil {
+		allErrs = append(allErrs, validateFileShareSelector(field.NewPath("spec").Child("fileShareSelector"), r.Spec.FileShareSelector)...)
+	}
+	if r.Spec.DynamicProvisioning != nil {
+		fldPath := field.NewPath("spec").Child("dynamicProvisioning").Child("storageClassName")
+		for _, msg := range validation.IsDNS1123Subdomain(r.Spec.DynamicProvisioning.StorageClassName) {
+			allErrs = append(allErrs, field.Invalid(fldPath, r.Spec.DynamicProvisioning.StorageClassName, msg))
+		}
+	}
+	if r.Spec.AccessRules != nil {
+		allErrs = append(allErrs, validateAccessRules(field.NewPath("spec").Child("accessRules"), r.Spec.AccessRules)...)
+	}
+	if r.Spec.UsageMonitoring != nil && r.Spec.UsageMonitoring.Interval != nil && r.Spec.UsageMonitoring.Interval.Duration < time.Minute {
+		fldPath := field.NewPath("spec").Child("usageMonitoring").Child("interval")
+		allErrs = append(allErrs, field.Invalid(fldPath, r.Spec.UsageMonitoring.Interval.Duration.String(), "must be at least 1m"))
+	}
+	for i, override := range r.Spec.FileShareOverrides {
+		allErrs = append(allErrs, validateFileShareOverride(field.NewPath("spec").Child("fileShareOverrides").Index(i), &override)...)
+	}
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -90,14 +193,184 @@ func ValidateNfsProvisioner(r *NfsProvisioner) error {
 		r.Name, allErrs)
 }
 
+// validateNativeDeploymentMode rejects the Helm values, they are not used by the Native deployment mode.
+func validateNativeDeploymentMode(fldPath *field.Path, spec *NfsProvisionerSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Values != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("values"), "values are not supported in Native deployment mode"))
+	}
+	if len(spec.ValuesFrom) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("valuesFrom"), "valuesFrom is not supported in Native deployment mode"))
+	}
+	for i, override := range spec.FileShareOverrides {
+		if override.Values != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("fileShareOverrides").Index(i).Child("values"),
+				"values are not supported in Native deployment mode"))
+		}
+	}
+	return allErrs
+}
+
+// validateStorageClassNameTemplate renders the template with sample file share data,
+// so unknown fields are rejected before the controller renders the storage class names.
+func validateStorageClassNameTemplate(fldPath *field.Path, text string) field.ErrorList {
+	tmpl, err := ParseStorageClassNameTemplate(text)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, text, err.Error())}
+	}
+	sample := StorageClassNameData{Name: "file-share", ID: "d918f840-29a2-4d54-a67e-5c9d4e34a408", Region: 1, Project: 1, Size: 1}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, sample); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, text, err.Error())}
+	}
+	if strings.TrimSpace(rendered.String()) == "" {
+		return field.ErrorList{field.Invalid(fldPath, text, "must not render an empty name")}
+	}
+	return nil
+}
+
+// pathPatternPlaceholder matches the claim metadata placeholders supported in pathPattern.
+var pathPatternPlaceholder = regexp.MustCompile(`\$\{\.PVC\.(namespace|name|labels\.[^}]+|annotations\.[^}]+)\}`)
+
+func validateStorageClassParameters(fldPath *field.Path, parameters *StorageClassParameters) field.ErrorList {
+	var allErrs field.ErrorList
+	if parameters.ReclaimPolicy != nil && *parameters.ReclaimPolicy != corev1.PersistentVolumeReclaimDelete &&
+		*parameters.ReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("reclaimPolicy"), *parameters.ReclaimPolicy,
+			[]string{string(corev1.PersistentVolumeReclaimDelete), string(corev1.PersistentVolumeReclaimRetain)}))
+	}
+	if parameters.OnDelete != "" && parameters.OnDelete != OnDeleteDelete && parameters.OnDelete != OnDeleteRetain {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("onDelete"), parameters.OnDelete, []string{OnDeleteDelete, OnDeleteRetain}))
+	}
+	if parameters.VolumeBindingMode != nil && *parameters.VolumeBindingMode != storagev1.VolumeBindingImmediate &&
+		*parameters.VolumeBindingMode != storagev1.VolumeBindingWaitForFirstConsumer {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("volumeBindingMode"), *parameters.VolumeBindingMode,
+			[]string{string(storagev1.VolumeBindingImmediate), string(storagev1.VolumeBindingWaitForFirstConsumer)}))
+	}
+	if parameters.PathPattern != "" {
+		pathPattern := pathPatternPlaceholder.ReplaceAllString(parameters.PathPattern, "x")
+		switch {
+		case strings.Contains(pathPattern, "${"):
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("pathPattern"), parameters.PathPattern,
+				"only ${.PVC.namespace}, ${.PVC.name}, ${.PVC.labels.<key>} and ${.PVC.annotations.<key>} are supported"))
+		case path.IsAbs(pathPattern) || strings.Contains("/"+pathPattern+"/", "/../"):
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("pathPattern"), parameters.PathPattern,
+				"must be a relative path within the file share"))
+		}
+	}
+	for key, value := range parameters.StorageClassLabels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("storageClassLabels"), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("storageClassLabels").Key(key), value, msg))
+		}
+	}
+	for key := range parameters.StorageClassAnnotations {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("storageClassAnnotations"), key, msg))
+		}
+		if key == IsDefaultStorageClassAnnotation {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("storageClassAnnotations").Key(key),
+				"the default storage class is set by spec.defaultStorageClass"))
+		}
+	}
+	return allErrs
+}
+
+func validateFileShareSelector(fldPath *field.Path, selector *FileShareSelector) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, name := range selector.IncludeNames {
+		if _, err := regexp.Compile(name); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("includeNames").Index(i), name, err.Error()))
+		}
+	}
+	for i, name := range selector.ExcludeNames {
+		if _, err := regexp.Compile(name); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("excludeNames").Index(i), name, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateAccessRules(fldPath *field.Path, accessRules *AccessRules) field.ErrorList {
+	var allErrs field.ErrorList
+	if accessRules.NodeSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(accessRules.NodeSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("nodeSelector"), accessRules.NodeSelector, err.Error()))
+		}
+	}
+	for i, rule := range accessRules.ExtraRules {
+		if _, err := ParseAccessRuleAddress(rule.IPAddress); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("extraRules").Index(i).Child("ipAddress"), rule.IPAddress, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateFileShareOverride(fldPath *field.Path, override *FileShareOverride) field.ErrorList {
+	var allErrs field.ErrorList
+	if override.ID == "" && override.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath, "one of id or name must be set"))
+	}
+	if override.Name != "" {
+		if _, err := path.Match(override.Name, ""); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), override.Name, err.Error()))
+		}
+	}
+	if override.StorageClassName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(override.StorageClassName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("storageClassName"), override.StorageClassName, msg))
+		}
+	}
+	allErrs = append(allErrs, validateStorageClassParameters(fldPath, &override.StorageClassParameters)...)
+	allErrs = append(allErrs, validateMountOptions(fldPath.Child("mountOptions"), override.MountOptions)...)
+	if override.Values != nil {
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(override.Values.Raw, &values); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("values"), string(override.Values.Raw), "must be an object"))
+		}
+	}
+	return allErrs
+}
+
+// GetNfsProvisionerWarnings returns the admission warnings about risky NFS mount options of the spec and
+// of each file share override, the override is checked with the options in effect for its file shares.
+func GetNfsProvisionerWarnings(r *NfsProvisioner) admission.Warnings {
+	var warnings admission.Warnings
+	if len(r.Spec.MountOptions) > 0 {
+		warnings = append(warnings, getMountOptionWarnings(field.NewPath("spec").Child("mountOptions"), r.Spec.MountOptions, "")...)
+	}
+	for i, override := range r.Spec.FileShareOverrides {
+		name := override.Name
+		if name == "" {
+			name = override.ID
+		}
+		if override.StorageClassName != "" {
+			name = fmt.Sprintf("%s (%s)", name, override.StorageClassName)
+		}
+		fldPath := field.NewPath("spec").Child("fileShareOverrides").Index(i).Child("mountOptions")
+		mountOptions := GetEffectiveMountOptions(&r.Spec, &override)
+		if len(override.MountOptions) == 0 {
+			// The spec options are already checked, only a database file share makes them risky.
+			if _, soft := ParseMountOptions(mountOptions)["soft"]; !soft {
+				continue
+			}
+			mountOptions = []string{"soft"}
+		}
+		warnings = append(warnings, getMountOptionWarnings(fldPath, mountOptions, name)...)
+	}
+	return warnings
+}
+
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *NfsProvisioner) ValidateCreate() (admission.Warnings, error) {
-	return nil, ValidateNfsProvisioner(r)
+	return GetNfsProvisionerWarnings(r), ValidateNfsProvisioner(r)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *NfsProvisioner) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
-	return nil, ValidateNfsProvisioner(r)
+	return GetNfsProvisionerWarnings(r), ValidateNfsProvisioner(r)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
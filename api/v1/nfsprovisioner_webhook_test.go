@@ -1,9 +1,12 @@
 package v1
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -59,6 +62,7 @@ var _ = Describe("NfsProvisioner webhooks", func() {
 				Namespace: "default",
 			},
 			Spec: NfsProvisionerSpec{
+				APIToken:  "faketoken",
 				RegionID:  1,
 				ProjectID: 1,
 			},
@@ -69,5 +73,295 @@ var _ = Describe("NfsProvisioner webhooks", func() {
 		Expect(provisioner.Spec.HelmRepository).To(Equal(DefaultHelmRepository))
 		Expect(provisioner.Spec.ChartName).To(Equal(DefaultHelmChartName))
 		Expect(provisioner.Spec.ImageVersion).To(Equal(DefaultNfsProvisionerImageVersion))
+		Expect(provisioner.Spec.DeploymentMode).To(Equal(DeploymentModeHelm))
+	})
+	It("Check NfsProvisioner webhook missing api token", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner2",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				RegionID:  1,
+				ProjectID: 1,
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("one of apiToken or apiTokenSecretRef must be set")))
+	})
+	It("Check NfsProvisioner webhook api token and secret reference", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner2",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken: "faketoken",
+				APITokenSecretRef: &SecretKeyReference{
+					Name: "gcore-api-token",
+					Key:  "token",
+				},
+				RegionID:  1,
+				ProjectID: 1,
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("mutually exclusive")))
+	})
+	It("Check NfsProvisioner webhook api token secret reference", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner2",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APITokenSecretRef: &SecretKeyReference{
+					Name: "gcore-api-token",
+					Key:  "token",
+				},
+				RegionID:  1,
+				ProjectID: 1,
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provisioner.APITokenSecretName().String()).To(Equal("default/gcore-api-token"))
+	})
+	It("Check NfsProvisioner webhook invalid chart version", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:     "faketoken",
+				RegionID:     1,
+				ProjectID:    1,
+				ChartVersion: "not-a-version",
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("spec.chartVersion")))
+	})
+	It("Check NfsProvisioner webhook file share override without match", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:  "faketoken",
+				RegionID:  1,
+				ProjectID: 1,
+				FileShareOverrides: []FileShareOverride{
+					{MountOptions: []string{"hard"}},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("one of id or name must be set")))
+	})
+	It("Check NfsProvisioner webhook values in native deployment mode", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:       "faketoken",
+				RegionID:       1,
+				ProjectID:      1,
+				DeploymentMode: DeploymentModeNative,
+				Values:         &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":2}`)},
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("not supported in Native deployment mode")))
+	})
+	It("Check NfsProvisioner webhook invalid chart URL", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:  "faketoken",
+				RegionID:  1,
+				ProjectID: 1,
+				ChartURL:  "ftp://charts.example.com/nfs-subdir-external-provisioner-4.0.18.tgz",
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("spec.chartURL")))
+	})
+	It("Check NfsProvisioner webhook invalid storage class name template", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:                 "faketoken",
+				RegionID:                 1,
+				ProjectID:                1,
+				StorageClassNameTemplate: "sfs-{{ .Zone }}",
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("spec.storageClassNameTemplate")))
+	})
+	It("Check NfsProvisioner webhook invalid storage class parameters", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:  "faketoken",
+				RegionID:  1,
+				ProjectID: 1,
+				StorageClassParameters: StorageClassParameters{
+					PathPattern:             "../${.PVC.name}",
+					StorageClassAnnotations: map[string]string{IsDefaultStorageClassAnnotation: "true"},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("spec.pathPattern")))
+		Expect(err).To(MatchError(ContainSubstring("spec.storageClassAnnotations")))
+	})
+	It("Check NfsProvisioner webhook invalid mount options", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:     "faketoken",
+				RegionID:     1,
+				ProjectID:    1,
+				MountOptions: []string{"nfsvers=4.1", "hard", "soft", "timeo=abc"},
+				FileShareOverrides: []FileShareOverride{
+					{Name: "db-*", MountOptions: []string{"hard,noresvport"}},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("soft and hard can not be used together")))
+		Expect(err).To(MatchError(ContainSubstring("spec.mountOptions[3]")))
+		Expect(err).To(MatchError(ContainSubstring("spec.fileShareOverrides[0].mountOptions[0]")))
+	})
+	It("Check NfsProvisioner webhook invalid access rules", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:  "faketoken",
+				RegionID:  1,
+				ProjectID: 1,
+				AccessRules: &AccessRules{
+					Mode: AccessRulesModeFromNodes,
+					ExtraRules: []AccessRule{
+						{IPAddress: "192.168.10.0/24"},
+						{IPAddress: "fd00::1"},
+						{IPAddress: "10.0.0.300"},
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("spec.accessRules.extraRules[1].ipAddress")))
+		Expect(err).To(MatchError(ContainSubstring("spec.accessRules.extraRules[2].ipAddress")))
+		Expect(err).NotTo(MatchError(ContainSubstring("spec.accessRules.extraRules[0]")))
+	})
+	It("Check NfsProvisioner webhook invalid usage monitoring interval", func() {
+		provisioner := NfsProvisioner{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NfsProvisioner",
+				APIVersion: GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner3",
+				Namespace: "default",
+			},
+			Spec: NfsProvisionerSpec{
+				APIToken:        "faketoken",
+				RegionID:        1,
+				ProjectID:       1,
+				UsageMonitoring: &UsageMonitoring{Interval: &metav1.Duration{Duration: 30 * time.Second}},
+			},
+		}
+		err := k8sClient.Create(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("spec.usageMonitoring.interval")))
+	})
+	It("Check NfsProvisioner webhook mount option warnings", func() {
+		provisioner := NfsProvisioner{
+			Spec: NfsProvisionerSpec{
+				MountOptions: []string{"nfsvers=4.1", "hard", "nolock"},
+				FileShareOverrides: []FileShareOverride{
+					{Name: "postgres-*", MountOptions: []string{"soft", "timeo=600"}},
+					{Name: "web-*", MountOptions: []string{"soft", "fancy"}},
+				},
+			},
+		}
+		warnings := GetNfsProvisionerWarnings(&provisioner)
+		Expect(warnings).To(HaveLen(3))
+		Expect(warnings[0]).To(ContainSubstring("spec.mountOptions: nolock"))
+		Expect(warnings[1]).To(ContainSubstring(`database file share "postgres-*"`))
+		Expect(warnings[2]).To(ContainSubstring(`unknown NFS mount option "fancy"`))
+
+		provisioner.Spec.MountOptions = nil
+		provisioner.Spec.FileShareOverrides = []FileShareOverride{{Name: "web", StorageClassName: "mysql-nfs"}}
+		Expect(GetNfsProvisionerWarnings(&provisioner)).To(ConsistOf(ContainSubstring(`database file share "web (mysql-nfs)"`)))
+
+		provisioner.Spec.MountOptions = []string{"soft"}
+		provisioner.Spec.FileShareOverrides = nil
+		Expect(GetNfsProvisionerWarnings(&provisioner)).To(ConsistOf(ContainSubstring("spec.mountOptions: soft applies to every file share")))
 	})
 })
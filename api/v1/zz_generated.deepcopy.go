@@ -22,16 +22,431 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRule) DeepCopyInto(out *AccessRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRule.
+func (in *AccessRule) DeepCopy() *AccessRule {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRules) DeepCopyInto(out *AccessRules) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrefixLength != nil {
+		in, out := &in.PrefixLength, &out.PrefixLength
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ExtraRules != nil {
+		in, out := &in.ExtraRules, &out.ExtraRules
+		*out = make([]AccessRule, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRules.
+func (in *AccessRules) DeepCopy() *AccessRules {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessRulesChange) DeepCopyInto(out *AccessRulesChange) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Added != nil {
+		in, out := &in.Added, &out.Added
+		*out = make([]AccessRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]AccessRule, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessRulesChange.
+func (in *AccessRulesChange) DeepCopy() *AccessRulesChange {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessRulesChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoExpand) DeepCopyInto(out *AutoExpand) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoExpand.
+func (in *AutoExpand) DeepCopy() *AutoExpand {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoExpand)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChartConfigMapReference) DeepCopyInto(out *ChartConfigMapReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChartConfigMapReference.
+func (in *ChartConfigMapReference) DeepCopy() *ChartConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ChartConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicProvisioning) DeepCopyInto(out *DynamicProvisioning) {
+	*out = *in
+	out.Network = in.Network
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicProvisioning.
+func (in *DynamicProvisioning) DeepCopy() *DynamicProvisioning {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicProvisioning)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShare) DeepCopyInto(out *FileShare) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShare.
+func (in *FileShare) DeepCopy() *FileShare {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShare)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FileShare) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareList) DeepCopyInto(out *FileShareList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FileShare, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareList.
+func (in *FileShareList) DeepCopy() *FileShareList {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FileShareList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareNetwork) DeepCopyInto(out *FileShareNetwork) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareNetwork.
+func (in *FileShareNetwork) DeepCopy() *FileShareNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareOverride) DeepCopyInto(out *FileShareOverride) {
+	*out = *in
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.StorageClassParameters.DeepCopyInto(&out.StorageClassParameters)
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareOverride.
+func (in *FileShareOverride) DeepCopy() *FileShareOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareResize) DeepCopyInto(out *FileShareResize) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareResize.
+func (in *FileShareResize) DeepCopy() *FileShareResize {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareResize)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareResourceStatus) DeepCopyInto(out *FileShareResourceStatus) {
+	*out = *in
+	if in.AccessRules != nil {
+		in, out := &in.AccessRules, &out.AccessRules
+		*out = make([]AccessRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastAccessRulesChange != nil {
+		in, out := &in.LastAccessRulesChange, &out.LastAccessRulesChange
+		*out = new(AccessRulesChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareResourceStatus.
+func (in *FileShareResourceStatus) DeepCopy() *FileShareResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareSelector) DeepCopyInto(out *FileShareSelector) {
+	*out = *in
+	if in.IDs != nil {
+		in, out := &in.IDs, &out.IDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeIDs != nil {
+		in, out := &in.ExcludeIDs, &out.ExcludeIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IncludeNames != nil {
+		in, out := &in.IncludeNames, &out.IncludeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeNames != nil {
+		in, out := &in.ExcludeNames, &out.ExcludeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MatchMetadata != nil {
+		in, out := &in.MatchMetadata, &out.MatchMetadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareSelector.
+func (in *FileShareSelector) DeepCopy() *FileShareSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareSpec) DeepCopyInto(out *FileShareSpec) {
+	*out = *in
+	out.ProvisionerRef = in.ProvisionerRef
+	out.Network = in.Network
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareSpec.
+func (in *FileShareSpec) DeepCopy() *FileShareSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareStatus) DeepCopyInto(out *FileShareStatus) {
+	*out = *in
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRetryTime != nil {
+		in, out := &in.NextRetryTime, &out.NextRetryTime
+		*out = (*in).DeepCopy()
+	}
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RetainedStorageClassNames != nil {
+		in, out := &in.RetainedStorageClassNames, &out.RetainedStorageClassNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastResize != nil {
+		in, out := &in.LastResize, &out.LastResize
+		*out = new(FileShareResize)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AccessRules != nil {
+		in, out := &in.AccessRules, &out.AccessRules
+		*out = make([]AccessRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastAccessRulesChange != nil {
+		in, out := &in.LastAccessRulesChange, &out.LastAccessRulesChange
+		*out = new(AccessRulesChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(FileShareUsage)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareStatus.
+func (in *FileShareStatus) DeepCopy() *FileShareStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileShareUsage) DeepCopyInto(out *FileShareUsage) {
+	*out = *in
+	out.Used = in.Used.DeepCopy()
+	out.Available = in.Available.DeepCopy()
+	in.ProbeTime.DeepCopyInto(&out.ProbeTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileShareUsage.
+func (in *FileShareUsage) DeepCopy() *FileShareUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(FileShareUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NfsProvisioner) DeepCopyInto(out *NfsProvisioner) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NfsProvisioner.
@@ -87,6 +502,74 @@ func (in *NfsProvisionerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NfsProvisionerSpec) DeepCopyInto(out *NfsProvisionerSpec) {
 	*out = *in
+	if in.APITokenSecretRef != nil {
+		in, out := &in.APITokenSecretRef, &out.APITokenSecretRef
+		*out = new(SecretKeyReference)
+		**out = **in
+	}
+	if in.HelmRepositorySecretRef != nil {
+		in, out := &in.HelmRepositorySecretRef, &out.HelmRepositorySecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.ChartConfigMapRef != nil {
+		in, out := &in.ChartConfigMapRef, &out.ChartConfigMapRef
+		*out = new(ChartConfigMapReference)
+		**out = **in
+	}
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.FileShareSelector != nil {
+		in, out := &in.FileShareSelector, &out.FileShareSelector
+		*out = new(FileShareSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.StorageClassParameters.DeepCopyInto(&out.StorageClassParameters)
+	if in.FileShareOverrides != nil {
+		in, out := &in.FileShareOverrides, &out.FileShareOverrides
+		*out = make([]FileShareOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AutoExpand != nil {
+		in, out := &in.AutoExpand, &out.AutoExpand
+		*out = new(AutoExpand)
+		**out = **in
+	}
+	if in.AccessRules != nil {
+		in, out := &in.AccessRules, &out.AccessRules
+		*out = new(AccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UsageMonitoring != nil {
+		in, out := &in.UsageMonitoring, &out.UsageMonitoring
+		*out = new(UsageMonitoring)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DynamicProvisioning != nil {
+		in, out := &in.DynamicProvisioning, &out.DynamicProvisioning
+		*out = new(DynamicProvisioning)
+		**out = **in
+	}
+	if in.HelmConcurrency != nil {
+		in, out := &in.HelmConcurrency, &out.HelmConcurrency
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NfsProvisionerSpec.
@@ -102,6 +585,24 @@ func (in *NfsProvisionerSpec) DeepCopy() *NfsProvisionerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NfsProvisionerStatus) DeepCopyInto(out *NfsProvisionerStatus) {
 	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FileShares != nil {
+		in, out := &in.FileShares, &out.FileShares
+		*out = make([]FileShareStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NfsProvisionerStatus.
@@ -113,3 +614,97 @@ func (in *NfsProvisionerStatus) DeepCopy() *NfsProvisionerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
+func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClassParameters) DeepCopyInto(out *StorageClassParameters) {
+	*out = *in
+	if in.ReclaimPolicy != nil {
+		in, out := &in.ReclaimPolicy, &out.ReclaimPolicy
+		*out = new(corev1.PersistentVolumeReclaimPolicy)
+		**out = **in
+	}
+	if in.ArchiveOnDelete != nil {
+		in, out := &in.ArchiveOnDelete, &out.ArchiveOnDelete
+		*out = new(bool)
+		**out = **in
+	}
+	if in.VolumeBindingMode != nil {
+		in, out := &in.VolumeBindingMode, &out.VolumeBindingMode
+		*out = new(storagev1.VolumeBindingMode)
+		**out = **in
+	}
+	if in.StorageClassLabels != nil {
+		in, out := &in.StorageClassLabels, &out.StorageClassLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StorageClassAnnotations != nil {
+		in, out := &in.StorageClassAnnotations, &out.StorageClassAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClassParameters.
+func (in *StorageClassParameters) DeepCopy() *StorageClassParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClassParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UsageMonitoring) DeepCopyInto(out *UsageMonitoring) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageMonitoring.
+func (in *UsageMonitoring) DeepCopy() *UsageMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(UsageMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesReference) DeepCopyInto(out *ValuesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesReference.
+func (in *ValuesReference) DeepCopy() *ValuesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesReference)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -55,12 +55,23 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var syncPeriod time.Duration
+	var maxConcurrentReconciles int
+	var helmConcurrency int
+	var chartCacheDir string
+	var bundledChartPath string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", true,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Minute, "The minimum interval at which watched resources are reconciled (e.g. 15m)")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "The number of NfsProvisioners reconciled in parallel.")
+	flag.IntVar(&helmConcurrency, "helm-concurrency", controller.DefaultHelmConcurrency,
+		"The number of Helm releases of a NfsProvisioner installed, upgraded or uninstalled in parallel. "+
+			"It can be overridden by spec.helmConcurrency.")
+	flag.StringVar(&chartCacheDir, "chart-cache-dir", "", "The directory holding the provisioner chart archives. Defaults to a temporary directory.")
+	flag.StringVar(&bundledChartPath, "bundled-chart", "/charts/nfs-subdir-external-provisioner.tgz",
+		"The provisioner chart archive used when the configured chart is not available. Empty disables the fallback.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -102,13 +113,18 @@ func main() {
 		setupLog.Error(err, "unable to create helm client")
 		os.Exit(1)
 	}
-	fileShareLiseter := gcoreclient.FileShareClient{}
+	fileShareLiseter := gcoreclient.FileShareClient{Client: mgr.GetClient()}
 
 	if err = (&controller.NfsProvisionerReconciler{
-		Client:          mgr.GetClient(),
-		Scheme:          mgr.GetScheme(),
-		HelmClient:      helmClient,
-		FileShareClient: fileShareLiseter,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("nfsprovisioner-controller"),
+		HelmClient:              helmClient,
+		FileShareClient:         fileShareLiseter,
+		HelmConcurrency:         helmConcurrency,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		ChartCacheDir:           chartCacheDir,
+		BundledChartPath:        bundledChartPath,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NfsProvisioner")
 		os.Exit(1)
@@ -117,6 +133,35 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "NfsProvisioner")
 		os.Exit(1)
 	}
+	if err = (&controller.FileShareReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("fileshare-controller"),
+		FileShareClient: fileShareLiseter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FileShare")
+		os.Exit(1)
+	}
+	if err = (&controller.ClaimReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("claim-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PersistentVolumeClaim")
+		os.Exit(1)
+	}
+	if err = (&controller.VolumeReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("volume-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PersistentVolume")
+		os.Exit(1)
+	}
+	if err = (&crdv1.FileShare{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "FileShare")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
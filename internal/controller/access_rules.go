@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Event reasons of the access rules emitted on NfsProvisioner and FileShare resources.
+const (
+	AccessRulesChangedEventReason    = "AccessRulesChanged"
+	AccessRulesSyncFailedEventReason = "AccessRulesSyncFailed"
+)
+
+// accessRuleErrorState is the state of an access rule Gcore Cloud failed to apply, it is replaced.
+const accessRuleErrorState = "error"
+
+// errNoNodeAccessRules is returned when no node IP is found, the access rules are kept rather than
+// revoking the access of all nodes.
+var errNoNodeAccessRules = errors.New("no internal IPv4 address found on the selected nodes")
+
+// isAccessRulesFromNodes reports whether the access rules of the file shares of the provisioner match the nodes.
+func isAccessRulesFromNodes(provisioner *crdv1.NfsProvisioner) bool {
+	return provisioner.Spec.AccessRules != nil && provisioner.Spec.AccessRules.Mode == crdv1.AccessRulesModeFromNodes
+}
+
+// formatAccessRuleAddress returns the IP address of a /32 prefix and the CIDR of the other prefixes.
+func formatAccessRuleAddress(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// formatAccessRules returns the access rules as a comma separated list for events and messages.
+func formatAccessRules(rules []crdv1.AccessRule) string {
+	formatted := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		formatted = append(formatted, fmt.Sprintf("%s (%s)", rule.IPAddress, rule.AccessMode))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// getDesiredAccessRules returns the sorted access rules of the internal IPs of the selected nodes and the extra
+// rules of spec.accessRules. An extra rule takes precedence over a node rule with the same address.
+func getDesiredAccessRules(ctx context.Context, c client.Reader, accessRules *crdv1.AccessRules) ([]crdv1.AccessRule, error) {
+	listOptions := []client.ListOption{}
+	if accessRules.NodeSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(accessRules.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector: %w", err)
+		}
+		listOptions = append(listOptions, client.MatchingLabelsSelector{Selector: selector})
+	}
+	nodeList := corev1.NodeList{}
+	if err := c.List(ctx, &nodeList, listOptions...); err != nil {
+		return nil, err
+	}
+	accessMode := accessRules.AccessMode
+	if accessMode == "" {
+		accessMode = crdv1.AccessModeReadWrite
+	}
+	accessModes := map[string]string{}
+	for _, node := range nodeList.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP {
+				continue
+			}
+			addr, err := netip.ParseAddr(address.Address)
+			if err != nil || !addr.Is4() {
+				continue
+			}
+			bits := addr.BitLen()
+			if accessRules.PrefixLength != nil {
+				bits = int(*accessRules.PrefixLength)
+			}
+			prefix := netip.PrefixFrom(addr, bits).Masked()
+			accessModes[formatAccessRuleAddress(prefix)] = accessMode
+		}
+	}
+	if len(accessModes) == 0 {
+		return nil, errNoNodeAccessRules
+	}
+	for _, rule := range accessRules.ExtraRules {
+		prefix, err := crdv1.ParseAccessRuleAddress(rule.IPAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra rule %s: %w", rule.IPAddress, err)
+		}
+		extraAccessMode := rule.AccessMode
+		if extraAccessMode == "" {
+			extraAccessMode = crdv1.AccessModeReadWrite
+		}
+		accessModes[formatAccessRuleAddress(prefix)] = extraAccessMode
+	}
+	rules := make([]crdv1.AccessRule, 0, len(accessModes))
+	for address, accessMode := range accessModes {
+		rules = append(rules, crdv1.AccessRule{IPAddress: address, AccessMode: accessMode})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].IPAddress < rules[j].IPAddress })
+	return rules, nil
+}
+
+// syncAccessRules makes the access rules of the file share in Gcore Cloud match the desired rules. The missing rules
+// are added before the rules which are not desired are removed, so the clients keep their access when adding a rule
+// fails. A rule of a desired address with another access mode or which failed to apply is removed before its address
+// is added again. It returns the change made, also when the sync failed on the way, or nil when the rules already
+// match.
+func syncAccessRules(ctx context.Context, fileShareClient gcoreclient.FileShareManager, provisioner *crdv1.NfsProvisioner, fileShareID string, desired []crdv1.AccessRule) (*crdv1.AccessRulesChange, error) {
+	start := time.Now()
+	current, err := fileShareClient.ListAccessRules(ctx, provisioner, fileShareID)
+	observeCloudAPIRequest("list_access_rules", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("list access rules: %w", err)
+	}
+	desiredAccessModes := map[string]string{}
+	for _, rule := range desired {
+		desiredAccessModes[rule.IPAddress] = rule.AccessMode
+	}
+	change := crdv1.AccessRulesChange{}
+	changed := func() *crdv1.AccessRulesChange {
+		if len(change.Added) == 0 && len(change.Removed) == 0 {
+			return nil
+		}
+		change.Time = metav1.Now()
+		return &change
+	}
+	deleteRule := func(rule file_shares.AccessRule) error {
+		start := time.Now()
+		err := fileShareClient.DeleteAccessRule(ctx, provisioner, fileShareID, rule.ID)
+		observeCloudAPIRequest("delete_access_rule", start, err)
+		if err != nil && !gcoreclient.IsNotFound(err) {
+			return fmt.Errorf("delete access rule %s: %w", rule.AccessTo, err)
+		}
+		change.Removed = append(change.Removed, crdv1.AccessRule{IPAddress: rule.AccessTo, AccessMode: rule.AccessLevel})
+		return nil
+	}
+	present := StringSet{}
+	var replaced, undesired []file_shares.AccessRule
+	for _, rule := range current {
+		address := rule.AccessTo
+		if prefix, err := crdv1.ParseAccessRuleAddress(rule.AccessTo); err == nil {
+			address = formatAccessRuleAddress(prefix)
+		}
+		if desiredAccessModes[address] == rule.AccessLevel && rule.State != accessRuleErrorState && !present[address] {
+			present[address] = true
+			continue
+		}
+		if accessMode, ok := desiredAccessModes[address]; rule.State == accessRuleErrorState || ok && accessMode != rule.AccessLevel {
+			replaced = append(replaced, rule)
+		} else {
+			undesired = append(undesired, rule)
+		}
+	}
+	// The address of a replaced rule is taken until the rule is removed.
+	for _, rule := range replaced {
+		if err := deleteRule(rule); err != nil {
+			return changed(), err
+		}
+	}
+	for _, rule := range desired {
+		if present[rule.IPAddress] {
+			continue
+		}
+		start := time.Now()
+		_, err := fileShareClient.CreateAccessRule(ctx, provisioner, fileShareID, file_shares.CreateAccessRuleOpts{
+			IPAddress:  rule.IPAddress,
+			AccessMode: rule.AccessMode,
+		})
+		observeCloudAPIRequest("create_access_rule", start, err)
+		if err != nil {
+			return changed(), fmt.Errorf("create access rule %s: %w", rule.IPAddress, err)
+		}
+		present[rule.IPAddress] = true
+		change.Added = append(change.Added, rule)
+	}
+	for _, rule := range undesired {
+		if err := deleteRule(rule); err != nil {
+			return changed(), err
+		}
+	}
+	return changed(), nil
+}
+
+// reconcileAccessRules makes the access rules of the provisioned file shares match the nodes in FromNodes access
+// rules mode, the rules and their last change are reported in the file share statuses. It returns whether the
+// access rules of any file share failed to sync.
+func (r *NfsProvisionerReconciler) reconcileAccessRules(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareStatuses []crdv1.FileShareStatus, previousFileShareStatuses map[string]crdv1.FileShareStatus) bool {
+	log := log.FromContext(ctx)
+
+	if !isAccessRulesFromNodes(provisioner) {
+		meta.RemoveStatusCondition(&provisioner.Status.Conditions, crdv1.AccessRulesSyncedCondition)
+		return false
+	}
+	for i := range fileShareStatuses {
+		previousStatus := previousFileShareStatuses[fileShareStatuses[i].ID]
+		fileShareStatuses[i].AccessRules = previousStatus.AccessRules
+		fileShareStatuses[i].LastAccessRulesChange = previousStatus.LastAccessRulesChange.DeepCopy()
+	}
+	desired, err := getDesiredAccessRules(ctx, r.Client, provisioner.Spec.AccessRules)
+	if err != nil {
+		log.Error(err, "failed get node access rules")
+		r.setCondition(provisioner, crdv1.AccessRulesSyncedCondition, metav1.ConditionFalse, crdv1.AccessRulesSyncFailedReason, err.Error())
+		return true
+	}
+	var failures []string
+	for i := range fileShareStatuses {
+		fileShareStatus := &fileShareStatuses[i]
+		// The access rules of a file share claimed by another provisioner are left to it.
+		if fileShareStatus.ConnectionPoint == "" || fileShareStatus.ClaimedBy != "" {
+			continue
+		}
+		change, err := syncAccessRules(ctx, r.FileShareClient, provisioner, fileShareStatus.ID, desired)
+		if change != nil {
+			log.Info("Changed access rules of file share", "fileShare", fileShareStatus.Name,
+				"added", formatAccessRules(change.Added), "removed", formatAccessRules(change.Removed))
+			r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, AccessRulesChangedEventReason,
+				"Access rules of file share %s changed, added: [%s], removed: [%s]",
+				fileShareStatus.Name, formatAccessRules(change.Added), formatAccessRules(change.Removed))
+			fileShareStatus.LastAccessRulesChange = change
+		}
+		if err != nil {
+			log.Error(err, "failed sync access rules", "fileShare", fileShareStatus.Name)
+			r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, AccessRulesSyncFailedEventReason,
+				"Failed to sync access rules of file share %s: %v", fileShareStatus.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", fileShareStatus.Name, err))
+			continue
+		}
+		fileShareStatus.AccessRules = desired
+	}
+	if len(failures) > 0 {
+		r.setCondition(provisioner, crdv1.AccessRulesSyncedCondition, metav1.ConditionFalse, crdv1.AccessRulesSyncFailedReason,
+			strings.Join(failures, "; "))
+		return true
+	}
+	r.setCondition(provisioner, crdv1.AccessRulesSyncedCondition, metav1.ConditionTrue, crdv1.AccessRulesSyncedReason, "")
+	return false
+}
+
+// nodeAccessChangedPredicate filters the Node events to those changing the nodes allowed to access the file shares.
+var nodeAccessChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		return !reflect.DeepEqual(oldNode.Labels, newNode.Labels) || !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses)
+	},
+}
+
+// findProvisionersForNode maps a Node to the NfsProvisioners in FromNodes access rules mode.
+func (r *NfsProvisionerReconciler) findProvisionersForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	provisionerList := crdv1.NfsProvisionerList{}
+	if err := r.Client.List(ctx, &provisionerList); err != nil {
+		log.FromContext(ctx).Error(err, "failed list provisioners for node", "node", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range provisionerList.Items {
+		if isAccessRulesFromNodes(&provisionerList.Items[i]) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: provisionerList.Items[i].Namespace, Name: provisionerList.Items[i].Name},
+			})
+		}
+	}
+	return requests
+}
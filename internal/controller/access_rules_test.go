@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"errors"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Access rules", func() {
+	It("Access rules of file shares should follow the node IPs", func() {
+		nodeLabels := map[string]string{"gcore-sfs-controller.io/access-rules-test": "true"}
+		nodes := []corev1.Node{}
+		for name, address := range map[string]string{"access-rules-1": "10.10.0.11", "access-rules-2": "10.10.0.12"} {
+			node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}}
+			Expect(k8sClient.Create(ctx, &node)).To(Succeed())
+			node.Status.Addresses = []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: address},
+				{Type: corev1.NodeInternalIP, Address: "fd00::11"},
+				{Type: corev1.NodeHostName, Address: name},
+			}
+			Expect(k8sClient.Status().Update(ctx, &node)).To(Succeed())
+			nodes = append(nodes, node)
+		}
+
+		fileShare := file_shares.FileShare{
+			ID:              "8d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a",
+			Name:            "access-rules",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            10,
+			ConnectionPoint: "10.33.20.95:/shares/share-8d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a",
+		}
+		fileShareClient := gcoreclient.MockFileShareManager{
+			FileShares: []file_shares.FileShare{fileShare},
+			AccessRules: map[string][]file_shares.AccessRule{fileShare.ID: {
+				{ID: "stale", State: "active", AccessTo: "10.10.0.9", AccessLevel: crdv1.AccessModeReadWrite},
+				{ID: "kept", State: "active", AccessTo: "10.10.0.11", AccessLevel: crdv1.AccessModeReadWrite},
+			}},
+		}
+		recorder := record.NewFakeRecorder(10)
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: recorder, FileShareClient: &fileShareClient}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "access-rules", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{AccessRules: &crdv1.AccessRules{
+				Mode:         crdv1.AccessRulesModeFromNodes,
+				NodeSelector: &metav1.LabelSelector{MatchLabels: nodeLabels},
+				ExtraRules:   []crdv1.AccessRule{{IPAddress: "192.168.10.0/24", AccessMode: crdv1.AccessModeReadOnly}},
+			}},
+		}
+		fileShareStatuses := func() []crdv1.FileShareStatus {
+			return []crdv1.FileShareStatus{{
+				ID:              fileShare.ID,
+				Name:            fileShare.Name,
+				ConnectionPoint: fileShare.ConnectionPoint,
+				Size:            fileShare.Size,
+				CloudStatus:     fileShare.Status,
+			}}
+		}
+
+		// The stale rule is removed and the missing node and extra rules are added
+		statuses := fileShareStatuses()
+		Expect(reconciler.reconcileAccessRules(ctx, &provisioner, statuses, nil)).To(BeFalse())
+		Expect(recorder.Events).To(Receive(ContainSubstring(AccessRulesChangedEventReason)))
+		Expect(statuses[0].AccessRules).To(Equal([]crdv1.AccessRule{
+			{IPAddress: "10.10.0.11", AccessMode: crdv1.AccessModeReadWrite},
+			{IPAddress: "10.10.0.12", AccessMode: crdv1.AccessModeReadWrite},
+			{IPAddress: "192.168.10.0/24", AccessMode: crdv1.AccessModeReadOnly},
+		}))
+		change := statuses[0].LastAccessRulesChange
+		Expect(change).NotTo(BeNil())
+		Expect(change.Removed).To(Equal([]crdv1.AccessRule{{IPAddress: "10.10.0.9", AccessMode: crdv1.AccessModeReadWrite}}))
+		Expect(change.Added).To(HaveLen(2))
+		Expect(fileShareClient.AccessRules[fileShare.ID]).To(HaveLen(3))
+		Expect(fileShareClient.AccessRules[fileShare.ID][0].ID).To(Equal("kept"))
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.AccessRulesSyncedCondition)).To(BeTrue())
+
+		// The rules in sync are not changed and the last change is kept
+		previousFileShareStatuses := map[string]crdv1.FileShareStatus{fileShare.ID: statuses[0]}
+		statuses = fileShareStatuses()
+		Expect(reconciler.reconcileAccessRules(ctx, &provisioner, statuses, previousFileShareStatuses)).To(BeFalse())
+		Expect(recorder.Events).NotTo(Receive())
+		Expect(statuses[0].LastAccessRulesChange.Removed).To(HaveLen(1))
+
+		// The node rules are replaced by the CIDR of the nodes with spec.accessRules.prefixLength
+		prefixLength := int32(24)
+		provisioner.Spec.AccessRules.PrefixLength = &prefixLength
+		desired, err := getDesiredAccessRules(ctx, k8sClient, provisioner.Spec.AccessRules)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(desired).To(Equal([]crdv1.AccessRule{
+			{IPAddress: "10.10.0.0/24", AccessMode: crdv1.AccessModeReadWrite},
+			{IPAddress: "192.168.10.0/24", AccessMode: crdv1.AccessModeReadOnly},
+		}))
+
+		// The rules are kept when no node matches the selector
+		provisioner.Spec.AccessRules.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"missing": "true"}}
+		Expect(reconciler.reconcileAccessRules(ctx, &provisioner, fileShareStatuses(), previousFileShareStatuses)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(provisioner.Status.Conditions, crdv1.AccessRulesSyncedCondition)).To(BeTrue())
+		Expect(fileShareClient.AccessRules[fileShare.ID]).To(HaveLen(3))
+
+		// The condition is removed in Manual access rules mode
+		provisioner.Spec.AccessRules.Mode = crdv1.AccessRulesModeManual
+		Expect(reconciler.reconcileAccessRules(ctx, &provisioner, fileShareStatuses(), nil)).To(BeFalse())
+		Expect(meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.AccessRulesSyncedCondition)).To(BeNil())
+		for i := range nodes {
+			Expect(k8sClient.Delete(ctx, &nodes[i])).To(Succeed())
+		}
+	})
+
+	It("Failing access rule creation should keep the rules of the clients", func() {
+		fileShare := file_shares.FileShare{
+			ID:              "9e2f3a4b-5c6d-4e7f-8a9b-0c1d2e3f4a5b",
+			Name:            "access-rules-failure",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            10,
+			ConnectionPoint: "10.33.20.95:/shares/share-9e2f3a4b-5c6d-4e7f-8a9b-0c1d2e3f4a5b",
+		}
+		fileShareClient := gcoreclient.MockFileShareManager{
+			FileShares: []file_shares.FileShare{fileShare},
+			AccessRules: map[string][]file_shares.AccessRule{fileShare.ID: {
+				{ID: "stale", State: "active", AccessTo: "10.10.0.9", AccessLevel: crdv1.AccessModeReadWrite},
+				{ID: "read-only", State: "active", AccessTo: "10.10.0.11", AccessLevel: crdv1.AccessModeReadOnly},
+			}},
+			CreateAccessRuleErr: errors.New("quota exceeded"),
+		}
+		provisioner := crdv1.NfsProvisioner{ObjectMeta: metav1.ObjectMeta{Name: "access-rules-failure", Namespace: DefaultNamespace}}
+		desired := []crdv1.AccessRule{
+			{IPAddress: "10.10.0.11", AccessMode: crdv1.AccessModeReadWrite},
+			{IPAddress: "10.10.0.12", AccessMode: crdv1.AccessModeReadWrite},
+		}
+
+		// The rule with another access mode is removed first, the stale rule is kept while the creation fails
+		change, err := syncAccessRules(ctx, &fileShareClient, &provisioner, fileShare.ID, desired)
+		Expect(err).To(HaveOccurred())
+		Expect(change).NotTo(BeNil())
+		Expect(change.Removed).To(Equal([]crdv1.AccessRule{{IPAddress: "10.10.0.11", AccessMode: crdv1.AccessModeReadOnly}}))
+		Expect(change.Added).To(BeEmpty())
+		Expect(fileShareClient.AccessRules[fileShare.ID]).To(HaveLen(1))
+		Expect(fileShareClient.AccessRules[fileShare.ID][0].ID).To(Equal("stale"))
+
+		// The stale rule is removed once the desired rules are added
+		fileShareClient.CreateAccessRuleErr = nil
+		change, err = syncAccessRules(ctx, &fileShareClient, &provisioner, fileShare.ID, desired)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(change.Added).To(Equal(desired))
+		Expect(change.Removed).To(Equal([]crdv1.AccessRule{{IPAddress: "10.10.0.9", AccessMode: crdv1.AccessModeReadWrite}}))
+		Expect(fileShareClient.AccessRules[fileShare.ID]).To(HaveLen(2))
+	})
+})
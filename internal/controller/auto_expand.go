@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/task/v1/tasks"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// autoExpandInterval is the delay before checking again whether the file shares have to be extended
+	// by spec.autoExpand, volumes and claims are not watched.
+	autoExpandInterval = 5 * time.Minute
+	// defaultAutoExpandThresholdPercent is the default spec.autoExpand.thresholdPercent.
+	defaultAutoExpandThresholdPercent = 80
+	// autoExpandRetryDelay is the delay before a failed extension is retried, unless the claims request
+	// another size.
+	autoExpandRetryDelay = 30 * time.Minute
+)
+
+// Event reasons of the file share extensions emitted on NfsProvisioner resources.
+const (
+	FileShareExtendingEventReason    = "FileShareExtending"
+	FileShareExtendedEventReason     = "FileShareExtended"
+	FileShareExtendFailedEventReason = "FileShareExtendFailed"
+)
+
+// getRequestedSizes returns the storage in GiB requested by the claims of the file share storage classes
+// by file share ID, including the retained storage classes.
+func (r *NfsProvisionerReconciler) getRequestedSizes(ctx context.Context, fileShareStatuses []crdv1.FileShareStatus) (map[string]int, error) {
+	storageClassFileShares := map[string]string{}
+	for _, fileShareStatus := range fileShareStatuses {
+		if fileShareStatus.ClaimedBy != "" {
+			continue
+		}
+		storageClassFileShares[fileShareStatus.StorageClassName] = fileShareStatus.ID
+		for _, storageClassName := range fileShareStatus.RetainedStorageClassNames {
+			storageClassFileShares[storageClassName] = fileShareStatus.ID
+		}
+	}
+	claimList := corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, &claimList); err != nil {
+		return nil, err
+	}
+	requestedBytes := map[string]int64{}
+	for _, claim := range claimList.Items {
+		if claim.Spec.StorageClassName == nil || !claim.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if fileShareID, found := storageClassFileShares[*claim.Spec.StorageClassName]; found {
+			requestedBytes[fileShareID] += claim.Spec.Resources.Requests.Storage().Value()
+		}
+	}
+	requestedSizes := map[string]int{}
+	for fileShareID, bytes := range requestedBytes {
+		requestedSizes[fileShareID] = int((bytes + (1 << 30) - 1) >> 30)
+	}
+	return requestedSizes, nil
+}
+
+// getAutoExpandSize returns the size in GiB the file share is extended to, so the requested storage is at
+// the threshold of spec.autoExpand, or 0 when the file share is not extended.
+func getAutoExpandSize(autoExpand *crdv1.AutoExpand, size int, requestedSize int) int {
+	thresholdPercent := int(autoExpand.ThresholdPercent)
+	if thresholdPercent == 0 {
+		thresholdPercent = defaultAutoExpandThresholdPercent
+	}
+	if requestedSize*100 <= size*thresholdPercent {
+		return 0
+	}
+	newSize := (requestedSize*100 + thresholdPercent - 1) / thresholdPercent
+	if newSize > autoExpand.MaxSize {
+		newSize = autoExpand.MaxSize
+	}
+	if newSize <= size {
+		return 0
+	}
+	return newSize
+}
+
+// autoExpandFileShares reports the storage requested by the claims of the file shares and extends the file
+// shares passing the threshold of spec.autoExpand. The running extensions are polled, the returned duration
+// is the delay before the file shares are checked again.
+func (r *NfsProvisionerReconciler) autoExpandFileShares(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareStatuses []crdv1.FileShareStatus, previousFileShareStatuses map[string]crdv1.FileShareStatus) (time.Duration, error) {
+	requestedSizes, err := r.getRequestedSizes(ctx, fileShareStatuses)
+	if err != nil {
+		return 0, err
+	}
+	autoExpand := provisioner.Spec.AutoExpand
+	var requeueAfter time.Duration
+	if autoExpand != nil {
+		requeueAfter = autoExpandInterval
+	}
+	for i := range fileShareStatuses {
+		fileShareStatus := &fileShareStatuses[i]
+		fileShareStatus.RequestedSize = requestedSizes[fileShareStatus.ID]
+		fileShareStatus.LastResize = previousFileShareStatuses[fileShareStatus.ID].LastResize.DeepCopy()
+		if autoExpand == nil {
+			continue
+		}
+		if fileShareStatus.LastResize != nil && fileShareStatus.LastResize.State == crdv1.FileShareResizeRunning {
+			// The extensions started for the other file shares are still recorded when the task cannot be polled.
+			if err := r.pollFileShareResize(ctx, provisioner, fileShareStatus); err != nil {
+				log.FromContext(ctx).Error(err, "failed poll file share extension", "fileShare", fileShareStatus.Name)
+			}
+			if fileShareStatus.LastResize.State == crdv1.FileShareResizeRunning {
+				requeueAfter = fileShareTaskPollInterval
+			}
+			continue
+		}
+		if fileShareStatus.ConnectionPoint == "" || fileShareStatus.CloudStatus != fileShareAvailableStatus {
+			continue
+		}
+		newSize := getAutoExpandSize(autoExpand, fileShareStatus.Size, fileShareStatus.RequestedSize)
+		if newSize == 0 || isAutoExpandRetryDelayed(fileShareStatus) {
+			continue
+		}
+		if r.extendFileShare(ctx, provisioner, fileShareStatus, newSize) {
+			requeueAfter = fileShareTaskPollInterval
+		}
+	}
+	return requeueAfter, nil
+}
+
+// isAutoExpandRetryDelayed reports whether the last extension of the file share failed less than
+// autoExpandRetryDelay ago for the same requested storage.
+func isAutoExpandRetryDelayed(fileShareStatus *crdv1.FileShareStatus) bool {
+	lastResize := fileShareStatus.LastResize
+	if lastResize == nil || lastResize.State != crdv1.FileShareResizeFailed || lastResize.CompletionTime == nil {
+		return false
+	}
+	return lastResize.RequestedSize == fileShareStatus.RequestedSize &&
+		time.Since(lastResize.CompletionTime.Time) < autoExpandRetryDelay
+}
+
+// extendFileShare starts the extension of the file share to newSize and records it in the file share status,
+// it returns whether the extension is running.
+func (r *NfsProvisionerReconciler) extendFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareStatus *crdv1.FileShareStatus, newSize int) bool {
+	log := log.FromContext(ctx)
+
+	resize := &crdv1.FileShareResize{
+		FromSize:      fileShareStatus.Size,
+		ToSize:        newSize,
+		RequestedSize: fileShareStatus.RequestedSize,
+		StartTime:     metav1.Now(),
+	}
+	fileShareStatus.LastResize = resize
+	start := time.Now()
+	taskID, err := r.FileShareClient.ExtendFileShare(ctx, provisioner, fileShareStatus.ID, newSize)
+	observeCloudAPIRequest("extend_file_share", start, err)
+	if err != nil {
+		log.Error(err, "failed extend file share", "fileShare", fileShareStatus.Name, "size", fileShareStatus.Size, "newSize", newSize)
+		r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, FileShareExtendFailedEventReason,
+			"Failed to extend file share %s to %d GiB: %v", fileShareStatus.Name, newSize, err)
+		resize.State = crdv1.FileShareResizeFailed
+		resize.Message = err.Error()
+		resize.CompletionTime = &resize.StartTime
+		return false
+	}
+	log.Info("Extending file share", "fileShare", fileShareStatus.Name, "size", fileShareStatus.Size, "newSize", newSize,
+		"requestedSize", fileShareStatus.RequestedSize, "taskID", taskID)
+	r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, FileShareExtendingEventReason,
+		"Extending file share %s from %d GiB to %d GiB, claims request %d GiB", fileShareStatus.Name, fileShareStatus.Size, newSize, fileShareStatus.RequestedSize)
+	resize.TaskID = taskID
+	resize.State = crdv1.FileShareResizeRunning
+	return true
+}
+
+// pollFileShareResize updates the running extension of the file share status from its task.
+func (r *NfsProvisionerReconciler) pollFileShareResize(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareStatus *crdv1.FileShareStatus) error {
+	resize := fileShareStatus.LastResize
+	start := time.Now()
+	task, err := r.FileShareClient.GetTask(ctx, provisioner, resize.TaskID)
+	observeCloudAPIRequest("get_task", start, err)
+	now := metav1.Now()
+	if gcoreclient.IsNotFound(err) {
+		resize.State = crdv1.FileShareResizeFailed
+		resize.CompletionTime = &now
+		resize.Message = fmt.Sprintf("Task %s not found", resize.TaskID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get task %s extending file share %s: %w", resize.TaskID, fileShareStatus.Name, err)
+	}
+	switch task.State {
+	case tasks.TaskStateFinished:
+		resize.State = crdv1.FileShareResizeSucceeded
+		resize.CompletionTime = &now
+		fileShareStatus.Size = resize.ToSize
+		r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, FileShareExtendedEventReason,
+			"Extended file share %s to %d GiB", fileShareStatus.Name, resize.ToSize)
+	case tasks.TaskStateError:
+		resize.State = crdv1.FileShareResizeFailed
+		resize.CompletionTime = &now
+		resize.Message = fmt.Sprintf("Task %s failed", resize.TaskID)
+		if task.Error != nil {
+			resize.Message = fmt.Sprintf("Task %s failed: %s", resize.TaskID, *task.Error)
+		}
+		r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, FileShareExtendFailedEventReason,
+			"Failed to extend file share %s to %d GiB: %s", fileShareStatus.Name, resize.ToSize, resize.Message)
+	}
+	return nil
+}
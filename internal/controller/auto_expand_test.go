@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Auto expand", func() {
+	It("Auto expand size should keep the requested storage at the threshold", func() {
+		autoExpand := crdv1.AutoExpand{MaxSize: 100}
+		Expect(getAutoExpandSize(&autoExpand, 10, 8)).To(Equal(0))
+		Expect(getAutoExpandSize(&autoExpand, 10, 9)).To(Equal(12))
+		Expect(getAutoExpandSize(&autoExpand, 10, 90)).To(Equal(100))
+		Expect(getAutoExpandSize(&autoExpand, 100, 90)).To(Equal(0))
+		autoExpand.ThresholdPercent = 50
+		Expect(getAutoExpandSize(&autoExpand, 10, 6)).To(Equal(12))
+	})
+	It("File share should be extended when its claims pass the threshold", func() {
+		fileShare := file_shares.FileShare{
+			ID:              "3f0c7a2e-6b1d-4c8e-9a5f-1d2e3c4b5a69",
+			Name:            "auto-expand",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            10,
+			ConnectionPoint: "10.33.20.94:/shares/share-3f0c7a2e-6b1d-4c8e-9a5f-1d2e3c4b5a69",
+		}
+		fileShareClient := gcoreclient.MockFileShareManager{FileShares: []file_shares.FileShare{fileShare}, KeepTasksRunning: true}
+		recorder := record.NewFakeRecorder(10)
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: recorder, FileShareClient: &fileShareClient}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "auto-expand", Namespace: DefaultNamespace},
+			Spec:       crdv1.NfsProvisionerSpec{AutoExpand: &crdv1.AutoExpand{ThresholdPercent: 80, MaxSize: 20}},
+		}
+		storageClassName := "nfs-auto-expand"
+		claims := []corev1.PersistentVolumeClaim{}
+		for _, name := range []string{"auto-expand-1", "auto-expand-2"} {
+			claim := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: DefaultNamespace},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClassName,
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("4500Mi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &claim)).To(Succeed())
+			claims = append(claims, claim)
+		}
+		fileShareStatuses := func() []crdv1.FileShareStatus {
+			return []crdv1.FileShareStatus{{
+				ID:               fileShare.ID,
+				Name:             fileShare.Name,
+				ConnectionPoint:  fileShare.ConnectionPoint,
+				Size:             fileShare.Size,
+				CloudStatus:      fileShare.Status,
+				StorageClassName: storageClassName,
+			}}
+		}
+
+		// 9 GiB are requested from the 10 GiB file share
+		statuses := fileShareStatuses()
+		requeueAfter, err := reconciler.autoExpandFileShares(ctx, &provisioner, statuses, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(fileShareTaskPollInterval))
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareExtendingEventReason)))
+		Expect(statuses[0].RequestedSize).To(Equal(9))
+		lastResize := statuses[0].LastResize
+		Expect(lastResize).NotTo(BeNil())
+		Expect(lastResize.State).To(Equal(crdv1.FileShareResizeRunning))
+		Expect(lastResize.FromSize).To(Equal(10))
+		Expect(lastResize.ToSize).To(Equal(12))
+		Expect(lastResize.TaskID).NotTo(BeEmpty())
+
+		// The running extension is polled until its task is finished
+		previousFileShareStatuses := map[string]crdv1.FileShareStatus{fileShare.ID: statuses[0]}
+		fileShareClient.FinishTasks()
+		statuses = fileShareStatuses()
+		requeueAfter, err = reconciler.autoExpandFileShares(ctx, &provisioner, statuses, previousFileShareStatuses)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(autoExpandInterval))
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareExtendedEventReason)))
+		Expect(statuses[0].Size).To(Equal(12))
+		Expect(statuses[0].LastResize.State).To(Equal(crdv1.FileShareResizeSucceeded))
+		Expect(statuses[0].LastResize.CompletionTime).NotTo(BeNil())
+		Expect(fileShareClient.FileShares[0].Size).To(Equal(12))
+
+		// A failed extension is retried after a delay or when the claims request another size
+		fileShareClient.FileShares = nil
+		statuses = fileShareStatuses()
+		_, err = reconciler.autoExpandFileShares(ctx, &provisioner, statuses, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareExtendFailedEventReason)))
+		Expect(statuses[0].LastResize.State).To(Equal(crdv1.FileShareResizeFailed))
+		previousFileShareStatuses = map[string]crdv1.FileShareStatus{fileShare.ID: statuses[0]}
+		statuses = fileShareStatuses()
+		_, err = reconciler.autoExpandFileShares(ctx, &provisioner, statuses, previousFileShareStatuses)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).NotTo(Receive())
+		Expect(statuses[0].LastResize.State).To(Equal(crdv1.FileShareResizeFailed))
+		failedResize := previousFileShareStatuses[fileShare.ID]
+		completionTime := metav1.NewTime(time.Now().Add(-autoExpandRetryDelay))
+		failedResize.LastResize.CompletionTime = &completionTime
+		previousFileShareStatuses[fileShare.ID] = failedResize
+		statuses = fileShareStatuses()
+		_, err = reconciler.autoExpandFileShares(ctx, &provisioner, statuses, previousFileShareStatuses)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareExtendFailedEventReason)))
+
+		// File shares are not extended without spec.autoExpand
+		provisioner.Spec.AutoExpand = nil
+		requeueAfter, err = reconciler.autoExpandFileShares(ctx, &provisioner, fileShareStatuses(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeZero())
+		Expect(recorder.Events).NotTo(Receive())
+		for i := range claims {
+			Expect(k8sClient.Delete(ctx, &claims[i])).To(Succeed())
+		}
+	})
+})
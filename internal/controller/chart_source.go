@@ -0,0 +1,413 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Charts are resolved from the following sources, the first configured source is used:
+//  1. spec.chartConfigMapRef, a chart archive stored in a ConfigMap,
+//  2. spec.chartURL, a chart archive downloaded from the URL,
+//  3. spec.helmRepository, an OCI registry (oci://) or an HTTP chart repository.
+// When the configured source is not available the chart bundled into the controller image is used.
+
+// resolvedChart is the provisioner chart archive passed to Helm.
+type resolvedChart struct {
+	// Name is the path of the chart archive.
+	Name string
+	// Digest is the sha256 digest of the chart archive.
+	Digest string
+}
+
+// chartRepositoryAuth holds the credentials and the CA certificate file of a chart source.
+type chartRepositoryAuth struct {
+	Username string
+	Password string
+	CAFile   string
+}
+
+// resolveChart returns the provisioner chart, falling back to the bundled chart when the configured source fails.
+// The availability of the configured source is reported by the ChartSourceAvailable condition.
+func (r *NfsProvisionerReconciler) resolveChart(ctx context.Context, provisioner *crdv1.NfsProvisioner) (*resolvedChart, error) {
+	log := log.FromContext(ctx)
+
+	chart, err := r.resolveConfiguredChart(ctx, provisioner)
+	if err == nil {
+		r.setCondition(provisioner, crdv1.ChartSourceAvailableCondition, metav1.ConditionTrue, crdv1.ChartResolvedReason, "")
+		return chart, nil
+	}
+	r.setCondition(provisioner, crdv1.ChartSourceAvailableCondition, metav1.ConditionFalse, crdv1.ChartUnavailableReason, err.Error())
+	if r.BundledChartPath == "" {
+		return nil, err
+	}
+	bundledChart, bundledErr := r.getBundledChart(provisioner)
+	if bundledErr != nil {
+		log.Error(bundledErr, "bundled chart is not available", "path", r.BundledChartPath)
+		return nil, err
+	}
+	log.Error(err, "chart is not available, using the bundled chart", "path", r.BundledChartPath)
+	r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, BundledChartUsedEventReason,
+		"Chart is not available, using the bundled chart: %v", err)
+	r.setCondition(provisioner, crdv1.ChartSourceAvailableCondition, metav1.ConditionFalse, crdv1.BundledChartUsedReason,
+		fmt.Sprintf("Using the bundled chart: %v", err))
+	return bundledChart, nil
+}
+
+func (r *NfsProvisionerReconciler) resolveConfiguredChart(ctx context.Context, provisioner *crdv1.NfsProvisioner) (*resolvedChart, error) {
+	auth, err := r.getChartRepositoryAuth(ctx, provisioner)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case provisioner.Spec.ChartConfigMapRef != nil:
+		data, err := r.getChartFromConfigMap(ctx, provisioner)
+		if err != nil {
+			return nil, err
+		}
+		return r.writeChartArchive(provisioner, data)
+	case provisioner.Spec.ChartURL != "":
+		data, err := downloadChart(provisioner.Spec.ChartURL, auth)
+		if err != nil {
+			return nil, err
+		}
+		return r.writeChartArchive(provisioner, data)
+	case registry.IsOCI(provisioner.Spec.HelmRepository):
+		data, err := r.pullOCIChart(provisioner, auth)
+		if err != nil {
+			return nil, err
+		}
+		return r.writeChartArchive(provisioner, data)
+	}
+	return r.resolveRepositoryChart(provisioner, auth)
+}
+
+// getRepositoryName returns the name of the chart repository entry of the provisioner.
+func getRepositoryName(provisioner *crdv1.NfsProvisioner) string {
+	return fmt.Sprintf("%s-%s", provisioner.Namespace, provisioner.Name)
+}
+
+// resolveRepositoryChart downloads the latest chart version satisfying the chart version constraint from the
+// HTTP chart repository. Every provisioner has its own repository entry and index cache, so provisioners using
+// different repositories do not interfere.
+func (r *NfsProvisionerReconciler) resolveRepositoryChart(provisioner *crdv1.NfsProvisioner, auth chartRepositoryAuth) (*resolvedChart, error) {
+	cacheDir := r.getChartCacheDir(provisioner)
+	entry := repo.Entry{
+		Name:     getRepositoryName(provisioner),
+		URL:      provisioner.Spec.HelmRepository,
+		Username: auth.Username,
+		Password: auth.Password,
+		CAFile:   auth.CAFile,
+	}
+	chartRepo, err := repo.NewChartRepository(&entry, getter.All(cli.New()))
+	if err != nil {
+		return nil, err
+	}
+	chartRepo.CachePath = cacheDir
+	indexPath, err := chartRepo.DownloadIndexFile()
+	if err != nil {
+		return nil, fmt.Errorf("download index of chart repository %s: %w", entry.URL, err)
+	}
+	index, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("load index of chart repository %s: %w", entry.URL, err)
+	}
+	chartVersion, err := index.Get(provisioner.Spec.ChartName, provisioner.Spec.ChartVersion)
+	if err != nil {
+		return nil, fmt.Errorf("find chart %s %s in repository %s: %w", provisioner.Spec.ChartName, provisioner.Spec.ChartVersion, entry.URL, err)
+	}
+	if len(chartVersion.URLs) == 0 {
+		return nil, fmt.Errorf("chart %s %s in repository %s has no URL", chartVersion.Name, chartVersion.Version, entry.URL)
+	}
+	// The index digest is the sha256 digest of the chart archive, a cached archive is not downloaded again.
+	if chartVersion.Digest != "" {
+		chartPath := filepath.Join(cacheDir, fmt.Sprintf("%s.tgz", chartVersion.Digest))
+		if _, err := os.Stat(chartPath); err == nil {
+			return &resolvedChart{Name: chartPath, Digest: chartVersion.Digest}, nil
+		}
+	}
+	chartURL, err := repo.ResolveReferenceURL(entry.URL, chartVersion.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	// The index may point to another host, the credentials are only passed to the repository host like Helm does.
+	if sameHost, err := isSameURLHost(entry.URL, chartURL); err != nil {
+		return nil, err
+	} else if !sameHost {
+		auth.Username, auth.Password = "", ""
+	}
+	data, err := downloadChart(chartURL, auth)
+	if err != nil {
+		return nil, err
+	}
+	return r.writeChartArchive(provisioner, data)
+}
+
+// getChartCacheDir returns the directory holding the chart archives and CA certificate of the provisioner.
+func (r *NfsProvisionerReconciler) getChartCacheDir(provisioner *crdv1.NfsProvisioner) string {
+	cacheDir := r.ChartCacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "gcore-sfs-controller")
+	}
+	return filepath.Join(cacheDir, provisioner.Namespace, provisioner.Name)
+}
+
+// removeChartCache removes the chart cache directory of a deleted provisioner with its chart archives,
+// CA certificate and registry credentials.
+func (r *NfsProvisionerReconciler) removeChartCache(provisioner *crdv1.NfsProvisioner) error {
+	return os.RemoveAll(r.getChartCacheDir(provisioner))
+}
+
+// getChartRepositoryAuth reads the chart source credentials from spec.helmRepositorySecretRef,
+// the CA certificate is written to the provisioner chart cache directory.
+func (r *NfsProvisionerReconciler) getChartRepositoryAuth(ctx context.Context, provisioner *crdv1.NfsProvisioner) (chartRepositoryAuth, error) {
+	auth := chartRepositoryAuth{}
+	if provisioner.Spec.HelmRepositorySecretRef == nil {
+		return auth, nil
+	}
+	name := types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Spec.HelmRepositorySecretRef.Name}
+	secret := corev1.Secret{}
+	if err := r.Client.Get(ctx, name, &secret); err != nil {
+		return auth, fmt.Errorf("get chart repository secret %s: %w", name, err)
+	}
+	auth.Username = string(secret.Data[crdv1.HelmRepositoryUsernameKey])
+	auth.Password = string(secret.Data[crdv1.HelmRepositoryPasswordKey])
+	if ca, found := secret.Data[crdv1.HelmRepositoryCAKey]; found {
+		auth.CAFile = filepath.Join(r.getChartCacheDir(provisioner), crdv1.HelmRepositoryCAKey)
+		if err := writeFileIfChanged(auth.CAFile, ca); err != nil {
+			return auth, err
+		}
+	}
+	return auth, nil
+}
+
+func (r *NfsProvisionerReconciler) getChartFromConfigMap(ctx context.Context, provisioner *crdv1.NfsProvisioner) ([]byte, error) {
+	chartRef := provisioner.Spec.ChartConfigMapRef
+	key := chartRef.Key
+	if key == "" {
+		key = crdv1.DefaultChartKey
+	}
+	name := types.NamespacedName{Namespace: provisioner.Namespace, Name: chartRef.Name}
+	configMap := corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, name, &configMap); err != nil {
+		return nil, fmt.Errorf("get chart configmap %s: %w", name, err)
+	}
+	data, found := configMap.BinaryData[key]
+	if !found {
+		return nil, fmt.Errorf("chart configmap %s has no binary data key %s", name, key)
+	}
+	return data, nil
+}
+
+// isSameURLHost returns whether both URLs have the same scheme and host.
+func isSameURLHost(first, second string) (bool, error) {
+	firstURL, err := url.Parse(first)
+	if err != nil {
+		return false, err
+	}
+	secondURL, err := url.Parse(second)
+	if err != nil {
+		return false, err
+	}
+	return firstURL.Scheme == secondURL.Scheme && firstURL.Host == secondURL.Host, nil
+}
+
+// downloadChart downloads the chart archive from the URL.
+func downloadChart(chartURL string, auth chartRepositoryAuth) ([]byte, error) {
+	options := []getter.Option{getter.WithURL(chartURL)}
+	if auth.Username != "" || auth.Password != "" {
+		options = append(options, getter.WithBasicAuth(auth.Username, auth.Password))
+	}
+	if auth.CAFile != "" {
+		options = append(options, getter.WithTLSClientConfig("", "", auth.CAFile))
+	}
+	httpGetter, err := getter.NewHTTPGetter(options...)
+	if err != nil {
+		return nil, err
+	}
+	data, err := httpGetter.Get(chartURL)
+	if err != nil {
+		return nil, fmt.Errorf("download chart %s: %w", chartURL, err)
+	}
+	return data.Bytes(), nil
+}
+
+// pullOCIChart pulls the latest chart version satisfying the chart version constraint from the OCI registry.
+func (r *NfsProvisionerReconciler) pullOCIChart(provisioner *crdv1.NfsProvisioner, auth chartRepositoryAuth) ([]byte, error) {
+	ref := fmt.Sprintf("%s/%s",
+		strings.TrimSuffix(strings.TrimPrefix(provisioner.Spec.HelmRepository, fmt.Sprintf("%s://", registry.OCIScheme)), "/"),
+		provisioner.Spec.ChartName)
+	options := []registry.ClientOption{
+		registry.ClientOptCredentialsFile(filepath.Join(r.getChartCacheDir(provisioner), "registry.json")),
+	}
+	if auth.CAFile != "" {
+		tlsConfig, err := newCATLSConfig(auth.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, registry.ClientOptHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		}))
+	}
+	registryClient, err := registry.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Username != "" || auth.Password != "" {
+		host := strings.SplitN(ref, "/", 2)[0]
+		loginOptions := []registry.LoginOption{registry.LoginOptBasicAuth(auth.Username, auth.Password)}
+		if auth.CAFile != "" {
+			loginOptions = append(loginOptions, registry.LoginOptTLSClientConfig("", "", auth.CAFile))
+		}
+		if err := registryClient.Login(host, loginOptions...); err != nil {
+			return nil, fmt.Errorf("login to registry %s: %w", host, err)
+		}
+	}
+	tags, err := registryClient.Tags(ref)
+	if err != nil {
+		return nil, fmt.Errorf("list tags of chart %s: %w", ref, err)
+	}
+	tag, err := registry.GetTagMatchingVersionOrConstraint(tags, provisioner.Spec.ChartVersion)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: %w", ref, err)
+	}
+	result, err := registryClient.Pull(fmt.Sprintf("%s:%s", ref, tag), registry.PullOptWithChart(true))
+	if err != nil {
+		return nil, fmt.Errorf("pull chart %s:%s: %w", ref, tag, err)
+	}
+	return result.Chart.Data, nil
+}
+
+// writeChartArchive validates the chart archive and writes it to the provisioner chart cache directory
+// named by its digest, archives of previously resolved charts are removed.
+func (r *NfsProvisionerReconciler) writeChartArchive(provisioner *crdv1.NfsProvisioner, data []byte) (*resolvedChart, error) {
+	if err := checkChartArchive(provisioner, data); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	cacheDir := r.getChartCacheDir(provisioner)
+	chartPath := filepath.Join(cacheDir, fmt.Sprintf("%s.tgz", digest))
+	if err := writeFileIfChanged(chartPath, data); err != nil {
+		return nil, err
+	}
+	archives, err := filepath.Glob(filepath.Join(cacheDir, "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	for _, archive := range archives {
+		if archive != chartPath {
+			_ = os.Remove(archive)
+		}
+	}
+	return &resolvedChart{Name: chartPath, Digest: digest}, nil
+}
+
+// getBundledChart returns the chart bundled into the controller image.
+func (r *NfsProvisionerReconciler) getBundledChart(provisioner *crdv1.NfsProvisioner) (*resolvedChart, error) {
+	data, err := os.ReadFile(r.BundledChartPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkChartArchive(provisioner, data); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &resolvedChart{Name: r.BundledChartPath, Digest: hex.EncodeToString(sum[:])}, nil
+}
+
+// checkChartArchive checks that the chart archive can be loaded and satisfies the chart version constraint.
+func checkChartArchive(provisioner *crdv1.NfsProvisioner, data []byte) error {
+	chart, err := loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("load chart archive: %w", err)
+	}
+	if provisioner.Spec.ChartVersion == "" {
+		return nil
+	}
+	constraint, err := semver.NewConstraint(provisioner.Spec.ChartVersion)
+	if err != nil {
+		return err
+	}
+	version, err := semver.NewVersion(chart.Metadata.Version)
+	if err != nil {
+		return fmt.Errorf("chart %s has invalid version %s: %w", chart.Metadata.Name, chart.Metadata.Version, err)
+	}
+	if !constraint.Check(version) {
+		return fmt.Errorf("chart %s version %s does not satisfy %s", chart.Metadata.Name, chart.Metadata.Version, provisioner.Spec.ChartVersion)
+	}
+	return nil
+}
+
+// newCATLSConfig returns a TLS client configuration trusting the CA certificates of the file.
+func newCATLSConfig(caFile string) (*tls.Config, error) {
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no valid CA certificate in %s", caFile)
+	}
+	return &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// writeFileIfChanged writes the data to the file unless the file already holds the data.
+func writeFileIfChanged(path string, data []byte) error {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
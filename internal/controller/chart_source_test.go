@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/yaml"
+)
+
+// newChartArchive returns a chart archive of the provisioner chart with the version.
+func newChartArchive(version string) []byte {
+	dir := GinkgoT().TempDir()
+	chartPath, err := chartutil.Save(&chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       crdv1.DefaultHelmChartName,
+			Version:    version,
+		},
+	}, dir)
+	Expect(err).NotTo(HaveOccurred())
+	data, err := os.ReadFile(chartPath)
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
+
+// newChartRepository returns an HTTP chart repository serving the provisioner chart archive with the version.
+func newChartRepository(version string) *httptest.Server {
+	data := newChartArchive(version)
+	sum := sha256.Sum256(data)
+	archiveName := "nfs-subdir-external-provisioner-" + version + ".tgz"
+	index := repo.NewIndexFile()
+	Expect(index.MustAdd(&chart.Metadata{
+		APIVersion: chart.APIVersionV2,
+		Name:       crdv1.DefaultHelmChartName,
+		Version:    version,
+	}, archiveName, "", hex.EncodeToString(sum[:]))).To(Succeed())
+	indexData, err := yaml.Marshal(index)
+	Expect(err).NotTo(HaveOccurred())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(indexData)
+	})
+	mux.HandleFunc("/"+archiveName, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data)
+	})
+	return httptest.NewServer(mux)
+}
+
+var _ = Describe("Chart sources", func() {
+	It("Provisioners should resolve charts from their own repositories", func() {
+		firstRepository := newChartRepository("4.0.17")
+		defer firstRepository.Close()
+		secondRepository := newChartRepository("4.0.18")
+		defer secondRepository.Close()
+		reconciler := NfsProvisionerReconciler{
+			Client:        k8sClient,
+			Recorder:      record.NewFakeRecorder(10),
+			ChartCacheDir: GinkgoT().TempDir(),
+		}
+		firstProvisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: DefaultNamespace},
+			Spec:       crdv1.NfsProvisionerSpec{ChartName: crdv1.DefaultHelmChartName, HelmRepository: firstRepository.URL},
+		}
+		secondProvisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: DefaultNamespace},
+			Spec:       crdv1.NfsProvisionerSpec{ChartName: crdv1.DefaultHelmChartName, HelmRepository: secondRepository.URL},
+		}
+		firstChart, err := reconciler.resolveChart(ctx, &firstProvisioner)
+		Expect(err).NotTo(HaveOccurred())
+		secondChart, err := reconciler.resolveChart(ctx, &secondProvisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(firstChart.Digest).NotTo(Equal(secondChart.Digest))
+		Expect(filepath.Dir(firstChart.Name)).To(Equal(reconciler.getChartCacheDir(&firstProvisioner)))
+		Expect(filepath.Dir(secondChart.Name)).To(Equal(reconciler.getChartCacheDir(&secondProvisioner)))
+		Expect(meta.IsStatusConditionTrue(firstProvisioner.Status.Conditions, crdv1.ChartSourceAvailableCondition)).To(BeTrue())
+
+		// Unreachable repository is reported in the status
+		firstRepository.Close()
+		_, err = reconciler.resolveChart(ctx, &firstProvisioner)
+		Expect(err).To(HaveOccurred())
+		Expect(meta.IsStatusConditionFalse(firstProvisioner.Status.Conditions, crdv1.ChartSourceAvailableCondition)).To(BeTrue())
+
+		// The chart cache of a deleted provisioner is removed
+		Expect(reconciler.removeChartCache(&secondProvisioner)).To(Succeed())
+		_, err = os.Stat(reconciler.getChartCacheDir(&secondProvisioner))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		Expect(reconciler.getChartCacheDir(&firstProvisioner)).To(BeADirectory())
+	})
+
+	It("Chart should be resolved from a ConfigMap", func() {
+		configMap := corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "provisioner-chart", Namespace: DefaultNamespace},
+			BinaryData: map[string][]byte{crdv1.DefaultChartKey: newChartArchive("4.0.18")},
+		}
+		Expect(k8sClient.Create(ctx, &configMap)).To(Succeed())
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "chart-configmap", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				ChartName:         crdv1.DefaultHelmChartName,
+				ChartVersion:      "~4.0",
+				ChartConfigMapRef: &crdv1.ChartConfigMapReference{Name: configMap.Name},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{
+			Client:        k8sClient,
+			Recorder:      record.NewFakeRecorder(10),
+			ChartCacheDir: GinkgoT().TempDir(),
+		}
+		chart, err := reconciler.resolveChart(ctx, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chart.Name).To(HavePrefix(reconciler.ChartCacheDir))
+		Expect(chart.Name).To(BeAnExistingFile())
+		Expect(chart.Digest).NotTo(BeEmpty())
+
+		provisioner.Spec.ChartVersion = "~5.0"
+		_, err = reconciler.resolveChart(ctx, &provisioner)
+		Expect(err).To(MatchError(ContainSubstring("does not satisfy")))
+		Expect(k8sClient.Delete(ctx, &configMap)).To(Succeed())
+	})
+
+	It("Bundled chart should be used when the configured chart is not available", func() {
+		bundledChartPath := filepath.Join(GinkgoT().TempDir(), "bundled.tgz")
+		Expect(os.WriteFile(bundledChartPath, newChartArchive("4.0.18"), 0o600)).To(Succeed())
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "chart-bundled", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				ChartName:         crdv1.DefaultHelmChartName,
+				ChartConfigMapRef: &crdv1.ChartConfigMapReference{Name: "missing-chart"},
+			},
+		}
+		recorder := record.NewFakeRecorder(10)
+		reconciler := NfsProvisionerReconciler{
+			Client:           k8sClient,
+			Recorder:         recorder,
+			ChartCacheDir:    GinkgoT().TempDir(),
+			BundledChartPath: bundledChartPath,
+		}
+		chart, err := reconciler.resolveChart(ctx, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chart.Name).To(Equal(bundledChartPath))
+		Expect(recorder.Events).To(Receive(ContainSubstring(BundledChartUsedEventReason)))
+
+		reconciler.BundledChartPath = ""
+		_, err = reconciler.resolveChart(ctx, &provisioner)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Repository credentials should not be sent to another chart host", func() {
+		data := newChartArchive("4.0.18")
+		sum := sha256.Sum256(data)
+		chartAuthorization := make(chan string, 1)
+		chartHost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			chartAuthorization <- r.Header.Get("Authorization")
+			_, _ = w.Write(data)
+		}))
+		defer chartHost.Close()
+		index := repo.NewIndexFile()
+		Expect(index.MustAdd(&chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       crdv1.DefaultHelmChartName,
+			Version:    "4.0.18",
+		}, "nfs-subdir-external-provisioner-4.0.18.tgz", chartHost.URL, hex.EncodeToString(sum[:]))).To(Succeed())
+		indexData, err := yaml.Marshal(index)
+		Expect(err).NotTo(HaveOccurred())
+		indexAuthorization := make(chan string, 1)
+		repository := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			indexAuthorization <- r.Header.Get("Authorization")
+			_, _ = w.Write(indexData)
+		}))
+		defer repository.Close()
+
+		secret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "chart-credentials", Namespace: DefaultNamespace},
+			Data: map[string][]byte{
+				crdv1.HelmRepositoryUsernameKey: []byte("user"),
+				crdv1.HelmRepositoryPasswordKey: []byte("password"),
+			},
+		}
+		Expect(k8sClient.Create(ctx, &secret)).To(Succeed())
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "chart-credentials", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				ChartName:               crdv1.DefaultHelmChartName,
+				HelmRepository:          repository.URL,
+				HelmRepositorySecretRef: &corev1.LocalObjectReference{Name: secret.Name},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{
+			Client:        k8sClient,
+			Recorder:      record.NewFakeRecorder(10),
+			ChartCacheDir: GinkgoT().TempDir(),
+		}
+		_, err = reconciler.resolveChart(ctx, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(<-indexAuthorization).To(HavePrefix("Basic "))
+		Expect(<-chartAuthorization).To(BeEmpty())
+		Expect(k8sClient.Delete(ctx, &secret)).To(Succeed())
+	})
+})
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// betaIsDefaultStorageClassAnnotation is the deprecated annotation of the default storage class.
+const betaIsDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+
+// getDefaultFileShareID returns the ID of the file share selected by spec.defaultStorageClass.
+// A file share matching by ID takes precedence over file shares matching by name.
+func (r *NfsProvisionerReconciler) getDefaultFileShareID(provisioner *crdv1.NfsProvisioner, fileShares []file_shares.FileShare) string {
+	if provisioner.Spec.DefaultStorageClass == "" {
+		return ""
+	}
+	for _, fileShare := range fileShares {
+		if fileShare.ID == provisioner.Spec.DefaultStorageClass {
+			return fileShare.ID
+		}
+	}
+	for _, fileShare := range fileShares {
+		if fileShare.Name == provisioner.Spec.DefaultStorageClass {
+			return fileShare.ID
+		}
+	}
+	return ""
+}
+
+// isDefaultStorageClass reports whether the storage class is annotated as the cluster default.
+func isDefaultStorageClass(storageClass *storagev1.StorageClass) bool {
+	return storageClass.Annotations[crdv1.IsDefaultStorageClassAnnotation] == "true" ||
+		storageClass.Annotations[betaIsDefaultStorageClassAnnotation] == "true"
+}
+
+// updateDefaultStorageClassStatus reports the default storage class of the provisioner in the status
+// and warns through the DefaultStorageClass condition when other storage classes are default too.
+func (r *NfsProvisionerReconciler) updateDefaultStorageClassStatus(ctx context.Context, provisioner *crdv1.NfsProvisioner, defaultFileShareID string) error {
+	provisioner.Status.DefaultStorageClassName = ""
+	if provisioner.Spec.DefaultStorageClass == "" {
+		meta.RemoveStatusCondition(&provisioner.Status.Conditions, crdv1.DefaultStorageClassCondition)
+		return nil
+	}
+	for _, fileShareStatus := range provisioner.Status.FileShares {
+		if fileShareStatus.ID == defaultFileShareID && fileShareStatus.ReleaseName != "" {
+			provisioner.Status.DefaultStorageClassName = fileShareStatus.StorageClassName
+		}
+	}
+	if provisioner.Status.DefaultStorageClassName == "" {
+		r.setCondition(provisioner, crdv1.DefaultStorageClassCondition, metav1.ConditionFalse, crdv1.DefaultFileShareNotFoundReason,
+			fmt.Sprintf("No provisioned file share matches %q", provisioner.Spec.DefaultStorageClass))
+		return nil
+	}
+	storageClassList := storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, &storageClassList); err != nil {
+		return err
+	}
+	otherDefaults := []string{}
+	for i := range storageClassList.Items {
+		storageClass := &storageClassList.Items[i]
+		if storageClass.Name != provisioner.Status.DefaultStorageClassName && isDefaultStorageClass(storageClass) {
+			otherDefaults = append(otherDefaults, storageClass.Name)
+		}
+	}
+	if len(otherDefaults) > 0 {
+		sort.Strings(otherDefaults)
+		r.setCondition(provisioner, crdv1.DefaultStorageClassCondition, metav1.ConditionFalse, crdv1.MultipleDefaultClassesReason,
+			fmt.Sprintf("Storage classes %s are default as well", strings.Join(otherDefaults, ", ")))
+		return nil
+	}
+	r.setCondition(provisioner, crdv1.DefaultStorageClassCondition, metav1.ConditionTrue, crdv1.ReconciledReason, "")
+	return nil
+}
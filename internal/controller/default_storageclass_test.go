@@ -0,0 +1,65 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Default storage class", func() {
+	fileShares := []file_shares.FileShare{
+		{ID: "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d", Name: "shared"},
+		{ID: "6d5c4b3a-2f1e-4d0c-8b9a-7f6e5d4c3b2a", Name: "shared"},
+		{ID: "0f1e2d3c-4b5a-4968-8776-a5b4c3d2e1f0", Name: "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d"},
+	}
+
+	It("Default file share should be selected by ID before name", func() {
+		reconciler := NfsProvisionerReconciler{}
+		provisioner := crdv1.NfsProvisioner{}
+		Expect(reconciler.getDefaultFileShareID(&provisioner, fileShares)).To(BeEmpty())
+		provisioner.Spec.DefaultStorageClass = "shared"
+		Expect(reconciler.getDefaultFileShareID(&provisioner, fileShares)).To(Equal(fileShares[0].ID))
+		provisioner.Spec.DefaultStorageClass = fileShares[0].ID
+		Expect(reconciler.getDefaultFileShareID(&provisioner, fileShares)).To(Equal(fileShares[0].ID))
+		provisioner.Spec.DefaultStorageClass = "missing"
+		Expect(reconciler.getDefaultFileShareID(&provisioner, fileShares)).To(BeEmpty())
+	})
+
+	It("Other default storage classes should be reported in the status", func() {
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{DefaultStorageClass: "shared"},
+			Status: crdv1.NfsProvisionerStatus{
+				FileShares: []crdv1.FileShareStatus{
+					{ID: fileShares[0].ID, Name: "shared", ReleaseName: "nfs-release", StorageClassName: "sfs-shared"},
+				},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{Client: k8sClient}
+		Expect(reconciler.updateDefaultStorageClassStatus(ctx, &provisioner, fileShares[0].ID)).To(Succeed())
+		Expect(provisioner.Status.DefaultStorageClassName).To(Equal("sfs-shared"))
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.DefaultStorageClassCondition)).To(BeTrue())
+
+		otherDefault := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "standard",
+				Annotations: map[string]string{crdv1.IsDefaultStorageClassAnnotation: "true"},
+			},
+			Provisioner: "kubernetes.io/no-provisioner",
+		}
+		Expect(k8sClient.Create(ctx, &otherDefault)).To(Succeed())
+		Expect(reconciler.updateDefaultStorageClassStatus(ctx, &provisioner, fileShares[0].ID)).To(Succeed())
+		condition := meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.DefaultStorageClassCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(crdv1.MultipleDefaultClassesReason))
+		Expect(condition.Message).To(ContainSubstring("standard"))
+		Expect(k8sClient.Delete(ctx, &otherDefault)).To(Succeed())
+
+		provisioner.Spec.DefaultStorageClass = ""
+		Expect(reconciler.updateDefaultStorageClassStatus(ctx, &provisioner, "")).To(Succeed())
+		Expect(meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.DefaultStorageClassCondition)).To(BeNil())
+	})
+})
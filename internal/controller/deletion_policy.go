@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OrphanedNfsProvisionerLabelName labels the objects orphaned by a deleted NfsProvisioner with the hash of its
+// namespace and name, in place of its UID.
+const OrphanedNfsProvisionerLabelName = "orphanedNfsProvisioner"
+
+// deletionBlockedRequeueDelay is the delay before checking again whether the storage classes blocking
+// the removal of a file share provisioner are still in use, volumes and claims are not watched.
+const deletionBlockedRequeueDelay = time.Minute
+
+// getStorageClassUsers returns the names of the PersistentVolumes and the namespaced names of the
+// PersistentVolumeClaims using any of the storage classes.
+func (r *NfsProvisionerReconciler) getStorageClassUsers(ctx context.Context, storageClassNames StringSet) ([]string, []string, error) {
+	volumeList := corev1.PersistentVolumeList{}
+	if err := r.Client.List(ctx, &volumeList); err != nil {
+		return nil, nil, err
+	}
+	var volumes []string
+	for _, volume := range volumeList.Items {
+		if volume.Spec.StorageClassName != "" && storageClassNames[volume.Spec.StorageClassName] {
+			volumes = append(volumes, volume.Name)
+		}
+	}
+	claimList := corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, &claimList); err != nil {
+		return nil, nil, err
+	}
+	var claims []string
+	for _, claim := range claimList.Items {
+		if claim.Spec.StorageClassName != nil && storageClassNames[*claim.Spec.StorageClassName] {
+			claims = append(claims, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}.String())
+		}
+	}
+	return volumes, claims, nil
+}
+
+// getReferencingFileShares returns the names of the FileShares referencing the provisioner.
+func (r *NfsProvisionerReconciler) getReferencingFileShares(ctx context.Context, provisioner *crdv1.NfsProvisioner) ([]string, error) {
+	fileShareList := crdv1.FileShareList{}
+	if err := r.Client.List(ctx, &fileShareList, client.InNamespace(provisioner.Namespace)); err != nil {
+		return nil, err
+	}
+	var fileShares []string
+	for _, fileShare := range fileShareList.Items {
+		if fileShare.Spec.ProvisionerRef.Name == provisioner.Name {
+			fileShares = append(fileShares, fileShare.Name)
+		}
+	}
+	sort.Strings(fileShares)
+	return fileShares, nil
+}
+
+// applyDeletionPolicy returns the file shares whose provisioners are removed according to spec.deletionPolicy.
+// With BlockWhileInUse the file shares whose storage classes are still used by a PersistentVolume or claim are
+// kept and reported in the DeletionBlocked condition, the returned flag reports whether any file share is kept.
+func (r *NfsProvisionerReconciler) applyDeletionPolicy(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareIDs []string) ([]string, bool, error) {
+	log := log.FromContext(ctx)
+
+	switch provisioner.Spec.DeletionPolicy {
+	case crdv1.DeletionPolicyOrphan:
+		meta.RemoveStatusCondition(&provisioner.Status.Conditions, crdv1.DeletionBlockedCondition)
+		if len(fileShareIDs) > 0 {
+			log.V(1).Info("Orphaning provisioners of removed file shares", "fileShareIDs", fileShareIDs)
+		}
+		return nil, false, nil
+	case crdv1.DeletionPolicyBlockWhileInUse:
+	default:
+		meta.RemoveStatusCondition(&provisioner.Status.Conditions, crdv1.DeletionBlockedCondition)
+		return fileShareIDs, false, nil
+	}
+	sortedIDs := append([]string{}, fileShareIDs...)
+	sort.Strings(sortedIDs)
+	var removableIDs []string
+	var blocked []string
+	for _, fileShareID := range sortedIDs {
+		storageClassList := storagev1.StorageClassList{}
+		if err := r.Client.List(ctx, &storageClassList, client.MatchingLabels{
+			NfsProvisionerIDLabelName: string(provisioner.UID),
+			FileShareIDLabelName:      fileShareID,
+		}); err != nil {
+			return nil, false, err
+		}
+		storageClassNames := StringSet{}
+		fileShareName := fileShareID
+		for _, storageClass := range storageClassList.Items {
+			storageClassNames[storageClass.Name] = true
+			if name := storageClass.Labels[FileShareNameLabelName]; name != "" {
+				fileShareName = name
+			}
+		}
+		volumes, claims, err := r.getStorageClassUsers(ctx, storageClassNames)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(volumes) == 0 && len(claims) == 0 {
+			removableIDs = append(removableIDs, fileShareID)
+			continue
+		}
+		log.Info("Keeping provisioner of file share while its storage classes are in use",
+			"fileShare", fileShareName, "persistentVolumes", volumes, "persistentVolumeClaims", claims)
+		blocked = append(blocked, fmt.Sprintf("%s (%d PersistentVolumes, %d PersistentVolumeClaims)", fileShareName, len(volumes), len(claims)))
+	}
+	if len(blocked) > 0 {
+		r.setCondition(provisioner, crdv1.DeletionBlockedCondition, metav1.ConditionTrue, crdv1.VolumesInUseReason,
+			fmt.Sprintf("Provisioners kept while their storage classes are in use: %s", strings.Join(blocked, ", ")))
+		return removableIDs, true, nil
+	}
+	r.setCondition(provisioner, crdv1.DeletionBlockedCondition, metav1.ConditionFalse, crdv1.ReconciledReason, "")
+	return removableIDs, false, nil
+}
+
+// orphanProvisionerObjects releases the objects of a provisioner deleted with the Orphan deletion policy. The owner
+// reference of the provisioner is removed from its namespaced Native deployment mode objects, so they are not garbage
+// collected with the provisioner, and the provisioner UID labels of all its objects are replaced by the
+// OrphanedNfsProvisionerLabelName label, so a provisioner recreated with the same namespace and name adopts them.
+func (r *NfsProvisionerReconciler) orphanProvisionerObjects(ctx context.Context, provisioner *crdv1.NfsProvisioner) error {
+	lists := map[client.ObjectList]string{
+		&appsv1.DeploymentList{}: provisioner.Namespace, &corev1.PersistentVolumeClaimList{}: provisioner.Namespace,
+		&rbacv1.RoleBindingList{}: provisioner.Namespace, &rbacv1.RoleList{}: provisioner.Namespace,
+		&corev1.ServiceAccountList{}: provisioner.Namespace, &storagev1.StorageClassList{}: "",
+		&corev1.PersistentVolumeList{}: "", &rbacv1.ClusterRoleBindingList{}: "", &rbacv1.ClusterRoleList{}: "",
+	}
+	for _, ownerLabelName := range []string{NfsProvisionerIDLabelName, DynamicNfsProvisionerIDLabelName} {
+		for list, namespace := range lists {
+			if err := r.Client.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{ownerLabelName: string(provisioner.UID)}); err != nil {
+				return err
+			}
+			objects, err := meta.ExtractList(list)
+			if err != nil {
+				return err
+			}
+			for _, object := range objects {
+				obj := object.(client.Object)
+				patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+				ownerReferences := []metav1.OwnerReference{}
+				for _, ownerReference := range obj.GetOwnerReferences() {
+					if ownerReference.UID != provisioner.UID {
+						ownerReferences = append(ownerReferences, ownerReference)
+					}
+				}
+				obj.SetOwnerReferences(ownerReferences)
+				labels := obj.GetLabels()
+				delete(labels, ownerLabelName)
+				labels[OrphanedNfsProvisionerLabelName] = r.getProvisionerHash(provisioner)
+				obj.SetLabels(labels)
+				if err := r.Client.Patch(ctx, obj, patch); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isAdoptable reports whether the object was orphaned by a former provisioner with the namespace and name
+// of the provisioner, which takes it over.
+func (r *NfsProvisionerReconciler) isAdoptable(obj client.Object, provisioner *crdv1.NfsProvisioner) bool {
+	return obj.GetLabels()[OrphanedNfsProvisionerLabelName] == r.getProvisionerHash(provisioner)
+}
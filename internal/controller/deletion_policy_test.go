@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"errors"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	gohelmclient "github.com/mittwald/go-helm-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Deletion policy", func() {
+	It("File share provisioner should be kept while a volume uses its storage class", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "blocked", Namespace: DefaultNamespace, UID: "blocked-uid"},
+			Spec:       crdv1.NfsProvisionerSpec{DeletionPolicy: crdv1.DeletionPolicyBlockWhileInUse},
+		}
+		usedFileShareID := "7b1d3e5f-2a4c-4e6f-8a1b-3c5d7e9f1a2b"
+		unusedFileShareID := "1e3f5a7b-9c2d-4e6f-a1b3-c5d7e9f1a3b5"
+		storageClass := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "nfs-" + usedFileShareID,
+				Labels: map[string]string{
+					NfsProvisionerIDLabelName: string(provisioner.UID),
+					FileShareIDLabelName:      usedFileShareID,
+					FileShareNameLabelName:    "used",
+				},
+			},
+			Provisioner: "cluster.local/used",
+		}
+		Expect(k8sClient.Create(ctx, &storageClass)).To(Succeed())
+		volume := corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "blocked-volume"},
+			Spec: corev1.PersistentVolumeSpec{
+				StorageClassName: storageClass.Name,
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					NFS: &corev1.NFSVolumeSource{Server: "10.33.20.97", Path: "/shares/used"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &volume)).To(Succeed())
+
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(10)}
+		removedIDs, blocked, err := reconciler.applyDeletionPolicy(ctx, &provisioner, []string{usedFileShareID, unusedFileShareID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blocked).To(BeTrue())
+		Expect(removedIDs).To(Equal([]string{unusedFileShareID}))
+		condition := meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.DeletionBlockedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(crdv1.VolumesInUseReason))
+		Expect(condition.Message).To(ContainSubstring("used (1 PersistentVolumes, 0 PersistentVolumeClaims)"))
+
+		// Orphan and Delete policies never block
+		provisioner.Spec.DeletionPolicy = crdv1.DeletionPolicyOrphan
+		removedIDs, blocked, err = reconciler.applyDeletionPolicy(ctx, &provisioner, []string{usedFileShareID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blocked).To(BeFalse())
+		Expect(removedIDs).To(BeEmpty())
+		Expect(meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.DeletionBlockedCondition)).To(BeNil())
+		provisioner.Spec.DeletionPolicy = crdv1.DeletionPolicyDelete
+		removedIDs, _, err = reconciler.applyDeletionPolicy(ctx, &provisioner, []string{usedFileShareID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removedIDs).To(Equal([]string{usedFileShareID}))
+
+		// The provisioner is removed once no volume uses the storage class
+		provisioner.Spec.DeletionPolicy = crdv1.DeletionPolicyBlockWhileInUse
+		Expect(k8sClient.Delete(ctx, &volume)).To(Succeed())
+		// No controller removes the volume protection finalizer in the test environment
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: volume.Name}, &volume); err == nil {
+			volume.Finalizers = nil
+			Expect(k8sClient.Update(ctx, &volume)).To(Succeed())
+		}
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: volume.Name}, &corev1.PersistentVolume{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+		removedIDs, blocked, err = reconciler.applyDeletionPolicy(ctx, &provisioner, []string{usedFileShareID})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blocked).To(BeFalse())
+		Expect(removedIDs).To(Equal([]string{usedFileShareID}))
+		Expect(meta.IsStatusConditionFalse(provisioner.Status.Conditions, crdv1.DeletionBlockedCondition)).To(BeTrue())
+		Expect(k8sClient.Delete(ctx, &storageClass)).To(Succeed())
+	})
+
+	It("Orphaned objects should be adopted by a recreated provisioner", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "orphaned", Namespace: DefaultNamespace, UID: "orphaned-uid"},
+		}
+		fileShare := file_shares.FileShare{ID: "2c4e6a8b-0d1f-4a3c-8e5b-7d9f1b3d5f7a", Name: "orphaned"}
+		serviceAccount := corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "orphaned-provisioner",
+				Namespace: DefaultNamespace,
+				Labels:    map[string]string{managedByLabelName: NativeFieldManager, NfsProvisionerIDLabelName: string(provisioner.UID)},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: crdv1.GroupVersion.String(), Kind: "NfsProvisioner", Name: provisioner.Name, UID: provisioner.UID,
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &serviceAccount)).To(Succeed())
+		storageClass := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "nfs-" + fileShare.ID,
+				Labels: map[string]string{NfsProvisionerIDLabelName: string(provisioner.UID), FileShareIDLabelName: fileShare.ID},
+			},
+			Provisioner: "cluster.local/orphaned",
+		}
+		Expect(k8sClient.Create(ctx, &storageClass)).To(Succeed())
+
+		helmClient, err := gohelmclient.NewClientFromRestConf(
+			&gohelmclient.RestConfClientOptions{
+				Options:    &gohelmclient.Options{},
+				RestConfig: cfg,
+			})
+		Expect(err).NotTo(HaveOccurred())
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, HelmClient: helmClient}
+		Expect(reconciler.orphanProvisionerObjects(ctx, &provisioner)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: DefaultNamespace, Name: serviceAccount.Name}, &serviceAccount)).To(Succeed())
+		Expect(serviceAccount.OwnerReferences).To(BeEmpty())
+		Expect(serviceAccount.Labels).NotTo(HaveKey(NfsProvisionerIDLabelName))
+		Expect(serviceAccount.Labels).To(HaveKeyWithValue(OrphanedNfsProvisionerLabelName, reconciler.getProvisionerHash(&provisioner)))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)).To(Succeed())
+		Expect(storageClass.Labels).NotTo(HaveKey(NfsProvisionerIDLabelName))
+
+		// The provisioner recreated with the same namespace and name adopts the orphaned storage class
+		recreated := provisioner.DeepCopy()
+		recreated.UID = "recreated-uid"
+		Expect(reconciler.checkFileShareOwnership(ctx, recreated, &fileShare, nil)).To(Succeed())
+		other := crdv1.NfsProvisioner{ObjectMeta: metav1.ObjectMeta{Name: "orphaned", Namespace: "other", UID: "other-uid"}}
+		var claimedErr *fileShareClaimedError
+		Expect(errors.As(reconciler.checkFileShareOwnership(ctx, &other, &fileShare, nil), &claimedErr)).To(BeTrue())
+		Expect(k8sClient.Delete(ctx, &serviceAccount)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &storageClass)).To(Succeed())
+	})
+})
@@ -0,0 +1,487 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DynamicProvisionerName is the provisioner of the storage classes creating a dedicated file share for every claim.
+const DynamicProvisionerName = "file-share.gcore-sfs-controller.io"
+
+// Parameters of the dynamic provisioning storage classes.
+const (
+	NfsProvisionerNamespaceParameter = "nfsProvisionerNamespace"
+	NfsProvisionerNameParameter      = "nfsProvisionerName"
+	NetworkIDParameter               = "networkID"
+	SubnetIDParameter                = "subnetID"
+	VolumeTypeParameter              = "volumeType"
+)
+
+const (
+	// DynamicNfsProvisionerIDLabelName labels the dynamic provisioning storage class with the UID of its NfsProvisioner.
+	DynamicNfsProvisionerIDLabelName = "dynamicNfsProvisionerID"
+	// ClaimUIDLabelName labels the FileShare of a claim with the claim UID.
+	ClaimUIDLabelName = "claimUID"
+	// ClaimAnnotationName is the namespaced name of the claim of a FileShare.
+	ClaimAnnotationName = "gcore-sfs-controller.io/claim"
+	// FileShareAnnotationName is the namespaced name of the FileShare of a PersistentVolume.
+	FileShareAnnotationName = "gcore-sfs-controller.io/file-share"
+	// DynamicVolumeFinalizer keeps a dynamically provisioned PersistentVolume until its file share is deleted.
+	DynamicVolumeFinalizer = "volume.gcore-sfs-controller.io"
+
+	provisionedByAnnotationName = "pv.kubernetes.io/provisioned-by"
+	selectedNodeAnnotationName  = "volume.kubernetes.io/selected-node"
+	// dynamicFileShareMinSize is the minimum size of a FileShare in GiB.
+	dynamicFileShareMinSize = 2
+)
+
+// Event reasons emitted on PersistentVolumeClaims and PersistentVolumes.
+const (
+	ProvisioningEventReason           = "Provisioning"
+	ProvisioningSucceededEventReason  = "ProvisioningSucceeded"
+	ProvisioningFailedEventReason     = "ProvisioningFailed"
+	VolumeDeletingEventReason         = "VolumeDeleting"
+	ResizingEventReason               = "Resizing"
+	VolumeResizeSuccessfulEventReason = "VolumeResizeSuccessful"
+)
+
+// reconcileDynamicStorageClass applies the storage class of spec.dynamicProvisioning and deletes
+// the former dynamic provisioning storage classes of the provisioner. A storage class of the same name which
+// is not managed by the provisioner is left intact and reported in the Conflict condition.
+func (r *NfsProvisionerReconciler) reconcileDynamicStorageClass(ctx context.Context, provisioner *crdv1.NfsProvisioner) error {
+	dynamicProvisioning := provisioner.Spec.DynamicProvisioning
+	if dynamicProvisioning == nil {
+		return r.deleteDynamicStorageClasses(ctx, provisioner, "")
+	}
+	var claimedErr *fileShareClaimedError
+	if err := r.checkDynamicStorageClassOwnership(ctx, provisioner); errors.As(err, &claimedErr) {
+		log.FromContext(ctx).Info("Skip applying dynamic provisioning storage class", "reason", err.Error())
+		return r.deleteDynamicStorageClasses(ctx, provisioner, "")
+	} else if err != nil {
+		return err
+	}
+	reclaimPolicy := dynamicProvisioning.ReclaimPolicy
+	if reclaimPolicy == "" {
+		reclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	}
+	volumeBindingMode := storagev1.VolumeBindingImmediate
+	allowVolumeExpansion := true
+	storageClass := &storagev1.StorageClass{
+		TypeMeta: metav1.TypeMeta{APIVersion: storagev1.SchemeGroupVersion.String(), Kind: "StorageClass"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: dynamicProvisioning.StorageClassName,
+			Labels: map[string]string{
+				managedByLabelName:               NativeFieldManager,
+				DynamicNfsProvisionerIDLabelName: string(provisioner.UID),
+			},
+		},
+		Provisioner: DynamicProvisionerName,
+		Parameters: map[string]string{
+			NfsProvisionerNamespaceParameter: provisioner.Namespace,
+			NfsProvisionerNameParameter:      provisioner.Name,
+			NetworkIDParameter:               dynamicProvisioning.Network.NetworkID,
+			SubnetIDParameter:                dynamicProvisioning.Network.SubnetID,
+			VolumeTypeParameter:              dynamicProvisioning.VolumeType,
+		},
+		ReclaimPolicy:        &reclaimPolicy,
+		AllowVolumeExpansion: &allowVolumeExpansion,
+		VolumeBindingMode:    &volumeBindingMode,
+		MountOptions:         crdv1.GetEffectiveMountOptions(&provisioner.Spec, nil),
+	}
+	if err := r.applyNativeObject(ctx, storageClass); err != nil {
+		return fmt.Errorf("apply dynamic provisioning storage class %s: %w", storageClass.Name, err)
+	}
+	return r.deleteDynamicStorageClasses(ctx, provisioner, storageClass.Name)
+}
+
+// deleteDynamicStorageClasses deletes the dynamic provisioning storage classes of the provisioner except keep.
+func (r *NfsProvisionerReconciler) deleteDynamicStorageClasses(ctx context.Context, provisioner *crdv1.NfsProvisioner, keep string) error {
+	storageClassList := storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, &storageClassList, client.MatchingLabels{DynamicNfsProvisionerIDLabelName: string(provisioner.UID)}); err != nil {
+		return err
+	}
+	for i := range storageClassList.Items {
+		if storageClassList.Items[i].Name == keep {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &storageClassList.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getDynamicVolumeName returns the name of the PersistentVolume and FileShare provisioned for the claim.
+func getDynamicVolumeName(claim *corev1.PersistentVolumeClaim) string {
+	return "pvc-" + string(claim.UID)
+}
+
+// getClaimFileShareSize returns the requested size of the claim in GiB rounded up.
+func getClaimFileShareSize(claim *corev1.PersistentVolumeClaim) int {
+	request := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+	size := int((request.Value() + (1 << 30) - 1) >> 30)
+	if size < dynamicFileShareMinSize {
+		return dynamicFileShareMinSize
+	}
+	return size
+}
+
+// ClaimReconciler provisions a dedicated file share and PersistentVolume for every PersistentVolumeClaim
+// of a storage class with the DynamicProvisionerName provisioner. The file share is managed by a FileShare
+// in the namespace of the NfsProvisioner referenced by the storage class parameters. The bound volumes of
+// expanded claims of these storage classes and of the file share storage classes are resized.
+type ClaimReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims/status,verbs=get;update;patch
+
+func (r *ClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	claim := corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(ctx, req.NamespacedName, &claim); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.deleteUnboundFileShares(ctx, req.NamespacedName, "")
+		}
+		return ctrl.Result{}, err
+	}
+	// The FileShares of a former claim with the same name are deleted when it was not bound.
+	if err := r.deleteUnboundFileShares(ctx, req.NamespacedName, claim.UID); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !claim.DeletionTimestamp.IsZero() || claim.Spec.StorageClassName == nil {
+		return ctrl.Result{}, nil
+	}
+	if claim.Spec.VolumeName != "" {
+		return ctrl.Result{}, r.reconcileExpansion(ctx, &claim)
+	}
+	storageClass := storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: *claim.Spec.StorageClassName}, &storageClass); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if storageClass.Provisioner != DynamicProvisionerName {
+		return ctrl.Result{}, nil
+	}
+	if storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer &&
+		claim.Annotations[selectedNodeAnnotationName] == "" {
+		return ctrl.Result{}, nil
+	}
+	if claim.Spec.VolumeMode != nil && *claim.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		r.Recorder.Event(&claim, corev1.EventTypeWarning, ProvisioningFailedEventReason, "File shares do not support block volumes")
+		return ctrl.Result{}, nil
+	}
+
+	volumeName := getDynamicVolumeName(&claim)
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: volumeName}, &corev1.PersistentVolume{}); err == nil || !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	fileShare := crdv1.FileShare{}
+	fileShareName := types.NamespacedName{Namespace: storageClass.Parameters[NfsProvisionerNamespaceParameter], Name: volumeName}
+	if err := r.Client.Get(ctx, fileShareName, &fileShare); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		fileShare = r.buildFileShare(&claim, &storageClass, fileShareName)
+		if err := r.Client.Create(ctx, &fileShare); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Provisioning file share for claim", "claim", req.NamespacedName, "fileShare", fileShareName, "size", fileShare.Spec.Size)
+		r.Recorder.Eventf(&claim, corev1.EventTypeNormal, ProvisioningEventReason,
+			"Creating file share %s of %d GiB", fileShareName, fileShare.Spec.Size)
+		return ctrl.Result{}, nil
+	}
+	switch fileShare.Status.Phase {
+	case crdv1.FileSharePhaseAvailable:
+	case crdv1.FileSharePhaseFailed:
+		message := "file share failed"
+		if condition := meta.FindStatusCondition(fileShare.Status.Conditions, crdv1.ReadyCondition); condition != nil {
+			message = condition.Message
+		}
+		r.Recorder.Eventf(&claim, corev1.EventTypeWarning, ProvisioningFailedEventReason, "File share %s: %s", fileShareName, message)
+		return ctrl.Result{}, nil
+	default:
+		return ctrl.Result{}, nil
+	}
+
+	volume, err := r.buildVolume(&claim, &storageClass, &fileShare)
+	if err != nil {
+		r.Recorder.Eventf(&claim, corev1.EventTypeWarning, ProvisioningFailedEventReason, "File share %s: %v", fileShareName, err)
+		return ctrl.Result{}, nil
+	}
+	if err := r.Client.Create(ctx, volume); err != nil {
+		return ctrl.Result{}, client.IgnoreAlreadyExists(err)
+	}
+	log.Info("Provisioned volume for claim", "claim", req.NamespacedName, "volume", volume.Name)
+	r.Recorder.Eventf(&claim, corev1.EventTypeNormal, ProvisioningSucceededEventReason,
+		"Successfully provisioned volume %s on file share %s", volume.Name, fileShare.Status.ConnectionPoint)
+	return ctrl.Result{}, nil
+}
+
+// reconcileExpansion resizes the bound volume of an expanded claim. The FileShare of a dynamically provisioned
+// volume is extended first. The volumes of the file share storage classes share the file share and have no quota,
+// their capacity is nominal and spec.autoExpand extends the file share instead. No resizer serves these volumes,
+// so the expansion conditions and allocated resources of the claim are completed here.
+func (r *ClaimReconciler) reconcileExpansion(ctx context.Context, claim *corev1.PersistentVolumeClaim) error {
+	log := log.FromContext(ctx)
+
+	request := claim.Spec.Resources.Requests.Storage()
+	if claim.Status.Phase != corev1.ClaimBound || request.Cmp(*claim.Status.Capacity.Storage()) <= 0 {
+		return nil
+	}
+	volume := corev1.PersistentVolume{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: claim.Spec.VolumeName}, &volume); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	capacity := *request
+	if volume.Annotations[provisionedByAnnotationName] == DynamicProvisionerName {
+		namespace, name, found := strings.Cut(volume.Annotations[FileShareAnnotationName], "/")
+		if !found {
+			return nil
+		}
+		fileShare := crdv1.FileShare{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &fileShare); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		size := getClaimFileShareSize(claim)
+		if fileShare.Spec.Size < size {
+			log.Info("Extending file share of expanded claim", "claim", client.ObjectKeyFromObject(claim), "fileShare", name, "size", size)
+			r.Recorder.Eventf(claim, corev1.EventTypeNormal, ResizingEventReason, "Extending file share %s to %d GiB", name, size)
+			fileShare.Spec.Size = size
+			return r.Client.Update(ctx, &fileShare)
+		}
+		// The claim is reconciled again when the FileShare is extended.
+		if fileShare.Status.Phase != crdv1.FileSharePhaseAvailable || fileShare.Status.Size < size {
+			return nil
+		}
+		capacity = *resource.NewQuantity(int64(fileShare.Status.Size)<<30, resource.BinarySI)
+	} else {
+		storageClass := storagev1.StorageClass{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: volume.Spec.StorageClassName}, &storageClass); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if storageClass.Labels[NfsProvisionerIDLabelName] == "" {
+			return nil
+		}
+	}
+
+	if volume.Spec.Capacity.Storage().Cmp(capacity) < 0 {
+		volume.Spec.Capacity[corev1.ResourceStorage] = capacity
+		if err := r.Client.Update(ctx, &volume); err != nil {
+			return err
+		}
+	}
+	claim.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: capacity}
+	conditions := []corev1.PersistentVolumeClaimCondition{}
+	for _, condition := range claim.Status.Conditions {
+		if condition.Type != corev1.PersistentVolumeClaimResizing && condition.Type != corev1.PersistentVolumeClaimFileSystemResizePending {
+			conditions = append(conditions, condition)
+		}
+	}
+	claim.Status.Conditions = conditions
+	if claim.Status.AllocatedResources != nil {
+		claim.Status.AllocatedResources[corev1.ResourceStorage] = capacity
+	}
+	claim.Status.ResizeStatus = nil
+	if err := r.Client.Status().Update(ctx, claim); err != nil {
+		return err
+	}
+	log.Info("Resized volume of expanded claim", "claim", client.ObjectKeyFromObject(claim), "volume", volume.Name, "capacity", capacity.String())
+	r.Recorder.Eventf(claim, corev1.EventTypeNormal, VolumeResizeSuccessfulEventReason, "Resized volume %s to %s", volume.Name, capacity.String())
+	return nil
+}
+
+func (r *ClaimReconciler) buildFileShare(claim *corev1.PersistentVolumeClaim, storageClass *storagev1.StorageClass, fileShareName types.NamespacedName) crdv1.FileShare {
+	reclaimPolicy := crdv1.FileShareReclaimDelete
+	if storageClass.ReclaimPolicy != nil && *storageClass.ReclaimPolicy == corev1.PersistentVolumeReclaimRetain {
+		reclaimPolicy = crdv1.FileShareReclaimRetain
+	}
+	return crdv1.FileShare{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fileShareName.Name,
+			Namespace:   fileShareName.Namespace,
+			Labels:      map[string]string{ClaimUIDLabelName: string(claim.UID)},
+			Annotations: map[string]string{ClaimAnnotationName: types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}.String()},
+		},
+		Spec: crdv1.FileShareSpec{
+			ProvisionerRef: corev1.LocalObjectReference{Name: storageClass.Parameters[NfsProvisionerNameParameter]},
+			Size:           getClaimFileShareSize(claim),
+			VolumeType:     storageClass.Parameters[VolumeTypeParameter],
+			Network: crdv1.FileShareNetwork{
+				NetworkID: storageClass.Parameters[NetworkIDParameter],
+				SubnetID:  storageClass.Parameters[SubnetIDParameter],
+			},
+			Metadata:      map[string]string{crdv1.PersistentVolumeMetadataKey: fileShareName.Name},
+			ReclaimPolicy: reclaimPolicy,
+		},
+	}
+}
+
+func (r *ClaimReconciler) buildVolume(claim *corev1.PersistentVolumeClaim, storageClass *storagev1.StorageClass, fileShare *crdv1.FileShare) (*corev1.PersistentVolume, error) {
+	server, path, err := splitConnectionPoint(fileShare.Status.ConnectionPoint)
+	if err != nil {
+		return nil, err
+	}
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	if storageClass.ReclaimPolicy != nil {
+		reclaimPolicy = *storageClass.ReclaimPolicy
+	}
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fileShare.Name,
+			Annotations: map[string]string{
+				provisionedByAnnotationName: DynamicProvisionerName,
+				FileShareAnnotationName:     types.NamespacedName{Namespace: fileShare.Namespace, Name: fileShare.Name}.String(),
+			},
+			Finalizers: []string{DynamicVolumeFinalizer},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: *resource.NewQuantity(int64(fileShare.Status.Size)<<30, resource.BinarySI),
+			},
+			AccessModes: claim.Spec.AccessModes,
+			ClaimRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+				Namespace:  claim.Namespace,
+				Name:       claim.Name,
+				UID:        claim.UID,
+			},
+			PersistentVolumeReclaimPolicy: reclaimPolicy,
+			StorageClassName:              storageClass.Name,
+			MountOptions:                  storageClass.MountOptions,
+			VolumeMode:                    claim.Spec.VolumeMode,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				NFS: &corev1.NFSVolumeSource{Server: server, Path: path},
+			},
+		},
+	}, nil
+}
+
+// deleteUnboundFileShares deletes the FileShares created for a deleted claim with the namespaced name before
+// a volume was bound to it, the FileShares of the claim with the UID are kept.
+func (r *ClaimReconciler) deleteUnboundFileShares(ctx context.Context, claimName types.NamespacedName, claimUID types.UID) error {
+	fileShareList := crdv1.FileShareList{}
+	if err := r.Client.List(ctx, &fileShareList, client.HasLabels{ClaimUIDLabelName}); err != nil {
+		return err
+	}
+	for i := range fileShareList.Items {
+		fileShare := &fileShareList.Items[i]
+		if fileShare.Annotations[ClaimAnnotationName] != claimName.String() || fileShare.Labels[ClaimUIDLabelName] == string(claimUID) {
+			continue
+		}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: fileShare.Name}, &corev1.PersistentVolume{})
+		if err == nil || !apierrors.IsNotFound(err) {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting file share of deleted claim", "claim", claimName, "fileShare", fileShare.Name)
+		if err := r.Client.Delete(ctx, fileShare); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findClaimForFileShare maps a FileShare to the claim it was created for.
+func (r *ClaimReconciler) findClaimForFileShare(ctx context.Context, obj client.Object) []reconcile.Request {
+	namespace, name, found := strings.Cut(obj.GetAnnotations()[ClaimAnnotationName], "/")
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("claim").
+		For(&corev1.PersistentVolumeClaim{}).
+		Watches(&crdv1.FileShare{}, handler.EnqueueRequestsFromMapFunc(r.findClaimForFileShare)).
+		Complete(r)
+}
+
+// VolumeReconciler applies the reclaim policy of the dynamically provisioned PersistentVolumes. A released
+// volume with the Delete policy is deleted, its finalizer is removed once its FileShare is deleted.
+type VolumeReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+func (r *VolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	volume := corev1.PersistentVolume{}
+	if err := r.Client.Get(ctx, req.NamespacedName, &volume); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if volume.Annotations[provisionedByAnnotationName] != DynamicProvisionerName {
+		return ctrl.Result{}, nil
+	}
+	deleteFileShare := volume.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimDelete
+	if volume.DeletionTimestamp.IsZero() {
+		if volume.Status.Phase == corev1.VolumeReleased && deleteFileShare {
+			log.Info("Deleting released volume", "volume", volume.Name)
+			r.Recorder.Event(&volume, corev1.EventTypeNormal, VolumeDeletingEventReason, "Deleting released volume and its file share")
+			return ctrl.Result{}, client.IgnoreNotFound(r.Client.Delete(ctx, &volume))
+		}
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&volume, DynamicVolumeFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	if namespace, name, found := strings.Cut(volume.Annotations[FileShareAnnotationName], "/"); found && deleteFileShare {
+		fileShare := crdv1.FileShare{}
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &fileShare)
+		if err == nil {
+			// The volume is reconciled again when the FileShare is gone.
+			if fileShare.DeletionTimestamp.IsZero() {
+				return ctrl.Result{}, client.IgnoreNotFound(r.Client.Delete(ctx, &fileShare))
+			}
+			return ctrl.Result{}, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+	controllerutil.RemoveFinalizer(&volume, DynamicVolumeFinalizer)
+	return ctrl.Result{}, r.Client.Update(ctx, &volume)
+}
+
+// findVolumeForFileShare maps a FileShare created for a claim to its PersistentVolume, which has the same name.
+func (r *VolumeReconciler) findVolumeForFileShare(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetLabels()[ClaimUIDLabelName] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetName()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VolumeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("volume").
+		For(&corev1.PersistentVolume{}).
+		Watches(&crdv1.FileShare{}, handler.EnqueueRequestsFromMapFunc(r.findVolumeForFileShare)).
+		Complete(r)
+}
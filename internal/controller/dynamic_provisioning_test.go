@@ -0,0 +1,177 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Dynamic provisioning", func() {
+	It("Claim should get a dedicated file share deleted with its volume", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "dynamic", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:  "faketoken",
+				RegionID:  2,
+				ProjectID: 5,
+				DynamicProvisioning: &crdv1.DynamicProvisioning{
+					StorageClassName: "gcore-file-share",
+					Network:          crdv1.FileShareNetwork{NetworkID: "5b2b6c1e-3d4f-4a5b-8c6d-7e8f9a0b1c2d"},
+					VolumeType:       "default_share_type",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		provisionerReconciler := NfsProvisionerReconciler{Client: k8sClient}
+		Expect(provisionerReconciler.reconcileDynamicStorageClass(ctx, &provisioner)).To(Succeed())
+		storageClass := storagev1.StorageClass{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "gcore-file-share"}, &storageClass)).To(Succeed())
+		Expect(storageClass.Provisioner).To(Equal(DynamicProvisionerName))
+		Expect(storageClass.Parameters).To(HaveKeyWithValue(NfsProvisionerNameParameter, provisioner.Name))
+		Expect(*storageClass.ReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimDelete))
+		Expect(storageClass.MountOptions).To(Equal(crdv1.DefaultMountOptions))
+
+		claimName := types.NamespacedName{Namespace: DefaultNamespace, Name: "dynamic-claim"}
+		claim := corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: claimName.Name, Namespace: claimName.Namespace},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass.Name,
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1500Mi")},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &claim)).To(Succeed())
+		recorder := record.NewFakeRecorder(10)
+		claimReconciler := ClaimReconciler{Client: k8sClient, Recorder: recorder}
+		_, err := claimReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: claimName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(ProvisioningEventReason)))
+
+		// The claim gets a FileShare sized from its request
+		volumeName := getDynamicVolumeName(&claim)
+		fileShare := crdv1.FileShare{}
+		fileShareName := types.NamespacedName{Namespace: DefaultNamespace, Name: volumeName}
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Spec.Size).To(Equal(2))
+		Expect(fileShare.Spec.ProvisionerRef.Name).To(Equal(provisioner.Name))
+		Expect(fileShare.Spec.VolumeType).To(Equal("default_share_type"))
+		Expect(fileShare.Spec.Metadata).To(HaveKeyWithValue(crdv1.PersistentVolumeMetadataKey, volumeName))
+
+		// The volume is created once the file share is available
+		fileShare.Status.Phase = crdv1.FileSharePhaseAvailable
+		fileShare.Status.Size = 2
+		fileShare.Status.ConnectionPoint = "10.33.20.98:/shares/share-dynamic"
+		Expect(k8sClient.Status().Update(ctx, &fileShare)).To(Succeed())
+		_, err = claimReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: claimName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(ProvisioningSucceededEventReason)))
+		volume := corev1.PersistentVolume{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: volumeName}, &volume)).To(Succeed())
+		Expect(volume.Spec.ClaimRef.UID).To(Equal(claim.UID))
+		Expect(volume.Spec.NFS.Server).To(Equal("10.33.20.98"))
+		Expect(volume.Spec.NFS.Path).To(Equal("/shares/share-dynamic"))
+		Expect(volume.Spec.Capacity.Storage().String()).To(Equal("2Gi"))
+		Expect(volume.Spec.MountOptions).To(Equal(crdv1.DefaultMountOptions))
+
+		// An expanded claim extends its file share before its volume is resized
+		Expect(*storageClass.AllowVolumeExpansion).To(BeTrue())
+		Expect(k8sClient.Get(ctx, claimName, &claim)).To(Succeed())
+		claim.Spec.VolumeName = volume.Name
+		Expect(k8sClient.Update(ctx, &claim)).To(Succeed())
+		claim.Status.Phase = corev1.ClaimBound
+		claim.Status.Capacity = volume.Spec.Capacity
+		Expect(k8sClient.Status().Update(ctx, &claim)).To(Succeed())
+		claim.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("3Gi")
+		Expect(k8sClient.Update(ctx, &claim)).To(Succeed())
+		_, err = claimReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: claimName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(ResizingEventReason)))
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Spec.Size).To(Equal(3))
+		fileShare.Status.Size = 3
+		Expect(k8sClient.Status().Update(ctx, &fileShare)).To(Succeed())
+		Expect(k8sClient.Get(ctx, claimName, &claim)).To(Succeed())
+		claim.Status.Conditions = []corev1.PersistentVolumeClaimCondition{
+			{Type: corev1.PersistentVolumeClaimResizing, Status: corev1.ConditionTrue},
+		}
+		Expect(k8sClient.Status().Update(ctx, &claim)).To(Succeed())
+		_, err = claimReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: claimName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(VolumeResizeSuccessfulEventReason)))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: volumeName}, &volume)).To(Succeed())
+		Expect(volume.Spec.Capacity.Storage().String()).To(Equal("3Gi"))
+		Expect(k8sClient.Get(ctx, claimName, &claim)).To(Succeed())
+		Expect(claim.Status.Capacity.Storage().String()).To(Equal("3Gi"))
+		Expect(claim.Status.Conditions).To(BeEmpty())
+
+		// A released volume is deleted with its FileShare
+		volume.Status.Phase = corev1.VolumeReleased
+		Expect(k8sClient.Status().Update(ctx, &volume)).To(Succeed())
+		volumeReconciler := VolumeReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(10)}
+		for i := 0; i < 3; i++ {
+			_, err = volumeReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: volumeName}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		err = k8sClient.Get(ctx, fileShareName, &fileShare)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		// No controller removes the volume protection finalizer in the test environment
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: volumeName}, &volume); err == nil {
+			Expect(volume.Finalizers).NotTo(ContainElement(DynamicVolumeFinalizer))
+			volume.Finalizers = nil
+			Expect(k8sClient.Update(ctx, &volume)).To(Succeed())
+		}
+
+		// The storage class is deleted when dynamic provisioning is disabled
+		provisioner.Spec.DynamicProvisioning = nil
+		Expect(provisionerReconciler.reconcileDynamicStorageClass(ctx, &provisioner)).To(Succeed())
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Delete(ctx, &claim)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+	})
+	It("Storage class of another owner should not be taken over by dynamic provisioning", func() {
+		storageClass := storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "foreign-file-share"},
+			Provisioner: "nfs.csi.k8s.io",
+		}
+		Expect(k8sClient.Create(ctx, &storageClass)).To(Succeed())
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "dynamic-conflict", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:  "faketoken",
+				RegionID:  2,
+				ProjectID: 5,
+				DynamicProvisioning: &crdv1.DynamicProvisioning{
+					StorageClassName: storageClass.Name,
+					Network:          crdv1.FileShareNetwork{NetworkID: "5b2b6c1e-3d4f-4a5b-8c6d-7e8f9a0b1c2d"},
+					VolumeType:       "default_share_type",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		provisionerReconciler := NfsProvisionerReconciler{Client: k8sClient}
+		Expect(provisionerReconciler.reconcileDynamicStorageClass(ctx, &provisioner)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)).To(Succeed())
+		Expect(storageClass.Provisioner).To(Equal("nfs.csi.k8s.io"))
+		Expect(storageClass.Labels).NotTo(HaveKey(DynamicNfsProvisionerIDLabelName))
+		Expect(provisionerReconciler.updateStatus(ctx, &provisioner)).To(Succeed())
+		condition := meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.ConflictCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(crdv1.StorageClassClaimedReason))
+		Expect(condition.Message).To(ContainSubstring(storageClass.Name))
+		Expect(k8sClient.Delete(ctx, &storageClass)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+	})
+})
@@ -0,0 +1,486 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	"github.com/G-Core/gcorelabscloud-go/gcore/task/v1/tasks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// provisionerRefIndex indexes FileShares by the name of the NfsProvisioner they reference.
+const provisionerRefIndex = ".spec.provisionerRef.name"
+
+// fileShareAvailableStatus is the Gcore Cloud status of a file share ready to be mounted.
+const fileShareAvailableStatus = "available"
+
+const (
+	// fileShareTaskPollInterval is the delay between checks of a running Gcore Cloud task.
+	fileShareTaskPollInterval = 10 * time.Second
+	// fileShareResyncInterval is the delay before refreshing the status of an available file share.
+	fileShareResyncInterval = 10 * time.Minute
+)
+
+// Event reasons emitted on FileShare resources.
+const (
+	FileShareCreatingEventReason   = "Creating"
+	FileShareAdoptedEventReason    = "Adopted"
+	FileShareAvailableEventReason  = "Available"
+	FileShareResizingEventReason   = "Resizing"
+	FileShareDeletingEventReason   = "Deleting"
+	FileShareNotFoundEventReason   = "FileShareNotFound"
+	FileShareTaskFailedEventReason = "TaskFailed"
+)
+
+// FileShareReconciler reconciles a FileShare object
+type FileShareReconciler struct {
+	client.Client
+	Scheme          *runtime.Scheme
+	Recorder        record.EventRecorder
+	FileShareClient gcoreclient.FileShareManager
+}
+
+//+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=fileshares,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=fileshares/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=fileshares/finalizers,verbs=update
+
+func (r *FileShareReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
+	log := log.FromContext(ctx)
+
+	fileShare := crdv1.FileShare{}
+	if err := r.Client.Get(ctx, req.NamespacedName, &fileShare); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get FileShare customer resource")
+		return ctrl.Result{}, err
+	}
+
+	if !controllerutil.ContainsFinalizer(&fileShare, crdv1.FileShareFinalizer) && fileShare.DeletionTimestamp.IsZero() {
+		controllerutil.AddFinalizer(&fileShare, crdv1.FileShareFinalizer)
+		if err := r.Client.Update(ctx, &fileShare, &client.UpdateOptions{}); err != nil {
+			log.Error(err, "Failed to update FileShare to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+	defer func() {
+		if controllerutil.ContainsFinalizer(&fileShare, crdv1.FileShareFinalizer) {
+			if err := r.Client.Status().Update(ctx, &fileShare, &client.SubResourceUpdateOptions{}); err != nil {
+				log.Error(err, "Failed to update FileShare status")
+				reterr = kerrors.NewAggregate([]error{reterr, err})
+			}
+		}
+	}()
+
+	provisioner := crdv1.NfsProvisioner{}
+	provisionerName := types.NamespacedName{Namespace: fileShare.Namespace, Name: fileShare.Spec.ProvisionerRef.Name}
+	if err := r.Client.Get(ctx, provisionerName, &provisioner); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if !fileShare.DeletionTimestamp.IsZero() && (fileShare.Status.ID == "" || fileShare.Spec.ReclaimPolicy == crdv1.FileShareReclaimRetain) {
+			controllerutil.RemoveFinalizer(&fileShare, crdv1.FileShareFinalizer)
+			return ctrl.Result{}, r.Client.Update(ctx, &fileShare)
+		}
+		// The provisioner holds the credentials of the file share, it is reconciled again when the provisioner is created.
+		r.setCondition(&fileShare, metav1.ConditionFalse, crdv1.ProvisionerNotFoundReason,
+			fmt.Sprintf("NfsProvisioner %s not found", provisionerName.Name))
+		return ctrl.Result{}, nil
+	}
+
+	if !fileShare.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, &provisioner, &fileShare)
+	}
+	return r.reconcileNormal(ctx, &provisioner, &fileShare)
+}
+
+func (r *FileShareReconciler) setCondition(fileShare *crdv1.FileShare, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&fileShare.Status.Conditions, metav1.Condition{
+		Type:               crdv1.ReadyCondition,
+		Status:             status,
+		ObservedGeneration: fileShare.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setCloudAPIFailed reports a failed Gcore Cloud API request, the request is retried with backoff.
+func (r *FileShareReconciler) setCloudAPIFailed(fileShare *crdv1.FileShare, operation string, err error) (ctrl.Result, error) {
+	r.setCondition(fileShare, metav1.ConditionFalse, crdv1.CloudAPIFailedReason, fmt.Sprintf("%s: %v", operation, err))
+	return ctrl.Result{}, fmt.Errorf("%s: %w", operation, err)
+}
+
+// pollTask checks the running task of the file share and returns whether it is finished. A failed task
+// is cleared from the status and reported in the Ready condition.
+func (r *FileShareReconciler) pollTask(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *crdv1.FileShare) (*tasks.Task, bool, error) {
+	start := time.Now()
+	task, err := r.FileShareClient.GetTask(ctx, provisioner, fileShare.Status.TaskID)
+	observeCloudAPIRequest("get_task", start, err)
+	if err != nil {
+		return nil, false, err
+	}
+	switch task.State {
+	case tasks.TaskStateFinished:
+		fileShare.Status.TaskID = ""
+		return task, true, nil
+	case tasks.TaskStateError:
+		message := fmt.Sprintf("Task %s failed", task.ID)
+		if task.Error != nil {
+			message = fmt.Sprintf("%s: %s", message, *task.Error)
+		}
+		r.Recorder.Event(fileShare, corev1.EventTypeWarning, FileShareTaskFailedEventReason, message)
+		fileShare.Status.TaskID = ""
+		fileShare.Status.Phase = crdv1.FileSharePhaseFailed
+		fileShare.Status.ObservedGeneration = fileShare.Generation
+		r.setCondition(fileShare, metav1.ConditionFalse, crdv1.TaskFailedReason, message)
+		return task, false, nil
+	default:
+		return task, false, nil
+	}
+}
+
+// findFileShare returns the ID of the file share created for the FileShare, it is found by the UID
+// in its metadata when the create task ID was not recorded.
+func (r *FileShareReconciler) findFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *crdv1.FileShare) (string, error) {
+	start := time.Now()
+	projectFileShares, err := r.FileShareClient.ListFileShares(ctx, provisioner)
+	observeCloudAPIRequest("list_file_shares", start, err)
+	if err != nil {
+		return "", err
+	}
+	for _, projectFileShare := range projectFileShares {
+		if uid, found := projectFileShare.Metadata[crdv1.FileShareUIDMetadataKey]; found && fmt.Sprint(uid) == string(fileShare.UID) {
+			return projectFileShare.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *FileShareReconciler) createFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *crdv1.FileShare) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	metadata := map[string]string{crdv1.FileShareUIDMetadataKey: string(fileShare.UID)}
+	for key, value := range fileShare.Spec.Metadata {
+		metadata[key] = value
+	}
+	protocol := fileShare.Spec.Protocol
+	if protocol == "" {
+		protocol = "NFS"
+	}
+	opts := gcoreclient.CreateFileShareOpts{
+		CreateOpts: file_shares.CreateOpts{
+			Name:     fileShare.CloudName(),
+			Protocol: protocol,
+			Size:     fileShare.Spec.Size,
+			Network: file_shares.FileShareNetworkOpts{
+				NetworkID: fileShare.Spec.Network.NetworkID,
+				SubnetID:  fileShare.Spec.Network.SubnetID,
+			},
+			Metadata: metadata,
+		},
+		VolumeType: fileShare.Spec.VolumeType,
+	}
+	start := time.Now()
+	taskID, err := r.FileShareClient.CreateFileShare(ctx, provisioner, opts)
+	observeCloudAPIRequest("create_file_share", start, err)
+	if err != nil {
+		return r.setCloudAPIFailed(fileShare, "create file share", err)
+	}
+	log.Info("Creating file share", "name", opts.Name, "size", opts.Size, "taskID", taskID)
+	r.Recorder.Eventf(fileShare, corev1.EventTypeNormal, FileShareCreatingEventReason,
+		"Creating file share %s of %d GiB", opts.Name, opts.Size)
+	fileShare.Status.TaskID = taskID
+	fileShare.Status.Phase = crdv1.FileSharePhaseCreating
+	r.setCondition(fileShare, metav1.ConditionFalse, crdv1.FileShareCreatingReason, fmt.Sprintf("Task %s is creating the file share", taskID))
+	return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+}
+
+func (r *FileShareReconciler) reconcileNormal(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *crdv1.FileShare) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if fileShare.Status.TaskID != "" {
+		task, finished, err := r.pollTask(ctx, provisioner, fileShare)
+		if err != nil {
+			return r.setCloudAPIFailed(fileShare, "get task", err)
+		}
+		if !finished {
+			if fileShare.Status.TaskID == "" {
+				return ctrl.Result{}, nil
+			}
+			return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+		}
+		if fileShare.Status.ID == "" {
+			fileShare.Status.ID, err = file_shares.ExtractFileShareIDFromTask(task)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+	// A failed task is retried once the spec changes.
+	if fileShare.Status.Phase == crdv1.FileSharePhaseFailed && fileShare.Status.ObservedGeneration == fileShare.Generation {
+		return ctrl.Result{}, nil
+	}
+
+	if fileShare.Status.ID == "" {
+		id := fileShare.Spec.ID
+		if id == "" {
+			var err error
+			if id, err = r.findFileShare(ctx, provisioner, fileShare); err != nil {
+				return r.setCloudAPIFailed(fileShare, "list file shares", err)
+			}
+		}
+		if id == "" {
+			return r.createFileShare(ctx, provisioner, fileShare)
+		}
+		r.Recorder.Eventf(fileShare, corev1.EventTypeNormal, FileShareAdoptedEventReason, "Adopted file share %s", id)
+		fileShare.Status.ID = id
+	}
+
+	start := time.Now()
+	cloudFileShare, err := r.FileShareClient.GetFileShare(ctx, provisioner, fileShare.Status.ID)
+	observeCloudAPIRequest("get_file_share", start, err)
+	if gcoreclient.IsNotFound(err) {
+		// The file share is not recreated, it would lose the data of the volumes.
+		message := fmt.Sprintf("File share %s not found in Gcore Cloud", fileShare.Status.ID)
+		if fileShare.Status.Phase != crdv1.FileSharePhaseFailed {
+			r.Recorder.Event(fileShare, corev1.EventTypeWarning, FileShareNotFoundEventReason, message)
+		}
+		fileShare.Status.Phase = crdv1.FileSharePhaseFailed
+		fileShare.Status.ConnectionPoint = ""
+		fileShare.Status.ObservedGeneration = fileShare.Generation
+		r.setCondition(fileShare, metav1.ConditionFalse, crdv1.FileShareNotFoundReason, message)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return r.setCloudAPIFailed(fileShare, "get file share", err)
+	}
+	fileShare.Status.Size = cloudFileShare.Size
+	fileShare.Status.CloudStatus = cloudFileShare.Status
+	fileShare.Status.ConnectionPoint = cloudFileShare.ConnectionPoint
+	fileShare.Status.ObservedGeneration = fileShare.Generation
+
+	if cloudFileShare.Status != fileShareAvailableStatus || cloudFileShare.ConnectionPoint == "" {
+		if fileShare.Status.Phase != crdv1.FileSharePhaseResizing {
+			fileShare.Status.Phase = crdv1.FileSharePhaseCreating
+		}
+		r.setCondition(fileShare, metav1.ConditionFalse, crdv1.FileShareCreatingReason,
+			fmt.Sprintf("File share is %s", cloudFileShare.Status))
+		return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+	}
+
+	if fileShare.Spec.Size > cloudFileShare.Size {
+		start := time.Now()
+		taskID, err := r.FileShareClient.ExtendFileShare(ctx, provisioner, cloudFileShare.ID, fileShare.Spec.Size)
+		observeCloudAPIRequest("extend_file_share", start, err)
+		if err != nil {
+			return r.setCloudAPIFailed(fileShare, "extend file share", err)
+		}
+		log.Info("Extending file share", "id", cloudFileShare.ID, "size", cloudFileShare.Size, "newSize", fileShare.Spec.Size, "taskID", taskID)
+		r.Recorder.Eventf(fileShare, corev1.EventTypeNormal, FileShareResizingEventReason,
+			"Extending file share from %d GiB to %d GiB", cloudFileShare.Size, fileShare.Spec.Size)
+		fileShare.Status.TaskID = taskID
+		fileShare.Status.Phase = crdv1.FileSharePhaseResizing
+		r.setCondition(fileShare, metav1.ConditionFalse, crdv1.FileShareResizingReason, fmt.Sprintf("Task %s is extending the file share", taskID))
+		return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+	}
+
+	// The NfsProvisioner deploys the provisioner of the file share and reports its storage class.
+	fileShare.Status.StorageClassName = ""
+	selected := false
+	for _, fileShareStatus := range provisioner.Status.FileShares {
+		if fileShareStatus.ID == cloudFileShare.ID {
+			fileShare.Status.StorageClassName = fileShareStatus.StorageClassName
+			selected = true
+		}
+	}
+	requeueAfter := fileShareResyncInterval
+	if !selected && isAccessRulesFromNodes(provisioner) {
+		if !r.reconcileAccessRules(ctx, provisioner, fileShare) {
+			requeueAfter = fileShareRetryBaseDelay
+		}
+	} else {
+		fileShare.Status.AccessRules = nil
+		fileShare.Status.LastAccessRulesChange = nil
+		meta.RemoveStatusCondition(&fileShare.Status.Conditions, crdv1.AccessRulesSyncedCondition)
+	}
+	if fileShare.Status.Phase != crdv1.FileSharePhaseAvailable {
+		r.Recorder.Eventf(fileShare, corev1.EventTypeNormal, FileShareAvailableEventReason,
+			"File share is available at %s", cloudFileShare.ConnectionPoint)
+	}
+	fileShare.Status.Phase = crdv1.FileSharePhaseAvailable
+	r.setCondition(fileShare, metav1.ConditionTrue, crdv1.FileShareAvailableReason, "")
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileAccessRules makes the access rules of a file share not selected by the NfsProvisioner, such as the file
+// share of a claim, match the nodes. It returns whether the access rules are synced.
+func (r *FileShareReconciler) reconcileAccessRules(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *crdv1.FileShare) bool {
+	log := log.FromContext(ctx)
+
+	setSyncedCondition := func(status metav1.ConditionStatus, reason string, message string) {
+		meta.SetStatusCondition(&fileShare.Status.Conditions, metav1.Condition{
+			Type:               crdv1.AccessRulesSyncedCondition,
+			Status:             status,
+			ObservedGeneration: fileShare.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+	desired, err := getDesiredAccessRules(ctx, r.Client, provisioner.Spec.AccessRules)
+	if err == nil {
+		var change *crdv1.AccessRulesChange
+		change, err = syncAccessRules(ctx, r.FileShareClient, provisioner, fileShare.Status.ID, desired)
+		if change != nil {
+			log.Info("Changed access rules of file share", "added", formatAccessRules(change.Added), "removed", formatAccessRules(change.Removed))
+			r.Recorder.Eventf(fileShare, corev1.EventTypeNormal, AccessRulesChangedEventReason,
+				"Access rules changed, added: [%s], removed: [%s]", formatAccessRules(change.Added), formatAccessRules(change.Removed))
+			fileShare.Status.LastAccessRulesChange = change
+		}
+	}
+	if err != nil {
+		log.Error(err, "failed sync access rules")
+		r.Recorder.Eventf(fileShare, corev1.EventTypeWarning, AccessRulesSyncFailedEventReason, "Failed to sync access rules: %v", err)
+		setSyncedCondition(metav1.ConditionFalse, crdv1.AccessRulesSyncFailedReason, err.Error())
+		return false
+	}
+	fileShare.Status.AccessRules = desired
+	setSyncedCondition(metav1.ConditionTrue, crdv1.AccessRulesSyncedReason, "")
+	return true
+}
+
+// reconcileDelete deletes the file share in Gcore Cloud unless its reclaim policy is Retain, the finalizer
+// is removed once the delete task is finished. A file share being created is deleted after its creation.
+func (r *FileShareReconciler) reconcileDelete(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *crdv1.FileShare) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if fileShare.Status.TaskID != "" {
+		task, finished, err := r.pollTask(ctx, provisioner, fileShare)
+		if err != nil {
+			return r.setCloudAPIFailed(fileShare, "get task", err)
+		}
+		if !finished && fileShare.Status.TaskID != "" {
+			return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+		}
+		if finished && fileShare.Status.ID == "" {
+			fileShare.Status.ID, err = file_shares.ExtractFileShareIDFromTask(task)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+	// A failed create task leaves no file share to delete.
+	if fileShare.Status.ID == "" || fileShare.Spec.ReclaimPolicy == crdv1.FileShareReclaimRetain {
+		controllerutil.RemoveFinalizer(fileShare, crdv1.FileShareFinalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, fileShare)
+	}
+
+	start := time.Now()
+	cloudFileShare, err := r.FileShareClient.GetFileShare(ctx, provisioner, fileShare.Status.ID)
+	observeCloudAPIRequest("get_file_share", start, err)
+	if gcoreclient.IsNotFound(err) {
+		log.Info("File share deleted", "id", fileShare.Status.ID)
+		controllerutil.RemoveFinalizer(fileShare, crdv1.FileShareFinalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, fileShare)
+	}
+	if err != nil {
+		return r.setCloudAPIFailed(fileShare, "get file share", err)
+	}
+	fileShare.Status.Phase = crdv1.FileSharePhaseDeleting
+	if cloudFileShare.Status == "deleting" {
+		return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+	}
+	start = time.Now()
+	taskID, err := r.FileShareClient.DeleteFileShare(ctx, provisioner, fileShare.Status.ID)
+	observeCloudAPIRequest("delete_file_share", start, err)
+	if err != nil && !gcoreclient.IsNotFound(err) {
+		return r.setCloudAPIFailed(fileShare, "delete file share", err)
+	}
+	log.Info("Deleting file share", "id", fileShare.Status.ID, "taskID", taskID)
+	r.Recorder.Eventf(fileShare, corev1.EventTypeNormal, FileShareDeletingEventReason, "Deleting file share %s", fileShare.Status.ID)
+	fileShare.Status.TaskID = taskID
+	r.setCondition(fileShare, metav1.ConditionFalse, crdv1.FileShareDeletingReason, fmt.Sprintf("Task %s is deleting the file share", taskID))
+	return ctrl.Result{RequeueAfter: fileShareTaskPollInterval}, nil
+}
+
+// findFileSharesForProvisioner maps a NfsProvisioner to the FileShares referencing it, so the FileShares
+// get the storage classes reported by the provisioner and are reconciled when it is created.
+func (r *FileShareReconciler) findFileSharesForProvisioner(ctx context.Context, obj client.Object) []reconcile.Request {
+	fileShareList := crdv1.FileShareList{}
+	if err := r.Client.List(ctx, &fileShareList, client.InNamespace(obj.GetNamespace()), client.MatchingFields{provisionerRefIndex: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "failed list file shares for provisioner", "provisioner", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(fileShareList.Items))
+	for _, fileShare := range fileShareList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: fileShare.Namespace, Name: fileShare.Name},
+		})
+	}
+	return requests
+}
+
+// findFileSharesForNode maps a Node to the FileShares, so their access rules follow the nodes.
+func (r *FileShareReconciler) findFileSharesForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	fileShareList := crdv1.FileShareList{}
+	if err := r.Client.List(ctx, &fileShareList); err != nil {
+		log.FromContext(ctx).Error(err, "failed list file shares for node", "node", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(fileShareList.Items))
+	for _, fileShare := range fileShareList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: fileShare.Namespace, Name: fileShare.Name},
+		})
+	}
+	return requests
+}
+
+func getProvisionerRef(obj client.Object) []string {
+	return []string{obj.(*crdv1.FileShare).Spec.ProvisionerRef.Name}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FileShareReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &crdv1.FileShare{}, provisionerRefIndex, getProvisionerRef); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&crdv1.FileShare{}).
+		Watches(&crdv1.NfsProvisioner{}, handler.EnqueueRequestsFromMapFunc(r.findFileSharesForProvisioner)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.findFileSharesForNode), builder.WithPredicates(nodeAccessChangedPredicate)).
+		Complete(r)
+}
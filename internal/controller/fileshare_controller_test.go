@@ -0,0 +1,202 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("FileShare controller", func() {
+	It("File share should be created, extended and deleted", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "fileshare-provisioner", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:          "faketoken",
+				APIURL:            "http://127.0.0.1",
+				RegionID:          2,
+				ProjectID:         5,
+				FileShareSelector: &crdv1.FileShareSelector{IDs: []string{"00000000-0000-4000-8000-999999999999"}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		fileShareName := types.NamespacedName{Namespace: DefaultNamespace, Name: "managed-file-share"}
+		fileShare := crdv1.FileShare{
+			ObjectMeta: metav1.ObjectMeta{Name: fileShareName.Name, Namespace: fileShareName.Namespace},
+			Spec: crdv1.FileShareSpec{
+				ProvisionerRef: corev1.LocalObjectReference{Name: provisioner.Name},
+				Size:           10,
+				VolumeType:     "default_share_type",
+				Network:        crdv1.FileShareNetwork{NetworkID: "5b2b6c1e-3d4f-4a5b-8c6d-7e8f9a0b1c2d"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &fileShare)).To(Succeed())
+
+		fileShareClient := gcoreclient.MockFileShareManager{KeepTasksRunning: true}
+		reconciler := FileShareReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100), FileShareClient: &fileShareClient}
+		reconcileFileShare := func() ctrl.Result {
+			result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		// The create task is polled until the file share has a connection point
+		Expect(reconcileFileShare().RequeueAfter).To(Equal(fileShareTaskPollInterval))
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Finalizers).To(ContainElement(crdv1.FileShareFinalizer))
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseCreating))
+		Expect(fileShare.Status.TaskID).NotTo(BeEmpty())
+		Expect(fileShareClient.FileShares).To(HaveLen(1))
+		Expect(fileShareClient.FileShares[0].Name).To(Equal(fileShareName.Name))
+		Expect(fileShareClient.FileShares[0].VolumeType).To(Equal("default_share_type"))
+		Expect(fileShareClient.FileShares[0].Metadata).To(HaveKeyWithValue(crdv1.FileShareUIDMetadataKey, string(fileShare.UID)))
+		Expect(reconcileFileShare().RequeueAfter).To(Equal(fileShareTaskPollInterval))
+		fileShareClient.FinishTasks()
+		Expect(reconcileFileShare().RequeueAfter).To(Equal(fileShareResyncInterval))
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseAvailable))
+		Expect(fileShare.Status.ID).To(Equal(fileShareClient.FileShares[0].ID))
+		Expect(fileShare.Status.ConnectionPoint).NotTo(BeEmpty())
+		Expect(fileShare.Status.TaskID).To(BeEmpty())
+		Expect(meta.IsStatusConditionTrue(fileShare.Status.Conditions, crdv1.ReadyCondition)).To(BeTrue())
+
+		// The managed file share is selected by its provisioner despite the file share selector
+		provisionerReconciler := NfsProvisionerReconciler{Client: k8sClient}
+		managedFileShareIDs, err := provisionerReconciler.getManagedFileShareIDs(ctx, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		selected, err := provisionerReconciler.selectFileShares(&provisioner, fileShareClient.FileShares, managedFileShareIDs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveLen(1))
+
+		// A larger size extends the file share
+		fileShare.Spec.Size = 20
+		Expect(k8sClient.Update(ctx, &fileShare)).To(Succeed())
+		reconcileFileShare()
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseResizing))
+		fileShareClient.FinishTasks()
+		reconcileFileShare()
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseAvailable))
+		Expect(fileShare.Status.Size).To(Equal(20))
+
+		// The file share is deleted in Gcore Cloud before the finalizer is removed
+		Expect(k8sClient.Delete(ctx, &fileShare)).To(Succeed())
+		reconcileFileShare()
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseDeleting))
+		fileShareClient.FinishTasks()
+		reconcileFileShare()
+		Expect(fileShareClient.FileShares).To(BeEmpty())
+		err = k8sClient.Get(ctx, fileShareName, &fileShare)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+	})
+
+	It("File share deleted out of band should not be recreated", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "vanished-provisioner", Namespace: DefaultNamespace},
+			Spec:       crdv1.NfsProvisionerSpec{APIToken: "faketoken", RegionID: 2, ProjectID: 5},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		fileShareName := types.NamespacedName{Namespace: DefaultNamespace, Name: "vanished-file-share"}
+		fileShare := crdv1.FileShare{
+			ObjectMeta: metav1.ObjectMeta{Name: fileShareName.Name, Namespace: fileShareName.Namespace},
+			Spec: crdv1.FileShareSpec{
+				ProvisionerRef: corev1.LocalObjectReference{Name: provisioner.Name},
+				Size:           10,
+				Network:        crdv1.FileShareNetwork{NetworkID: "5b2b6c1e-3d4f-4a5b-8c6d-7e8f9a0b1c2d"},
+				ReclaimPolicy:  crdv1.FileShareReclaimRetain,
+			},
+		}
+		Expect(k8sClient.Create(ctx, &fileShare)).To(Succeed())
+		fileShareClient := gcoreclient.MockFileShareManager{}
+		reconciler := FileShareReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100), FileShareClient: &fileShareClient}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseAvailable))
+
+		fileShareClient.FileShares = nil
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, fileShareName, &fileShare)).To(Succeed())
+		Expect(fileShare.Status.Phase).To(Equal(crdv1.FileSharePhaseFailed))
+		Expect(meta.FindStatusCondition(fileShare.Status.Conditions, crdv1.ReadyCondition).Reason).To(Equal(crdv1.FileShareNotFoundReason))
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fileShareClient.FileShares).To(BeEmpty())
+
+		// A retained file share is left in Gcore Cloud
+		Expect(k8sClient.Delete(ctx, &fileShare)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Get(ctx, fileShareName, &fileShare)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+	})
+
+	It("Deleted provisioner should be kept until its FileShares are deleted", func() {
+		provisionerName := types.NamespacedName{Namespace: DefaultNamespace, Name: "deleted-provisioner"}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       provisionerName.Name,
+				Namespace:  provisionerName.Namespace,
+				Finalizers: []string{crdv1.NfsProvisionerFinalizer},
+			},
+			Spec: crdv1.NfsProvisionerSpec{APIToken: "faketoken", RegionID: 2, ProjectID: 5},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		fileShareName := types.NamespacedName{Namespace: DefaultNamespace, Name: "deleted-provisioner-file-share"}
+		fileShare := crdv1.FileShare{
+			ObjectMeta: metav1.ObjectMeta{Name: fileShareName.Name, Namespace: fileShareName.Namespace},
+			Spec: crdv1.FileShareSpec{
+				ProvisionerRef: corev1.LocalObjectReference{Name: provisioner.Name},
+				Size:           10,
+				Network:        crdv1.FileShareNetwork{NetworkID: "5b2b6c1e-3d4f-4a5b-8c6d-7e8f9a0b1c2d"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &fileShare)).To(Succeed())
+		fileShareClient := gcoreclient.MockFileShareManager{}
+		reconciler := FileShareReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100), FileShareClient: &fileShareClient}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fileShareClient.FileShares).To(HaveLen(1))
+
+		// Both are deleted, as on namespace deletion, and the provisioner waits for the FileShare
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &fileShare)).To(Succeed())
+		provisionerReconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100)}
+		_, err = provisionerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		condition := meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.DeletionBlockedCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(crdv1.FileSharesReferencedReason))
+		Expect(condition.Message).To(ContainSubstring(fileShareName.Name))
+
+		// The FileShare deletes its file share with the credentials of the provisioner
+		for i := 0; i < 3; i++ {
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fileShareName})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(fileShareClient.FileShares).To(BeEmpty())
+		err = k8sClient.Get(ctx, fileShareName, &fileShare)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		_, err = provisionerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Get(ctx, provisionerName, &provisioner)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,136 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fileShareMatcher matches file shares against a compiled FileShareSelector.
+type fileShareMatcher struct {
+	ids           StringSet
+	excludeIDs    StringSet
+	includeNames  []*regexp.Regexp
+	excludeNames  []*regexp.Regexp
+	matchMetadata map[string]string
+}
+
+func newFileShareMatcher(selector *crdv1.FileShareSelector) (*fileShareMatcher, error) {
+	matcher := fileShareMatcher{
+		ids:        StringSet{},
+		excludeIDs: StringSet{},
+	}
+	if selector == nil {
+		return &matcher, nil
+	}
+	for _, id := range selector.IDs {
+		matcher.ids[id] = true
+	}
+	for _, id := range selector.ExcludeIDs {
+		matcher.excludeIDs[id] = true
+	}
+	for _, name := range selector.IncludeNames {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect file share selector include name %s: %w", name, err)
+		}
+		matcher.includeNames = append(matcher.includeNames, re)
+	}
+	for _, name := range selector.ExcludeNames {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect file share selector exclude name %s: %w", name, err)
+		}
+		matcher.excludeNames = append(matcher.excludeNames, re)
+	}
+	matcher.matchMetadata = selector.MatchMetadata
+	return &matcher, nil
+}
+
+func (m *fileShareMatcher) matches(fileShare *file_shares.FileShare) bool {
+	if len(m.ids) > 0 && !m.ids[fileShare.ID] {
+		return false
+	}
+	if m.excludeIDs[fileShare.ID] {
+		return false
+	}
+	if len(m.includeNames) > 0 {
+		included := false
+		for _, re := range m.includeNames {
+			if re.MatchString(fileShare.Name) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, re := range m.excludeNames {
+		if re.MatchString(fileShare.Name) {
+			return false
+		}
+	}
+	for key, value := range m.matchMetadata {
+		fileShareValue, found := fileShare.Metadata[key]
+		if !found || fmt.Sprint(fileShareValue) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selectFileShares returns the file shares matching the provisioner file share selector and
+// the file shares of the FileShare resources referencing the provisioner. The file shares
+// dynamically provisioned for a claim are never selected.
+func (r *NfsProvisionerReconciler) selectFileShares(provisioner *crdv1.NfsProvisioner, fileShares []file_shares.FileShare, managedFileShareIDs StringSet) ([]file_shares.FileShare, error) {
+	matcher, err := newFileShareMatcher(provisioner.Spec.FileShareSelector)
+	if err != nil {
+		return nil, err
+	}
+	selectedFileShares := []file_shares.FileShare{}
+	for i := range fileShares {
+		if _, dynamic := fileShares[i].Metadata[crdv1.PersistentVolumeMetadataKey]; dynamic {
+			continue
+		}
+		if managedFileShareIDs[fileShares[i].ID] || matcher.matches(&fileShares[i]) {
+			selectedFileShares = append(selectedFileShares, fileShares[i])
+		}
+	}
+	return selectedFileShares, nil
+}
+
+// getManagedFileShareIDs returns the IDs of the file shares of the FileShare resources referencing the provisioner.
+func (r *NfsProvisionerReconciler) getManagedFileShareIDs(ctx context.Context, provisioner *crdv1.NfsProvisioner) (StringSet, error) {
+	fileShareList := crdv1.FileShareList{}
+	if err := r.Client.List(ctx, &fileShareList, client.InNamespace(provisioner.Namespace)); err != nil {
+		return nil, err
+	}
+	managedFileShareIDs := StringSet{}
+	for _, fileShare := range fileShareList.Items {
+		if fileShare.Spec.ProvisionerRef.Name == provisioner.Name && fileShare.Status.ID != "" {
+			managedFileShareIDs[fileShare.Status.ID] = true
+		}
+	}
+	return managedFileShareIDs, nil
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File share selector", func() {
+	fileShares := []file_shares.FileShare{
+		{ID: "0a1d8f4e-7d3c-4b8e-9b1e-3f6a2b0c9d11", Name: "cluster-a-data", Metadata: map[string]interface{}{"cluster": "a"}},
+		{ID: "1b2e9f5f-8e4d-4c9f-8c2f-4a7b3c1d0e22", Name: "cluster-a-tmp", Metadata: map[string]interface{}{"cluster": "a"}},
+		{ID: "2c3f0a6a-9f5e-4da0-9d3a-5b8c4d2e1f33", Name: "cluster-b-data", Metadata: map[string]interface{}{"cluster": "b"}},
+	}
+	reconciler := NfsProvisionerReconciler{}
+
+	It("Select all file shares without selector", func() {
+		selected, err := reconciler.selectFileShares(&crdv1.NfsProvisioner{}, fileShares, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveLen(3))
+	})
+	It("Select file shares by name patterns", func() {
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				FileShareSelector: &crdv1.FileShareSelector{
+					IncludeNames: []string{"^cluster-a-"},
+					ExcludeNames: []string{"-tmp$"},
+				},
+			},
+		}
+		selected, err := reconciler.selectFileShares(&provisioner, fileShares, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveLen(1))
+		Expect(selected[0].Name).To(Equal("cluster-a-data"))
+	})
+	It("Select file shares by IDs and metadata", func() {
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				FileShareSelector: &crdv1.FileShareSelector{
+					IDs:           []string{fileShares[1].ID, fileShares[2].ID},
+					MatchMetadata: map[string]string{"cluster": "b"},
+				},
+			},
+		}
+		selected, err := reconciler.selectFileShares(&provisioner, fileShares, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveLen(1))
+		Expect(selected[0].ID).To(Equal(fileShares[2].ID))
+	})
+	It("Exclude file shares by IDs", func() {
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				FileShareSelector: &crdv1.FileShareSelector{
+					ExcludeIDs: []string{fileShares[0].ID},
+				},
+			},
+		}
+		selected, err := reconciler.selectFileShares(&provisioner, fileShares, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveLen(2))
+	})
+	It("Select file shares managed by FileShare resources", func() {
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				FileShareSelector: &crdv1.FileShareSelector{
+					MatchMetadata: map[string]string{"cluster": "b"},
+				},
+			},
+		}
+		selected, err := reconciler.selectFileShares(&provisioner, fileShares, StringSet{fileShares[0].ID: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveLen(2))
+		Expect(selected[0].ID).To(Equal(fileShares[0].ID))
+	})
+})
@@ -0,0 +1,178 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Helm values of the provisioner chart are merged with the following precedence, from lowest to highest:
+//  1. controller defaults,
+//  2. spec.valuesFrom in the listed order,
+//  3. spec.values,
+//  4. spec.fileShareOverrides values matching the file share, in the listed order,
+//  5. storage class parameters and mount options of the spec and the matching file share overrides,
+//  6. values managed by the controller (NFS server and path, storage class name, default class, volume expansion, labels
+//     and the mount options annotation of the provisioner pods).
+
+// getDefaultValues returns the controller default values of the provisioner chart.
+func (r *NfsProvisionerReconciler) getDefaultValues(provisioner *crdv1.NfsProvisioner) map[string]interface{} {
+	values := map[string]interface{}{
+		"storageClass": map[string]interface{}{
+			"accessModes":       "ReadWriteMany",
+			"reclaimPolicy":     string(defaultReclaimPolicy),
+			"archiveOnDelete":   defaultArchiveOnDelete,
+			"volumeBindingMode": string(defaultVolumeBindingMode),
+		},
+		"image": map[string]interface{}{
+			"tag": provisioner.Spec.ImageVersion,
+		},
+	}
+	return mergeValues(values, getMountOptionValues(crdv1.DefaultMountOptions))
+}
+
+// getManagedValues returns the values the controller relies on, they can not be overridden by the user.
+// defaultClass is managed, so at most one storage class of the provisioner is the cluster default.
+func (r *NfsProvisionerReconciler) getManagedValues(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, nfsServer string, nfsPath string, defaultClass bool) map[string]interface{} {
+	return map[string]interface{}{
+		"nfs": map[string]interface{}{
+			"server": nfsServer,
+			"path":   nfsPath,
+		},
+		"storageClass": map[string]interface{}{
+			"name":                 r.getStorageClassName(provisioner, fileShare),
+			"defaultClass":         defaultClass,
+			"allowVolumeExpansion": true,
+		},
+		"labels": map[string]interface{}{
+			NfsProvisionerIDLabelName: string(provisioner.UID),
+			FileShareIDLabelName:      fileShare.ID,
+			FileShareNameLabelName:    fileShare.Name,
+		},
+	}
+}
+
+// getProvisionerValues returns the user values of the provisioner merged from spec.valuesFrom and spec.values.
+func (r *NfsProvisionerReconciler) getProvisionerValues(ctx context.Context, provisioner *crdv1.NfsProvisioner) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, valuesRef := range provisioner.Spec.ValuesFrom {
+		refValues, err := r.getReferencedValues(ctx, provisioner, valuesRef)
+		if err != nil {
+			return nil, err
+		}
+		values = mergeValues(values, refValues)
+	}
+	if provisioner.Spec.Values != nil && len(provisioner.Spec.Values.Raw) > 0 {
+		specValues := map[string]interface{}{}
+		if err := json.Unmarshal(provisioner.Spec.Values.Raw, &specValues); err != nil {
+			return nil, fmt.Errorf("parse spec.values: %w", err)
+		}
+		values = mergeValues(values, specValues)
+	}
+	return values, nil
+}
+
+// getFileShareOverrideValues returns the values of the file share overrides matching the file share.
+// Their storage class parameters and mount options are merged by getStorageClassParameters and getMountOptions.
+func (r *NfsProvisionerReconciler) getFileShareOverrideValues(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for i, override := range provisioner.Spec.FileShareOverrides {
+		if !override.Matches(fileShare.ID, fileShare.Name) {
+			continue
+		}
+		if override.Values != nil && len(override.Values.Raw) > 0 {
+			overrideValues := map[string]interface{}{}
+			if err := json.Unmarshal(override.Values.Raw, &overrideValues); err != nil {
+				return nil, fmt.Errorf("parse spec.fileShareOverrides[%d].values: %w", i, err)
+			}
+			values = mergeValues(values, overrideValues)
+		}
+	}
+	return values, nil
+}
+
+func (r *NfsProvisionerReconciler) getReferencedValues(ctx context.Context, provisioner *crdv1.NfsProvisioner, valuesRef crdv1.ValuesReference) (map[string]interface{}, error) {
+	valuesKey := valuesRef.ValuesKey
+	if valuesKey == "" {
+		valuesKey = crdv1.DefaultValuesKey
+	}
+	name := types.NamespacedName{Namespace: provisioner.Namespace, Name: valuesRef.Name}
+	var data []byte
+	var found bool
+	switch valuesRef.Kind {
+	case crdv1.ValuesKindConfigMap:
+		configMap := corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, name, &configMap); err != nil {
+			if apierrors.IsNotFound(err) && valuesRef.Optional {
+				return map[string]interface{}{}, nil
+			}
+			return nil, fmt.Errorf("get values configmap %s: %w", name, err)
+		}
+		var value string
+		value, found = configMap.Data[valuesKey]
+		data = []byte(value)
+	case crdv1.ValuesKindSecret:
+		secret := corev1.Secret{}
+		if err := r.Client.Get(ctx, name, &secret); err != nil {
+			if apierrors.IsNotFound(err) && valuesRef.Optional {
+				return map[string]interface{}{}, nil
+			}
+			return nil, fmt.Errorf("get values secret %s: %w", name, err)
+		}
+		data, found = secret.Data[valuesKey]
+	default:
+		return nil, fmt.Errorf("unsupported values kind %s", valuesRef.Kind)
+	}
+	if !found {
+		if valuesRef.Optional {
+			return map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("values %s %s has no key %s", valuesRef.Kind, name, valuesKey)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse values %s %s key %s: %w", valuesRef.Kind, name, valuesKey, err)
+	}
+	return values, nil
+}
+
+// mergeValues returns a deep merge of the values maps, values of src take precedence over dst.
+func mergeValues(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(dst))
+	for key, value := range dst {
+		out[key] = value
+	}
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]interface{}); ok {
+			if dstMap, ok := out[key].(map[string]interface{}); ok {
+				out[key] = mergeValues(dstMap, srcMap)
+				continue
+			}
+		}
+		out[key] = value
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Helm values", func() {
+	It("Merge values should override nested keys", func() {
+		dst := map[string]interface{}{
+			"replicaCount": 1,
+			"image": map[string]interface{}{
+				"repository": "registry.k8s.io/sig-storage/nfs-subdir-external-provisioner",
+				"tag":        "v4.0.2",
+			},
+		}
+		src := map[string]interface{}{
+			"image": map[string]interface{}{
+				"tag": "v4.0.3",
+			},
+			"nodeSelector": map[string]interface{}{
+				"role": "storage",
+			},
+		}
+		merged := mergeValues(dst, src)
+		Expect(merged).To(Equal(map[string]interface{}{
+			"replicaCount": 1,
+			"image": map[string]interface{}{
+				"repository": "registry.k8s.io/sig-storage/nfs-subdir-external-provisioner",
+				"tag":        "v4.0.3",
+			},
+			"nodeSelector": map[string]interface{}{
+				"role": "storage",
+			},
+		}))
+		Expect(dst["image"].(map[string]interface{})["tag"]).To(Equal("v4.0.2"))
+	})
+	It("Provisioner values should merge valuesFrom and values in order", func() {
+		configMap := corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioner-values",
+				Namespace: DefaultNamespace,
+			},
+			Data: map[string]string{
+				crdv1.DefaultValuesKey: "replicaCount: 2\nnodeSelector:\n  role: storage\n",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &configMap)).To(Succeed())
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "values-provisioner",
+				Namespace: DefaultNamespace,
+			},
+			Spec: crdv1.NfsProvisionerSpec{
+				Values: &apiextensionsv1.JSON{Raw: []byte(`{"replicaCount":3}`)},
+				ValuesFrom: []crdv1.ValuesReference{
+					{Kind: crdv1.ValuesKindConfigMap, Name: configMap.Name},
+					{Kind: crdv1.ValuesKindSecret, Name: "missing-values", Optional: true},
+				},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{Client: k8sClient}
+		values, err := reconciler.getProvisionerValues(ctx, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values["replicaCount"]).To(BeEquivalentTo(3))
+		Expect(values["nodeSelector"]).To(Equal(map[string]interface{}{"role": "storage"}))
+
+		provisioner.Spec.ValuesFrom[1].Optional = false
+		_, err = reconciler.getProvisionerValues(ctx, &provisioner)
+		Expect(err).To(HaveOccurred())
+		Expect(k8sClient.Delete(ctx, &configMap)).To(Succeed())
+	})
+	It("File share override values should apply matching overrides in order", func() {
+		retain := corev1.PersistentVolumeReclaimRetain
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				MountOptions: []string{"nfsvers=4.1", "soft", "timeo=600"},
+				FileShareOverrides: []crdv1.FileShareOverride{
+					{Name: "db-*", MountOptions: []string{"hard", "nfsvers=4.1"}, StorageClassName: "nfs-db"},
+					{ID: "d918f840-29a2-4d54-a67e-5c9d4e34a408", StorageClassParameters: crdv1.StorageClassParameters{ReclaimPolicy: &retain}},
+					{Name: "other", MountOptions: []string{"soft"}},
+				},
+			},
+		}
+		fileShare := file_shares.FileShare{ID: "d918f840-29a2-4d54-a67e-5c9d4e34a408", Name: "db-main"}
+		reconciler := NfsProvisionerReconciler{}
+		values, err := reconciler.getFileShareOverrideValues(&provisioner, &fileShare)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).NotTo(HaveKey("nfs"))
+		Expect(values).NotTo(HaveKey("storageClass"))
+		Expect(reconciler.getStorageClassName(&provisioner, &fileShare)).To(Equal("nfs-db"))
+		Expect(getMountOptionValues(reconciler.getMountOptions(&provisioner, &fileShare))).To(Equal(map[string]interface{}{
+			"nfs": map[string]interface{}{"mountOptions": []interface{}{"hard", "nfsvers=4.1"}},
+		}))
+
+		otherFileShare := file_shares.FileShare{ID: "9c6a4e3c-5f0e-4d1c-9d0d-0b5f1f7a1d11", Name: "web"}
+		Expect(reconciler.getStorageClassName(&provisioner, &otherFileShare)).To(Equal("nfs-" + otherFileShare.ID))
+		Expect(reconciler.getMountOptions(&provisioner, &otherFileShare)).To(Equal(provisioner.Spec.MountOptions))
+
+		provisioner.Spec.MountOptions = nil
+		Expect(reconciler.getMountOptions(&provisioner, &otherFileShare)).To(BeNil())
+
+		// An override setting both id and name matches a file share by either of them
+		override := crdv1.FileShareOverride{ID: otherFileShare.ID, Name: "db-*"}
+		Expect(override.Matches(otherFileShare.ID, otherFileShare.Name)).To(BeTrue())
+		Expect(override.Matches(fileShare.ID, fileShare.Name)).To(BeTrue())
+		Expect(override.Matches("7b1d2c3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e", "cache")).To(BeFalse())
+	})
+})
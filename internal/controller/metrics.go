@@ -0,0 +1,224 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	gcorecloud "github.com/G-Core/gcorelabscloud-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "gcore_sfs"
+
+// Helm operations recorded in metrics.
+const (
+	helmOperationInstall   = "install"
+	helmOperationUpgrade   = "upgrade"
+	helmOperationUninstall = "uninstall"
+)
+
+var (
+	fileSharesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "file_shares",
+			Help:      "Number of file shares selected by the provisioner by cloud status.",
+		},
+		[]string{"namespace", "provisioner", "cloud_status"},
+	)
+	fileShareCapacityGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "file_share_capacity_bytes",
+			Help:      "Size of the file share in Gcore Cloud.",
+		},
+		[]string{"namespace", "provisioner", "file_share_id", "file_share"},
+	)
+	fileShareUsedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "file_share_used_bytes",
+			Help:      "Storage used on the file share measured by the last usage probe.",
+		},
+		[]string{"namespace", "provisioner", "file_share_id", "file_share"},
+	)
+	managedReleasesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "managed_releases",
+			Help:      "Number of provisioner releases managed by the provisioner.",
+		},
+		[]string{"namespace", "provisioner"},
+	)
+	helmOperationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "helm_operations_total",
+			Help:      "Number of Helm operations by operation and result.",
+		},
+		[]string{"operation", "result"},
+	)
+	helmOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "helm_operation_duration_seconds",
+			Help:      "Duration of Helm operations by operation.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		},
+		[]string{"operation"},
+	)
+	cloudAPIRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "cloud_api_request_duration_seconds",
+			Help:      "Duration of Gcore Cloud API requests by operation.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	cloudAPIErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cloud_api_errors_total",
+			Help:      "Number of failed Gcore Cloud API requests by operation and HTTP status code.",
+		},
+		[]string{"operation", "status_code"},
+	)
+	lastSyncMetric = newLastSyncCollector()
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		fileSharesGauge,
+		fileShareCapacityGauge,
+		fileShareUsedGauge,
+		managedReleasesGauge,
+		helmOperationsTotal,
+		helmOperationDuration,
+		cloudAPIRequestDuration,
+		cloudAPIErrorsTotal,
+		lastSyncMetric,
+	)
+}
+
+// lastSyncCollector exports the time since the last successful synchronization of every provisioner.
+type lastSyncCollector struct {
+	mu        sync.Mutex
+	lastSyncs map[types.NamespacedName]time.Time
+	desc      *prometheus.Desc
+}
+
+func newLastSyncCollector() *lastSyncCollector {
+	return &lastSyncCollector{
+		lastSyncs: map[types.NamespacedName]time.Time{},
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "seconds_since_last_successful_sync"),
+			"Seconds since the last successful synchronization of the provisioner file shares.",
+			[]string{"namespace", "provisioner"},
+			nil,
+		),
+	}
+}
+
+func (c *lastSyncCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lastSyncCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, lastSync := range c.lastSyncs {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, time.Since(lastSync).Seconds(), name.Namespace, name.Name)
+	}
+}
+
+func (c *lastSyncCollector) set(name types.NamespacedName, lastSync time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSyncs[name] = lastSync
+}
+
+func (c *lastSyncCollector) delete(name types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.lastSyncs, name)
+}
+
+// observeHelmOperation records the result and duration of a Helm operation started at start.
+func observeHelmOperation(operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	helmOperationsTotal.WithLabelValues(operation, result).Inc()
+	helmOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// observeCloudAPIRequest records the duration of a Gcore Cloud API request started at start and its error status code.
+func observeCloudAPIRequest(operation string, start time.Time, err error) {
+	cloudAPIRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+	statusCode := "none"
+	var statusCodeErr gcorecloud.StatusCodeError
+	if errors.As(err, &statusCodeErr) {
+		statusCode = strconv.Itoa(statusCodeErr.GetStatusCode())
+	}
+	cloudAPIErrorsTotal.WithLabelValues(operation, statusCode).Inc()
+}
+
+// observeFileShares records the file shares, their capacity and usage and the managed releases of the provisioner.
+func observeFileShares(provisioner *crdv1.NfsProvisioner) {
+	labels := prometheus.Labels{"namespace": provisioner.Namespace, "provisioner": provisioner.Name}
+	fileSharesGauge.DeletePartialMatch(labels)
+	fileShareCapacityGauge.DeletePartialMatch(labels)
+	fileShareUsedGauge.DeletePartialMatch(labels)
+	releases := 0
+	for _, fileShareStatus := range provisioner.Status.FileShares {
+		fileSharesGauge.WithLabelValues(provisioner.Namespace, provisioner.Name, fileShareStatus.CloudStatus).Inc()
+		if fileShareStatus.Capacity != nil {
+			fileShareCapacityGauge.WithLabelValues(provisioner.Namespace, provisioner.Name, fileShareStatus.ID, fileShareStatus.Name).
+				Set(float64(fileShareStatus.Capacity.Value()))
+		}
+		if fileShareStatus.Usage != nil {
+			fileShareUsedGauge.WithLabelValues(provisioner.Namespace, provisioner.Name, fileShareStatus.ID, fileShareStatus.Name).
+				Set(float64(fileShareStatus.Usage.Used.Value()))
+		}
+		if fileShareStatus.ReleaseName != "" {
+			releases++
+		}
+	}
+	managedReleasesGauge.WithLabelValues(provisioner.Namespace, provisioner.Name).Set(float64(releases))
+}
+
+// deleteProvisionerMetrics removes the metrics of a deleted provisioner.
+func deleteProvisionerMetrics(provisioner *crdv1.NfsProvisioner) {
+	labels := prometheus.Labels{"namespace": provisioner.Namespace, "provisioner": provisioner.Name}
+	fileSharesGauge.DeletePartialMatch(labels)
+	fileShareCapacityGauge.DeletePartialMatch(labels)
+	fileShareUsedGauge.DeletePartialMatch(labels)
+	managedReleasesGauge.DeletePartialMatch(labels)
+	lastSyncMetric.delete(types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Name})
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"time"
+
+	gcorecloud "github.com/G-Core/gcorelabscloud-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Metrics", func() {
+	It("Cloud API errors should be counted by HTTP status code", func() {
+		before := testutil.ToFloat64(cloudAPIErrorsTotal.WithLabelValues("list_file_shares", "401"))
+		err := gcorecloud.ErrDefault401{
+			ErrUnexpectedResponseCode: gcorecloud.ErrUnexpectedResponseCode{Actual: 401},
+		}
+		observeCloudAPIRequest("list_file_shares", time.Now(), err)
+		Expect(testutil.ToFloat64(cloudAPIErrorsTotal.WithLabelValues("list_file_shares", "401"))).To(Equal(before + 1))
+	})
+	It("Helm operations should be counted by result", func() {
+		before := testutil.ToFloat64(helmOperationsTotal.WithLabelValues(helmOperationUninstall, "success"))
+		observeHelmOperation(helmOperationUninstall, time.Now(), nil)
+		Expect(testutil.ToFloat64(helmOperationsTotal.WithLabelValues(helmOperationUninstall, "success"))).To(Equal(before + 1))
+	})
+})
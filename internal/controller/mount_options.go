@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+)
+
+// MountOptionsAnnotationName annotates the provisioner pods with their mount options,
+// so a change of the mount options restarts them and the file share is mounted again.
+const MountOptionsAnnotationName = "gcore-sfs-controller.io/mount-options"
+
+// getMountOptions returns the mount options of the file share set by the last matching file share override
+// or spec.mountOptions. It is nil when neither sets them, the defaults or the Helm values apply then.
+func (r *NfsProvisionerReconciler) getMountOptions(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) []string {
+	var matchingOverride *crdv1.FileShareOverride
+	for i, override := range provisioner.Spec.FileShareOverrides {
+		if override.Matches(fileShare.ID, fileShare.Name) && len(override.MountOptions) > 0 {
+			matchingOverride = &provisioner.Spec.FileShareOverrides[i]
+		}
+	}
+	if matchingOverride == nil && len(provisioner.Spec.MountOptions) == 0 {
+		return nil
+	}
+	return crdv1.GetEffectiveMountOptions(&provisioner.Spec, matchingOverride)
+}
+
+// getMountOptionValues returns the Helm values of the mount options, they are empty when no mount options are set.
+func getMountOptionValues(mountOptions []string) map[string]interface{} {
+	if len(mountOptions) == 0 {
+		return map[string]interface{}{}
+	}
+	values := make([]interface{}, 0, len(mountOptions))
+	for _, mountOption := range mountOptions {
+		values = append(values, mountOption)
+	}
+	return map[string]interface{}{
+		"nfs": map[string]interface{}{"mountOptions": values},
+	}
+}
+
+// getValuesMountOptions returns the mount options rendered by the chart from the values.
+func getValuesMountOptions(values map[string]interface{}) []string {
+	nfsValues, _ := values["nfs"].(map[string]interface{})
+	mountOptions := []string{}
+	if values, ok := nfsValues["mountOptions"].([]interface{}); ok {
+		for _, value := range values {
+			mountOptions = append(mountOptions, fmt.Sprint(value))
+		}
+	}
+	return mountOptions
+}
+
+// getMountOptionsPodAnnotationValues returns the Helm values annotating the provisioner pods with the mount options.
+func getMountOptionsPodAnnotationValues(mountOptions []string) map[string]interface{} {
+	return map[string]interface{}{
+		"podAnnotations": map[string]interface{}{
+			MountOptionsAnnotationName: strings.Join(mountOptions, ","),
+		},
+	}
+}
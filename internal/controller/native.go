@@ -0,0 +1,343 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The objects of the Native deployment mode mirror the objects of the nfs-subdir-external-provisioner chart.
+const (
+	// NativeFieldManager is the server-side apply field manager of the Native deployment mode objects.
+	NativeFieldManager = "gcore-sfs-controller"
+	// NfsProvisionerImage is the image of the provisioner Deployment in Native deployment mode.
+	NfsProvisionerImage = "registry.k8s.io/sig-storage/nfs-subdir-external-provisioner"
+
+	nativeAppName          = "nfs-subdir-external-provisioner"
+	nativeVolumeName       = "nfs-subdir-external-provisioner-root"
+	nativeVolumeMountPath  = "/persistentvolumes"
+	managedByLabelName     = "app.kubernetes.io/managed-by"
+	appNameLabelName       = "app.kubernetes.io/name"
+	appInstanceLabelName   = "app.kubernetes.io/instance"
+	defaultArchiveOnDelete = true
+	// nativeRootVolumeSize is the nominal size of the PersistentVolume mounting the file share into the provisioner.
+	nativeRootVolumeSize = "10Mi"
+)
+
+// nativeStorageClassSettings are the StorageClass settings of a file share in Native deployment mode.
+type nativeStorageClassSettings struct {
+	MountOptions      []string
+	ReclaimPolicy     corev1.PersistentVolumeReclaimPolicy
+	VolumeBindingMode storagev1.VolumeBindingMode
+	Parameters        map[string]string
+	Labels            map[string]string
+	Annotations       map[string]string
+}
+
+// getNativeStorageClassSettings returns the StorageClass settings of the file share,
+// the last matching file share override setting a value takes precedence.
+func (r *NfsProvisionerReconciler) getNativeStorageClassSettings(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) nativeStorageClassSettings {
+	parameters := r.getStorageClassParameters(provisioner, fileShare)
+	settings := nativeStorageClassSettings{
+		MountOptions:      crdv1.DefaultMountOptions,
+		ReclaimPolicy:     defaultReclaimPolicy,
+		VolumeBindingMode: defaultVolumeBindingMode,
+		Parameters:        getNativeStorageClassParameters(&parameters),
+		Labels:            parameters.StorageClassLabels,
+		Annotations:       parameters.StorageClassAnnotations,
+	}
+	if parameters.ReclaimPolicy != nil {
+		settings.ReclaimPolicy = *parameters.ReclaimPolicy
+	}
+	if parameters.VolumeBindingMode != nil {
+		settings.VolumeBindingMode = *parameters.VolumeBindingMode
+	}
+	if mountOptions := r.getMountOptions(provisioner, fileShare); mountOptions != nil {
+		settings.MountOptions = mountOptions
+	}
+	return settings
+}
+
+// getNativeLabels returns the labels of the Native deployment mode objects of the file share.
+func (r *NfsProvisionerReconciler) getNativeLabels(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) map[string]string {
+	return map[string]string{
+		managedByLabelName:        NativeFieldManager,
+		appNameLabelName:          nativeAppName,
+		appInstanceLabelName:      r.getReleaseName(provisioner, fileShare.ID),
+		NfsProvisionerIDLabelName: string(provisioner.UID),
+		FileShareIDLabelName:      fileShare.ID,
+		FileShareNameLabelName:    fileShare.Name,
+	}
+}
+
+// getNativeProvisionerName returns the name of the provisioner serving the StorageClass of the file share.
+func (r *NfsProvisionerReconciler) getNativeProvisionerName(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) string {
+	return fmt.Sprintf("cluster.local/%s", r.getReleaseName(provisioner, fileShare.ID))
+}
+
+// buildNativeObjects returns the provisioner objects of the file share in Native deployment mode, the
+// StorageClass is served by the provisionerName provisioner.
+func (r *NfsProvisionerReconciler) buildNativeObjects(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, nfsServer string, nfsPath string, provisionerName string, defaultClass bool) []client.Object {
+	name := r.getReleaseName(provisioner, fileShare.ID)
+	labels := r.getNativeLabels(provisioner, fileShare)
+	selectorLabels := map[string]string{
+		appNameLabelName:     nativeAppName,
+		appInstanceLabelName: name,
+	}
+	objectMeta := func(name string, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}
+	}
+	settings := r.getNativeStorageClassSettings(provisioner, fileShare)
+	allowVolumeExpansion := true
+	replicas := int32(1)
+
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: objectMeta(name, provisioner.Namespace),
+	}
+	clusterRole := &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: objectMeta(fmt.Sprintf("%s-runner", name), ""),
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list", "watch"}},
+			{APIGroups: []string{""}, Resources: []string{"persistentvolumes"}, Verbs: []string{"get", "list", "watch", "create", "delete"}},
+			{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"get", "list", "watch", "update"}},
+			{APIGroups: []string{"storage.k8s.io"}, Resources: []string{"storageclasses"}, Verbs: []string{"get", "list", "watch"}},
+			{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "update", "patch"}},
+		},
+	}
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+		ObjectMeta: objectMeta(fmt.Sprintf("run-%s", name), ""),
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount.Name, Namespace: serviceAccount.Namespace},
+		},
+		RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole.Name},
+	}
+	role := &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+		ObjectMeta: objectMeta(fmt.Sprintf("leader-locking-%s", name), provisioner.Namespace),
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"endpoints"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch"}},
+		},
+	}
+	roleBinding := &rbacv1.RoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+		ObjectMeta: objectMeta(fmt.Sprintf("leader-locking-%s", name), provisioner.Namespace),
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount.Name, Namespace: serviceAccount.Namespace},
+		},
+		RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name},
+	}
+	podLabels := map[string]string{}
+	for key, value := range labels {
+		podLabels[key] = value
+	}
+	// The file share is mounted into the provisioner with the storage class mount options through
+	// a pre-bound PersistentVolume, an inline NFS volume has no mount options.
+	rootVolumeSource := corev1.VolumeSource{
+		NFS: &corev1.NFSVolumeSource{Server: nfsServer, Path: nfsPath},
+	}
+	var rootVolumeObjects []client.Object
+	if len(settings.MountOptions) > 0 {
+		rootVolume := &corev1.PersistentVolume{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"},
+			ObjectMeta: objectMeta(fmt.Sprintf("pv-%s", name), ""),
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(nativeRootVolumeSize)},
+				AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+				MountOptions:                  settings.MountOptions,
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					NFS: &corev1.NFSVolumeSource{Server: nfsServer, Path: nfsPath},
+				},
+			},
+		}
+		emptyStorageClassName := ""
+		rootVolumeClaim := &corev1.PersistentVolumeClaim{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+			ObjectMeta: objectMeta(fmt.Sprintf("pvc-%s", name), provisioner.Namespace),
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				StorageClassName: &emptyStorageClassName,
+				VolumeName:       rootVolume.Name,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(nativeRootVolumeSize)},
+				},
+			},
+		}
+		rootVolumeSource = corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: rootVolumeClaim.Name},
+		}
+		rootVolumeObjects = []client.Object{rootVolume, rootVolumeClaim}
+	}
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: objectMeta(name, provisioner.Namespace),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Selector: &metav1.LabelSelector{MatchLabels: selectorLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: podLabels,
+					// The pods are restarted to mount the file share with changed mount options.
+					Annotations: map[string]string{MountOptionsAnnotationName: strings.Join(settings.MountOptions, ",")},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: serviceAccount.Name,
+					Containers: []corev1.Container{
+						{
+							Name:            nativeAppName,
+							Image:           fmt.Sprintf("%s:%s", NfsProvisionerImage, provisioner.Spec.ImageVersion),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: nativeVolumeName, MountPath: nativeVolumeMountPath},
+							},
+							Env: []corev1.EnvVar{
+								{Name: "PROVISIONER_NAME", Value: provisionerName},
+								{Name: "NFS_SERVER", Value: nfsServer},
+								{Name: "NFS_PATH", Value: nfsPath},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: nativeVolumeName, VolumeSource: rootVolumeSource},
+					},
+				},
+			},
+		},
+	}
+	// The labels and annotations managed by the controller take precedence over the configured ones.
+	storageClassMeta := objectMeta(r.getStorageClassName(provisioner, fileShare), "")
+	storageClassMeta.Labels = mergeStringMaps(settings.Labels, labels)
+	storageClassMeta.Annotations = mergeStringMaps(settings.Annotations, map[string]string{
+		crdv1.IsDefaultStorageClassAnnotation: strconv.FormatBool(defaultClass),
+	})
+	storageClass := &storagev1.StorageClass{
+		TypeMeta:             metav1.TypeMeta{APIVersion: storagev1.SchemeGroupVersion.String(), Kind: "StorageClass"},
+		ObjectMeta:           storageClassMeta,
+		Provisioner:          provisionerName,
+		ReclaimPolicy:        &settings.ReclaimPolicy,
+		AllowVolumeExpansion: &allowVolumeExpansion,
+		VolumeBindingMode:    &settings.VolumeBindingMode,
+		MountOptions:         settings.MountOptions,
+		Parameters:           settings.Parameters,
+	}
+	objects := []client.Object{serviceAccount, clusterRole, clusterRoleBinding, role, roleBinding}
+	objects = append(objects, rootVolumeObjects...)
+	return append(objects, deployment, storageClass)
+}
+
+// deployNativeProvisioner applies the provisioner objects of the file share with server-side apply
+// and returns the name of the provisioner Deployment. Namespaced objects are owned by the provisioner,
+// cluster-scoped objects are found by their labels and deleted by deleteNativeObjects.
+func (r *NfsProvisionerReconciler) deployNativeProvisioner(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, state *provisionerState) (string, error) {
+	log := log.FromContext(ctx)
+
+	nfsServer, nfsPath, err := r.getNfsServerAndPath(fileShare)
+	if err != nil {
+		r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, InvalidConnectionPointEventReason,
+			"File share %s has incorrect connection point %q", fileShare.Name, fileShare.ConnectionPoint)
+		return "", err
+	}
+	provisionerName, err := r.getLegacyProvisionerName(ctx, provisioner, fileShare)
+	if err != nil {
+		return "", err
+	}
+	if provisionerName == "" {
+		provisionerName = r.getNativeProvisionerName(provisioner, fileShare)
+	}
+	// The provisioner of a file share deployed by Helm before switching the deployment mode is replaced.
+	releaseName := r.getReleaseName(provisioner, fileShare.ID)
+	for _, name := range []string{releaseName, r.getLegacyReleaseName(fileShare.ID)} {
+		uninstalled, err := r.uninstallOwnedRelease(ctx, provisioner, name)
+		if err != nil {
+			return "", err
+		}
+		if uninstalled {
+			log.Info("Uninstalled release replaced by native objects", "release", name)
+		}
+	}
+	for _, obj := range r.buildNativeObjects(provisioner, fileShare, nfsServer, nfsPath, provisionerName, fileShare.ID == state.defaultFileShareID) {
+		if obj.GetNamespace() != "" {
+			if err := controllerutil.SetControllerReference(provisioner, obj, r.Client.Scheme()); err != nil {
+				return "", err
+			}
+		}
+		if err := r.applyNativeObject(ctx, obj); err != nil {
+			return "", fmt.Errorf("apply %s %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), err)
+		}
+	}
+	return releaseName, nil
+}
+
+// applyNativeObject applies the object with server-side apply. StorageClass parameters are immutable,
+// a StorageClass rejected by the API server is deleted and created again.
+func (r *NfsProvisionerReconciler) applyNativeObject(ctx context.Context, obj client.Object) error {
+	err := r.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(NativeFieldManager), client.ForceOwnership)
+	if _, isStorageClass := obj.(*storagev1.StorageClass); !isStorageClass || !apierrors.IsInvalid(err) {
+		return err
+	}
+	log.FromContext(ctx).Info("Recreating storage class with changed parameters", "storageClass", obj.GetName())
+	if err := r.Client.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	obj.SetResourceVersion("")
+	return r.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(NativeFieldManager), client.ForceOwnership)
+}
+
+// deleteNativeObjects deletes the Native deployment mode objects of the provisioner matching the labels.
+func (r *NfsProvisionerReconciler) deleteNativeObjects(ctx context.Context, provisioner *crdv1.NfsProvisioner, matchLabels map[string]string) error {
+	selector := map[string]string{
+		managedByLabelName:        NativeFieldManager,
+		NfsProvisionerIDLabelName: string(provisioner.UID),
+	}
+	for key, value := range matchLabels {
+		selector[key] = value
+	}
+	namespacedObjects := []client.Object{&appsv1.Deployment{}, &corev1.PersistentVolumeClaim{}, &rbacv1.RoleBinding{}, &rbacv1.Role{}, &corev1.ServiceAccount{}}
+	for _, obj := range namespacedObjects {
+		if err := r.Client.DeleteAllOf(ctx, obj, client.InNamespace(provisioner.Namespace), client.MatchingLabels(selector),
+			client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return err
+		}
+	}
+	clusterObjects := []client.Object{&storagev1.StorageClass{}, &corev1.PersistentVolume{}, &rbacv1.ClusterRoleBinding{}, &rbacv1.ClusterRole{}}
+	for _, obj := range clusterObjects {
+		if err := r.Client.DeleteAllOf(ctx, obj, client.MatchingLabels(selector)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
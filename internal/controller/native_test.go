@@ -0,0 +1,144 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	gohelmclient "github.com/mittwald/go-helm-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Native deployment mode", func() {
+	fileShare := file_shares.FileShare{
+		Name:            "native_file_share",
+		ID:              "2c6f3d1e-7a4b-4e2f-9c1d-6b8a5e3f0d21",
+		Protocol:        "nfs",
+		Status:          "available",
+		Size:            2,
+		ConnectionPoint: "10.33.20.94:/shares/share-2c6f3d1e-7a4b-4e2f-9c1d-6b8a5e3f0d21",
+	}
+
+	It("Native objects should apply the file share overrides", func() {
+		reclaimPolicy := corev1.PersistentVolumeReclaimRetain
+		archiveOnDelete := false
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "native", Namespace: DefaultNamespace, UID: "provisioner-uid"},
+			Spec: crdv1.NfsProvisionerSpec{
+				DeploymentMode: crdv1.DeploymentModeNative,
+				ImageVersion:   "v4.0.2",
+				FileShareOverrides: []crdv1.FileShareOverride{
+					{Name: "native_*", StorageClassName: "native-nfs", MountOptions: []string{"hard", "nfsvers=4.1"}},
+					{ID: fileShare.ID, StorageClassParameters: crdv1.StorageClassParameters{
+						ReclaimPolicy:      &reclaimPolicy,
+						ArchiveOnDelete:    &archiveOnDelete,
+						StorageClassLabels: map[string]string{"team": "storage", FileShareIDLabelName: "overridden"},
+					}},
+				},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{}
+		objects := reconciler.buildNativeObjects(&provisioner, &fileShare, "10.33.20.94", "/shares/share", reconciler.getNativeProvisionerName(&provisioner, &fileShare), false)
+		Expect(objects).To(HaveLen(9))
+		for _, obj := range objects {
+			Expect(obj.GetLabels()).To(HaveKeyWithValue(NfsProvisionerIDLabelName, "provisioner-uid"))
+			Expect(obj.GetLabels()).To(HaveKeyWithValue(FileShareIDLabelName, fileShare.ID))
+			Expect(obj.GetLabels()).To(HaveKeyWithValue(managedByLabelName, NativeFieldManager))
+		}
+
+		storageClass := objects[8].(*storagev1.StorageClass)
+		Expect(storageClass.Name).To(Equal("native-nfs"))
+		Expect(storageClass.Provisioner).To(Equal("cluster.local/" + reconciler.getReleaseName(&provisioner, fileShare.ID)))
+		Expect(storageClass.MountOptions).To(Equal([]string{"hard", "nfsvers=4.1"}))
+		Expect(*storageClass.ReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+		Expect(storageClass.Parameters).To(HaveKeyWithValue("archiveOnDelete", "false"))
+		Expect(storageClass.Annotations).To(HaveKeyWithValue(crdv1.IsDefaultStorageClassAnnotation, "false"))
+		Expect(storageClass.Labels).To(HaveKeyWithValue("team", "storage"))
+		Expect(*storageClass.VolumeBindingMode).To(Equal(storagev1.VolumeBindingImmediate))
+
+		rootVolume := objects[5].(*corev1.PersistentVolume)
+		Expect(rootVolume.Spec.MountOptions).To(Equal([]string{"hard", "nfsvers=4.1"}))
+		Expect(rootVolume.Spec.NFS).To(Equal(&corev1.NFSVolumeSource{Server: "10.33.20.94", Path: "/shares/share"}))
+		rootVolumeClaim := objects[6].(*corev1.PersistentVolumeClaim)
+		Expect(rootVolumeClaim.Spec.VolumeName).To(Equal(rootVolume.Name))
+
+		deployment := objects[7].(*appsv1.Deployment)
+		Expect(deployment.Namespace).To(Equal(DefaultNamespace))
+		container := deployment.Spec.Template.Spec.Containers[0]
+		Expect(container.Image).To(Equal(NfsProvisionerImage + ":v4.0.2"))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "PROVISIONER_NAME", Value: storageClass.Provisioner}))
+		Expect(deployment.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(rootVolumeClaim.Name))
+		Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue(MountOptionsAnnotationName, "hard,nfsvers=4.1"))
+	})
+
+	It("Calling reconcile in native mode should apply provisioner objects", func() {
+		provisionerName := types.NamespacedName{Namespace: DefaultNamespace, Name: "test-provisioner-native"}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      provisionerName.Name,
+				Namespace: provisionerName.Namespace,
+			},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:       "faketoken",
+				APIURL:         "http://127.0.0.1",
+				RegionID:       2,
+				ProjectID:      5,
+				DeploymentMode: crdv1.DeploymentModeNative,
+				ImageVersion:   "v4.0.2",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+
+		helmClient, err := gohelmclient.NewClientFromRestConf(
+			&gohelmclient.RestConfClientOptions{
+				Options:    &gohelmclient.Options{},
+				RestConfig: cfg,
+			})
+		Expect(err).NotTo(HaveOccurred())
+		reconciler := NfsProvisionerReconciler{
+			Client:     k8sClient,
+			Recorder:   record.NewFakeRecorder(100),
+			HelmClient: helmClient,
+			FileShareClient: &gcoreclient.MockFileShareManager{
+				FileShares: []file_shares.FileShare{fileShare},
+			},
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+
+		name := reconciler.getReleaseName(&provisioner, fileShare.ID)
+		storageClass := storagev1.StorageClass{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "nfs-" + fileShare.ID}, &storageClass)).To(Succeed())
+		Expect(storageClass.Provisioner).To(Equal("cluster.local/" + name))
+		deployment := appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: DefaultNamespace, Name: name}, &deployment)).To(Succeed())
+		Expect(deployment.OwnerReferences).To(HaveLen(1))
+		Expect(deployment.OwnerReferences[0].Name).To(Equal(provisionerName.Name))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-runner"}, &rbacv1.ClusterRole{})).To(Succeed())
+		_, err = helmClient.GetRelease(name)
+		Expect(err).To(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(provisioner.Status.FileShares).To(HaveLen(1))
+		Expect(provisioner.Status.FileShares[0].ReleaseName).To(Equal(name))
+		Expect(provisioner.Status.FileShares[0].MountOptions).To(Equal(crdv1.DefaultMountOptions))
+
+		// Cluster-scoped objects are deleted with the provisioner
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storagev1.StorageClass{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: name + "-runner"}, &rbacv1.ClusterRole{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -18,55 +18,124 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
 	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
 	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	"github.com/Masterminds/semver/v3"
 	gohelmclient "github.com/mittwald/go-helm-client"
-	"github.com/mittwald/go-helm-client/values"
-	"helm.sh/helm/v3/pkg/repo"
+	"golang.org/x/sync/errgroup"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-
+	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 )
 
-const RepositoryName = "nfs-subdir-external-provisioner"
-const RepositoryUrl = "https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner/"
-
 const (
 	FileShareIDLabelName      = "fileShareID"
 	FileShareNameLabelName    = "fileShareName"
 	NfsProvisionerIDLabelName = "nfsProvisionerID"
 )
 
+const (
+	// secretRefIndex indexes NfsProvisioners by the namespaced names of the Secrets they reference.
+	secretRefIndex = ".spec.secretRefs"
+	// configMapRefIndex indexes NfsProvisioners by the namespaced names of the ConfigMaps they reference.
+	configMapRefIndex = ".spec.configMapRefs"
+)
+
+// Event reasons emitted on NfsProvisioner resources.
+const (
+	FileShareDiscoveredEventReason       = "FileShareDiscovered"
+	ListFileSharesFailedEventReason      = "ListFileSharesFailed"
+	InvalidConnectionPointEventReason    = "InvalidConnectionPoint"
+	ReleaseInstalledEventReason          = "ReleaseInstalled"
+	ReleaseUpgradedEventReason           = "ReleaseUpgraded"
+	ReleaseInstallFailedEventReason      = "ReleaseInstallFailed"
+	ReleaseUninstalledEventReason        = "ReleaseUninstalled"
+	ReleaseUninstallFailedEventReason    = "ReleaseUninstallFailed"
+	ProvisionerObjectsDeletedEventReason = "ProvisionerObjectsDeleted"
+	BundledChartUsedEventReason          = "BundledChartUsed"
+	FileShareConflictEventReason         = "FileShareConflict"
+	StorageClassRetainedEventReason      = "StorageClassRetained"
+	ProvisionersOrphanedEventReason      = "ProvisionersOrphaned"
+)
+
+const (
+	// fileShareRetryBaseDelay is the delay before the first retry of a failed file share.
+	fileShareRetryBaseDelay = 10 * time.Second
+	// fileShareRetryMaxDelay is the maximum delay between retries of a failed file share.
+	fileShareRetryMaxDelay = 10 * time.Minute
+)
+
+// DefaultHelmConcurrency is the default number of Helm releases of a provisioner processed in parallel.
+const DefaultHelmConcurrency = 4
+
 type StringSet map[string]bool
 
+// provisionerState is computed once per reconcile and shared by the file shares of the provisioner.
+type provisionerState struct {
+	// chart is the resolved provisioner chart, it is nil in Native deployment mode.
+	chart *resolvedChart
+	// values are the user Helm values of the provisioner.
+	values map[string]interface{}
+	// storageClassOwners maps the storage class names to the file shares getting them.
+	storageClassOwners map[string]*file_shares.FileShare
+	// defaultFileShareID is the ID of the file share whose storage class is the cluster default.
+	defaultFileShareID string
+}
+
 // NfsProvisionerReconciler reconciles a NfsProvisioner object
 type NfsProvisionerReconciler struct {
 	client.Client
 	Scheme          *runtime.Scheme
+	Recorder        record.EventRecorder
 	HelmClient      gohelmclient.Client
-	FileShareClient gcoreclient.FileShareLister
+	FileShareClient gcoreclient.FileShareManager
+	// HelmConcurrency is the number of Helm releases of a provisioner processed in parallel,
+	// it can be overridden by spec.helmConcurrency.
+	HelmConcurrency int
+	// MaxConcurrentReconciles is the number of NfsProvisioners reconciled in parallel.
+	MaxConcurrentReconciles int
+	// ChartCacheDir is the directory holding the resolved chart archives, defaults to a temporary directory.
+	ChartCacheDir string
+	// BundledChartPath is the path of the chart archive used when the configured chart is not available.
+	BundledChartPath string
 }
 
 //+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=nfsprovisioners,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=nfsprovisioners/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=nfsprovisioners/finalizers,verbs=update
-//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=crd.gcore-sfs-controller.io,resources=fileshares,verbs=get;list;watch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch;create;update;patch;delete;deletecollection
 //+kubebuilder:rbac:groups="",resources=pods;secrets;serviceaccounts;persistentvolumes;persistentvolumeclaims;events,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;create;upate;patch;delete
-//+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=clusterroles;clusterrolebindings;roles;rolebindings,verbs=get;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts;persistentvolumes;persistentvolumeclaims,verbs=deletecollection
+//+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;patch;delete;deletecollection
+//+kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=clusterroles;clusterrolebindings;roles;rolebindings,verbs=get;list;watch;create;update;patch;delete;deletecollection
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch;create;update;patch
 
 func (r *NfsProvisionerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
@@ -93,14 +162,6 @@ func (r *NfsProvisionerReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			return ctrl.Result{}, err
 		}
 	}
-	err := r.HelmClient.AddOrUpdateChartRepo(repo.Entry{
-		Name: RepositoryName,
-		URL:  provisioner.Spec.HelmRepository,
-	})
-	if err != nil {
-		log.Error(err, "add or update repo")
-	}
-
 	defer func() {
 		// If object has finalizer attempt to update status.
 		if controllerutil.ContainsFinalizer(&provisioner, crdv1.NfsProvisionerFinalizer) {
@@ -132,7 +193,7 @@ func (r *NfsProvisionerReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 
 	log.Info("Reconciling has completed")
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
 func (r *NfsProvisionerReconciler) updateStatus(ctx context.Context, provisioner *crdv1.NfsProvisioner) error {
@@ -145,58 +206,384 @@ func (r *NfsProvisionerReconciler) updateStatus(ctx context.Context, provisioner
 	if err := r.Client.List(ctx, &provisionerPodList, &listOptions); err != nil {
 		return err
 	}
-	var status bool = true
+	readyFileShareSet := StringSet{}
 	for _, pod := range provisionerPodList.Items {
-		if pod.Status.Phase != corev1.PodRunning {
+		if isPodReady(&pod) {
+			readyFileShareSet[pod.Labels[FileShareIDLabelName]] = true
+		}
+	}
+	var status bool = true
+	notReadyFileShares := []string{}
+	failedFileShares := []string{}
+	claimedFileShares := []string{}
+	for i := range provisioner.Status.FileShares {
+		fileShareStatus := &provisioner.Status.FileShares[i]
+		if fileShareStatus.ClaimedBy != "" {
+			claimedFileShares = append(claimedFileShares, fmt.Sprintf("%s (%s)", fileShareStatus.Name, fileShareStatus.ClaimedBy))
+		}
+		fileShareStatus.Ready = fileShareStatus.ReleaseName != "" && fileShareStatus.Failures == 0 && readyFileShareSet[fileShareStatus.ID]
+		if fileShareStatus.Failures > 0 {
+			failedFileShares = append(failedFileShares, fileShareStatus.Name)
+		}
+		if !fileShareStatus.Ready {
 			status = false
-			break
+			notReadyFileShares = append(notReadyFileShares, fileShareStatus.Name)
+			if fileShareStatus.Message == "" {
+				fileShareStatus.Message = "Provisioner is not running"
+			}
+		} else {
+			fileShareStatus.Message = ""
 		}
 	}
 	provisioner.Status.ProvisionersReady = status
+	provisioner.Status.ObservedGeneration = provisioner.Generation
+	observeFileShares(provisioner)
+
+	if len(failedFileShares) > 0 {
+		r.setCondition(provisioner, crdv1.DegradedCondition, metav1.ConditionTrue, crdv1.FileSharesFailedReason,
+			fmt.Sprintf("Failed file shares: %s", strings.Join(failedFileShares, ", ")))
+	} else {
+		r.setCondition(provisioner, crdv1.DegradedCondition, metav1.ConditionFalse, crdv1.ReconciledReason, "")
+	}
+	var claimedErr *fileShareClaimedError
+	if err := r.checkDynamicStorageClassOwnership(ctx, provisioner); err != nil && !errors.As(err, &claimedErr) {
+		return err
+	}
+	if len(claimedFileShares) > 0 {
+		message := fmt.Sprintf("File shares claimed by other owners: %s", strings.Join(claimedFileShares, ", "))
+		if claimedErr != nil {
+			message = fmt.Sprintf("%s; dynamic provisioning %s", message, claimedErr.Error())
+		}
+		r.setCondition(provisioner, crdv1.ConflictCondition, metav1.ConditionTrue, crdv1.FileSharesClaimedReason, message)
+	} else if claimedErr != nil {
+		r.setCondition(provisioner, crdv1.ConflictCondition, metav1.ConditionTrue, crdv1.StorageClassClaimedReason,
+			fmt.Sprintf("Dynamic provisioning %s", claimedErr.Error()))
+	} else {
+		r.setCondition(provisioner, crdv1.ConflictCondition, metav1.ConditionFalse, crdv1.ReconciledReason, "")
+	}
+	for _, conditionType := range []string{crdv1.CloudAPIReachableCondition, crdv1.HelmReleasesSyncedCondition} {
+		condition := meta.FindStatusCondition(provisioner.Status.Conditions, conditionType)
+		if condition == nil || condition.Status != metav1.ConditionTrue {
+			reason, message := crdv1.ReconciledReason, fmt.Sprintf("%s condition is not true", conditionType)
+			if condition != nil {
+				reason, message = condition.Reason, condition.Message
+			}
+			r.setCondition(provisioner, crdv1.ReadyCondition, metav1.ConditionFalse, reason, message)
+			return nil
+		}
+	}
+	if !status {
+		r.setCondition(provisioner, crdv1.ReadyCondition, metav1.ConditionFalse, crdv1.ProvisionersNotReadyReason,
+			fmt.Sprintf("Not ready file shares: %s", strings.Join(notReadyFileShares, ", ")))
+		return nil
+	}
+	r.setCondition(provisioner, crdv1.ReadyCondition, metav1.ConditionTrue, crdv1.ReconciledReason, "")
 	return nil
 }
 
+func (r *NfsProvisionerReconciler) setCondition(provisioner *crdv1.NfsProvisioner, conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&provisioner.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: provisioner.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 func (r *NfsProvisionerReconciler) reconcileNormal(ctx context.Context, provisioner *crdv1.NfsProvisioner) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
-	allFileShares, err := r.FileShareClient.ListFileShares(provisioner)
+	if err := r.reconcileDynamicStorageClass(ctx, provisioner); err != nil {
+		log.Error(err, "failed reconcile dynamic provisioning storage class")
+		return ctrl.Result{}, err
+	}
+	listStart := time.Now()
+	projectFileShares, err := r.FileShareClient.ListFileShares(ctx, provisioner)
+	observeCloudAPIRequest("list_file_shares", listStart, err)
 	if err != nil {
 		log.Error(err, "get file shares in the project", "regionID", provisioner.Spec.RegionID, "projectID", provisioner.Spec.ProjectID)
+		r.setCondition(provisioner, crdv1.CloudAPIReachableCondition, metav1.ConditionFalse, crdv1.ListFileSharesFailedReason, err.Error())
+		r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, ListFileSharesFailedEventReason,
+			"Failed to list file shares of project %d in region %d: %v", provisioner.Spec.ProjectID, provisioner.Spec.RegionID, err)
+		return ctrl.Result{}, err
+	}
+	r.setCondition(provisioner, crdv1.CloudAPIReachableCondition, metav1.ConditionTrue, crdv1.FileSharesListedReason, "")
+	managedFileShareIDs, err := r.getManagedFileShareIDs(ctx, provisioner)
+	if err != nil {
+		log.Error(err, "failed get managed file shares")
+		return ctrl.Result{}, err
+	}
+	allFileShares, err := r.selectFileShares(provisioner, projectFileShares, managedFileShareIDs)
+	if err != nil {
+		log.Error(err, "failed select file shares")
+		return ctrl.Result{}, err
+	}
+	currentFileShareIDSet, err := r.getCurrentFileShareIDSet(ctx, provisioner)
+	if err != nil {
+		log.Error(err, "failed get provisioner file shares")
 		return ctrl.Result{}, err
 	}
-	currentReleaseNameSet, err := r.getCurrentReleaseNameSet(ctx, provisioner)
+	provisionerValues, err := r.getProvisionerValues(ctx, provisioner)
 	if err != nil {
-		log.Error(err, "failed get provisioner helm releases")
+		log.Error(err, "failed get provisioner helm values")
+		r.setCondition(provisioner, crdv1.HelmReleasesSyncedCondition, metav1.ConditionFalse, crdv1.ReleaseInstallFailedReason, err.Error())
 		return ctrl.Result{}, err
 	}
-	createReleaseNameSet := make(map[string]bool)
-	for _, fileShare := range allFileShares {
-		// ConnectionPoint == "" if file share is creating
+	var chart *resolvedChart
+	if provisioner.Spec.DeploymentMode != crdv1.DeploymentModeNative {
+		chart, err = r.resolveChart(ctx, provisioner)
+		if err != nil {
+			log.Error(err, "failed resolve provisioner chart")
+			r.setCondition(provisioner, crdv1.HelmReleasesSyncedCondition, metav1.ConditionFalse, crdv1.ChartUnavailableReason, err.Error())
+			return ctrl.Result{}, err
+		}
+	} else {
+		meta.RemoveStatusCondition(&provisioner.Status.Conditions, crdv1.ChartSourceAvailableCondition)
+	}
+	storageClassOwners, err := r.getStorageClassOwners(ctx, provisioner, allFileShares)
+	if err != nil {
+		log.Error(err, "failed get provisioner storage classes")
+		return ctrl.Result{}, err
+	}
+	state := provisionerState{
+		chart:              chart,
+		values:             provisionerValues,
+		storageClassOwners: storageClassOwners,
+		defaultFileShareID: r.getDefaultFileShareID(provisioner, allFileShares),
+	}
+	previousFileShareStatuses := map[string]crdv1.FileShareStatus{}
+	for _, fileShareStatus := range provisioner.Status.FileShares {
+		previousFileShareStatuses[fileShareStatus.ID] = fileShareStatus
+	}
+	// Every file share is processed independently, failed file shares are retried
+	// with backoff while the other file shares keep converging.
+	fileShareStatuses := make([]crdv1.FileShareStatus, len(allFileShares))
+	fileShareErrs := make([]error, len(allFileShares))
+	r.runConcurrently(provisioner, len(allFileShares), func(i int) {
+		fileShare := allFileShares[i]
+		previousStatus, known := previousFileShareStatuses[fileShare.ID]
+		if !known {
+			r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, FileShareDiscoveredEventReason,
+				"Discovered file share %s (%s)", fileShare.Name, fileShare.ID)
+		}
+		fileShareStatuses[i], fileShareErrs[i] = r.reconcileFileShare(ctx, provisioner, &fileShare, &previousStatus, &state)
+	})
+	var errs []error
+	var requeueAfter time.Duration
+	desiredFileShareIDSet := StringSet{}
+	for i, fileShare := range allFileShares {
+		if fileShareErrs[i] != nil {
+			errs = append(errs, fmt.Errorf("file share %s: %w", fileShare.Name, fileShareErrs[i]))
+		}
 		if fileShare.ConnectionPoint != "" {
-			releaseName, err := r.deployNfsProvisioner(ctx, provisioner, &fileShare)
-			if err != nil {
-				log.Error(err, "failed deploy chart", "namespace", provisioner.Namespace, "chartName", provisioner.Spec.ChartName)
-				return ctrl.Result{}, err
+			// Provisioners of failed file shares are kept until their next retry.
+			desiredFileShareIDSet[fileShare.ID] = true
+		}
+		if nextRetryTime := fileShareStatuses[i].NextRetryTime; nextRetryTime != nil {
+			retryAfter := time.Until(nextRetryTime.Time)
+			if retryAfter <= 0 {
+				retryAfter = time.Second
+			}
+			if requeueAfter == 0 || retryAfter < requeueAfter {
+				requeueAfter = retryAfter
 			}
-			createReleaseNameSet[releaseName] = true
 		}
 	}
-	for currentReleaseName := range currentReleaseNameSet {
-		if _, found := createReleaseNameSet[currentReleaseName]; !found {
-			if err = r.HelmClient.UninstallReleaseByName(currentReleaseName); err != nil {
-				log.Error(err, "failed uninstall chart", "namespace", provisioner.Namespace, "release", currentReleaseName)
-				return ctrl.Result{}, err
+	autoExpandRequeueAfter, err := r.autoExpandFileShares(ctx, provisioner, fileShareStatuses, previousFileShareStatuses)
+	if err != nil {
+		log.Error(err, "failed auto expand file shares")
+		return ctrl.Result{}, err
+	}
+	if autoExpandRequeueAfter > 0 && (requeueAfter == 0 || requeueAfter > autoExpandRequeueAfter) {
+		requeueAfter = autoExpandRequeueAfter
+	}
+	// The access rules changed out of band are restored on the next resync.
+	accessRulesRequeueAfter := time.Duration(0)
+	if r.reconcileAccessRules(ctx, provisioner, fileShareStatuses, previousFileShareStatuses) {
+		accessRulesRequeueAfter = fileShareRetryBaseDelay
+	} else if isAccessRulesFromNodes(provisioner) {
+		accessRulesRequeueAfter = fileShareResyncInterval
+	}
+	if accessRulesRequeueAfter > 0 && (requeueAfter == 0 || requeueAfter > accessRulesRequeueAfter) {
+		requeueAfter = accessRulesRequeueAfter
+	}
+	usageRequeueAfter, usageErr := r.reconcileUsage(ctx, provisioner, fileShareStatuses, previousFileShareStatuses)
+	if usageRequeueAfter > 0 && (requeueAfter == 0 || requeueAfter > usageRequeueAfter) {
+		requeueAfter = usageRequeueAfter
+	}
+	provisioner.Status.FileShares = fileShareStatuses
+	provisioner.Status.ChartVersion = getInstalledChartVersion(fileShareStatuses)
+	// The file share statuses are kept with the resizes and access rules changes of this reconcile.
+	if usageErr != nil {
+		log.Error(usageErr, "failed probe file share usage")
+		return ctrl.Result{}, usageErr
+	}
+	if err := r.updateDefaultStorageClassStatus(ctx, provisioner, state.defaultFileShareID); err != nil {
+		log.Error(err, "failed check default storage classes")
+		return ctrl.Result{}, err
+	}
+	staleFileShareIDs := []string{}
+	for currentFileShareID := range currentFileShareIDSet {
+		if !desiredFileShareIDSet[currentFileShareID] {
+			staleFileShareIDs = append(staleFileShareIDs, currentFileShareID)
+		}
+	}
+	removedFileShareIDs, deletionBlocked, err := r.applyDeletionPolicy(ctx, provisioner, staleFileShareIDs)
+	if err != nil {
+		log.Error(err, "failed check removed file shares")
+		return ctrl.Result{}, err
+	}
+	if deletionBlocked && (requeueAfter == 0 || requeueAfter > deletionBlockedRequeueDelay) {
+		requeueAfter = deletionBlockedRequeueDelay
+	}
+	uninstallErrs := r.removeFileShareProvisioners(ctx, provisioner, removedFileShareIDs)
+	if len(uninstallErrs) > 0 && (requeueAfter == 0 || requeueAfter > fileShareRetryBaseDelay) {
+		requeueAfter = fileShareRetryBaseDelay
+	}
+	if len(errs) > 0 || len(uninstallErrs) > 0 {
+		reason := crdv1.ReleaseInstallFailedReason
+		if len(errs) == 0 {
+			reason = crdv1.ReleaseUninstallFailedReason
+		}
+		aggregateErr := kerrors.NewAggregate(append(errs, uninstallErrs...))
+		log.Error(aggregateErr, "failed sync file share releases", "requeueAfter", requeueAfter)
+		r.setCondition(provisioner, crdv1.HelmReleasesSyncedCondition, metav1.ConditionFalse, reason, aggregateErr.Error())
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+	r.setCondition(provisioner, crdv1.HelmReleasesSyncedCondition, metav1.ConditionTrue, crdv1.ReleasesSyncedReason, "")
+	now := metav1.Now()
+	provisioner.Status.LastSyncTime = &now
+	lastSyncMetric.set(types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Name}, now.Time)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileFileShare deploys the provisioner of the file share and returns the file share status.
+// A file share which failed before is skipped until its next retry time, a file share claimed
+// by another provisioner is reported in the status and left intact.
+func (r *NfsProvisionerReconciler) reconcileFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, previousStatus *crdv1.FileShareStatus, state *provisionerState) (crdv1.FileShareStatus, error) {
+	log := log.FromContext(ctx)
+
+	fileShareStatus := crdv1.FileShareStatus{
+		ID:               fileShare.ID,
+		Name:             fileShare.Name,
+		ConnectionPoint:  fileShare.ConnectionPoint,
+		Size:             fileShare.Size,
+		CloudStatus:      fileShare.Status,
+		StorageClassName: r.getStorageClassName(provisioner, fileShare),
+	}
+	// ConnectionPoint == "" if file share is creating
+	if fileShare.ConnectionPoint == "" {
+		fileShareStatus.Message = "File share has no connection point yet"
+		return fileShareStatus, nil
+	}
+	// A changed spec may fix the failure, the file share is retried right away.
+	specChanged := provisioner.Generation != provisioner.Status.ObservedGeneration
+	if previousStatus.NextRetryTime != nil && !specChanged && time.Now().Before(previousStatus.NextRetryTime.Time) {
+		log.Info("Skip failed file share until next retry", "fileShare", fileShare.Name, "nextRetryTime", previousStatus.NextRetryTime)
+		fileShareStatus.ReleaseName = previousStatus.ReleaseName
+		fileShareStatus.ChartVersion = previousStatus.ChartVersion
+		fileShareStatus.Message = previousStatus.Message
+		fileShareStatus.Failures = previousStatus.Failures
+		fileShareStatus.LastFailureTime = previousStatus.LastFailureTime
+		fileShareStatus.NextRetryTime = previousStatus.NextRetryTime
+		fileShareStatus.AppliedHash = previousStatus.AppliedHash
+		fileShareStatus.MountOptions = previousStatus.MountOptions
+		fileShareStatus.RetainedStorageClassNames = previousStatus.RetainedStorageClassNames
+		return fileShareStatus, nil
+	}
+	err := r.checkFileShareOwnership(ctx, provisioner, fileShare, state.storageClassOwners)
+	var claimedErr *fileShareClaimedError
+	if errors.As(err, &claimedErr) {
+		if previousStatus.ClaimedBy != claimedErr.claimedBy {
+			r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, FileShareConflictEventReason,
+				"File share %s is not provisioned: %v", fileShare.Name, claimedErr)
+		}
+		fileShareStatus.ClaimedBy = claimedErr.claimedBy
+		fileShareStatus.Message = claimedErr.Error()
+		return fileShareStatus, nil
+	}
+	if err == nil {
+		fileShareStatus.RetainedStorageClassNames, err = r.retainStorageClasses(ctx, provisioner, fileShare, fileShareStatus.StorageClassName)
+	}
+	if err == nil {
+		if provisioner.Spec.DeploymentMode == crdv1.DeploymentModeNative {
+			fileShareStatus.ReleaseName, err = r.deployNativeProvisioner(ctx, provisioner, fileShare, state)
+			fileShareStatus.MountOptions = r.getNativeStorageClassSettings(provisioner, fileShare).MountOptions
+		} else {
+			var release *release.Release
+			release, fileShareStatus.AppliedHash, err = r.deployNfsProvisioner(ctx, provisioner, fileShare, previousStatus.AppliedHash, state)
+			if err == nil {
+				fileShareStatus.ReleaseName = release.Name
+				fileShareStatus.ChartVersion = release.Chart.Metadata.Version
+				fileShareStatus.MountOptions = getValuesMountOptions(release.Config)
 			}
 		}
 	}
-	return ctrl.Result{}, nil
+	if err != nil {
+		log.Error(err, "failed deploy provisioner", "namespace", provisioner.Namespace, "fileShare", fileShare.Name, "chartName", provisioner.Spec.ChartName, "chartVersion", provisioner.Spec.ChartVersion)
+		r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, ReleaseInstallFailedEventReason,
+			"Failed to deploy provisioner of file share %s: %v", fileShare.Name, err)
+		now := metav1.Now()
+		nextRetryTime := metav1.NewTime(now.Add(getFileShareRetryDelay(previousStatus.Failures + 1)))
+		fileShareStatus.ReleaseName = previousStatus.ReleaseName
+		fileShareStatus.ChartVersion = previousStatus.ChartVersion
+		fileShareStatus.Message = err.Error()
+		fileShareStatus.Failures = previousStatus.Failures + 1
+		fileShareStatus.LastFailureTime = &now
+		fileShareStatus.NextRetryTime = &nextRetryTime
+		fileShareStatus.AppliedHash = previousStatus.AppliedHash
+		fileShareStatus.MountOptions = previousStatus.MountOptions
+		fileShareStatus.RetainedStorageClassNames = previousStatus.RetainedStorageClassNames
+		return fileShareStatus, err
+	}
+	return fileShareStatus, nil
 }
 
-func (r NfsProvisionerReconciler) getReleaseName(fileShareID string) string {
-	return fmt.Sprintf("nfsprovisioner-%s", fileShareID)
+// getFileShareRetryDelay returns the exponential backoff delay after the given number of consecutive failures.
+func getFileShareRetryDelay(failures int32) time.Duration {
+	delay := fileShareRetryBaseDelay
+	for i := int32(1); i < failures && delay < fileShareRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > fileShareRetryMaxDelay {
+		delay = fileShareRetryMaxDelay
+	}
+	return delay
 }
 
-func (r *NfsProvisionerReconciler) getCurrentReleaseNameSet(ctx context.Context, provisioner *crdv1.NfsProvisioner) (StringSet, error) {
+// getInstalledChartVersion returns the chart version of the file share releases,
+// it is empty while the releases run different chart versions.
+func getInstalledChartVersion(fileShareStatuses []crdv1.FileShareStatus) string {
+	chartVersionSet := StringSet{}
+	for _, fileShareStatus := range fileShareStatuses {
+		if fileShareStatus.ChartVersion != "" {
+			chartVersionSet[fileShareStatus.ChartVersion] = true
+		}
+	}
+	if len(chartVersionSet) != 1 {
+		return ""
+	}
+	for chartVersion := range chartVersionSet {
+		return chartVersion
+	}
+	return ""
+}
+
+// getCurrentFileShareIDSet returns the IDs of the file shares with a provisioner StorageClass.
+func (r *NfsProvisionerReconciler) getCurrentFileShareIDSet(ctx context.Context, provisioner *crdv1.NfsProvisioner) (StringSet, error) {
 	fileShareStorageClasseList := storagev1.StorageClassList{}
 	listOptions := client.ListOptions{
 		LabelSelector: labels.SelectorFromSet(
@@ -206,54 +593,209 @@ func (r *NfsProvisionerReconciler) getCurrentReleaseNameSet(ctx context.Context,
 	if err := r.Client.List(ctx, &fileShareStorageClasseList, &listOptions); err != nil {
 		return StringSet{}, err
 	}
-	currentReleaseNameSet := StringSet{}
+	currentFileShareIDSet := StringSet{}
 	for _, storageClass := range fileShareStorageClasseList.Items {
-		currentReleaseNameSet[r.getReleaseName(storageClass.Labels[FileShareIDLabelName])] = true
+		currentFileShareIDSet[storageClass.Labels[FileShareIDLabelName]] = true
+	}
+	return currentFileShareIDSet, nil
+}
+
+// getCurrentRelease returns the installed release, it is nil when the release is not installed.
+func (r *NfsProvisionerReconciler) getCurrentRelease(releaseName string) (*release.Release, error) {
+	currentRelease, err := r.HelmClient.GetRelease(releaseName)
+	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return currentRelease, nil
+}
+
+// isChartVersionChanged reports whether the release runs a chart version
+// which does not satisfy the provisioner chart version constraint.
+func (r *NfsProvisionerReconciler) isChartVersionChanged(provisioner *crdv1.NfsProvisioner, currentRelease *release.Release) (bool, error) {
+	if provisioner.Spec.ChartVersion == "" || currentRelease == nil {
+		return false, nil
 	}
-	return currentReleaseNameSet, nil
+	if currentRelease.Chart == nil || currentRelease.Chart.Metadata == nil {
+		return true, nil
+	}
+	constraint, err := semver.NewConstraint(provisioner.Spec.ChartVersion)
+	if err != nil {
+		return false, err
+	}
+	currentVersion, err := semver.NewVersion(currentRelease.Chart.Metadata.Version)
+	if err != nil {
+		return true, nil
+	}
+	return !constraint.Check(currentVersion), nil
 }
 
-func (r *NfsProvisionerReconciler) deployNfsProvisioner(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) (string, error) {
+// deployNfsProvisioner installs or upgrades the provisioner release of the file share and returns the release
+// with the hash of its desired state. Helm is not called when the desired state matches appliedHash and the
+// release objects were not changed out-of-band.
+func (r *NfsProvisionerReconciler) deployNfsProvisioner(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, appliedHash string, state *provisionerState) (*release.Release, string, error) {
+	log := log.FromContext(ctx)
+
 	nfsServer, nfsPath, err := r.getNfsServerAndPath(fileShare)
 	if err != nil {
-		return "", err
+		r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, InvalidConnectionPointEventReason,
+			"File share %s has incorrect connection point %q", fileShare.Name, fileShare.ConnectionPoint)
+		return nil, "", err
 	}
-	release, err := r.HelmClient.InstallOrUpgradeChart(ctx, &gohelmclient.ChartSpec{
-		ReleaseName: r.getReleaseName(fileShare.ID),
-		ChartName:   fmt.Sprintf("%s/%s", RepositoryName, provisioner.Spec.ChartName),
+	releaseName := r.getReleaseName(provisioner, fileShare.ID)
+	currentRelease, err := r.getCurrentRelease(releaseName)
+	if err != nil {
+		return nil, "", err
+	}
+	// The volumes provisioned before the release was renamed keep their provisioner.
+	legacyProvisionerName, err := r.getLegacyProvisionerName(ctx, provisioner, fileShare)
+	if err != nil {
+		return nil, "", err
+	}
+	if currentRelease == nil {
+		// The provisioner of a file share deployed natively before switching the deployment mode is replaced.
+		if err := r.deleteNativeObjects(ctx, provisioner, map[string]string{FileShareIDLabelName: fileShare.ID}); err != nil {
+			return nil, "", err
+		}
+		// The release installed under the legacy name owns the StorageClass, it is replaced by the new release.
+		if _, err := r.uninstallOwnedRelease(ctx, provisioner, r.getLegacyReleaseName(fileShare.ID)); err != nil {
+			return nil, "", err
+		}
+	}
+	chartVersionChanged, err := r.isChartVersionChanged(provisioner, currentRelease)
+	if err != nil {
+		return nil, "", err
+	}
+	// Upgrades to another chart version are rolled back on failure, so the release
+	// keeps running the previously installed version.
+	var helmOptions *gohelmclient.GenericHelmOptions
+	if chartVersionChanged {
+		log.Info("Upgrading chart version", "release", releaseName, "chartVersion", provisioner.Spec.ChartVersion)
+		helmOptions = &gohelmclient.GenericHelmOptions{RollBack: r.HelmClient}
+	}
+	overrideValues, err := r.getFileShareOverrideValues(provisioner, fileShare)
+	if err != nil {
+		return nil, "", err
+	}
+	values := mergeValues(r.getDefaultValues(provisioner), state.values)
+	values = mergeValues(values, overrideValues)
+	storageClassParameters := r.getStorageClassParameters(provisioner, fileShare)
+	values = mergeValues(values, getStorageClassParameterValues(&storageClassParameters))
+	values = mergeValues(values, getMountOptionValues(r.getMountOptions(provisioner, fileShare)))
+	values = mergeValues(values, r.getManagedValues(provisioner, fileShare, nfsServer, nfsPath, fileShare.ID == state.defaultFileShareID))
+	if legacyProvisionerName != "" {
+		values = mergeValues(values, map[string]interface{}{
+			"storageClass": map[string]interface{}{"provisionerName": legacyProvisionerName},
+		})
+	}
+	// The provisioner pods are restarted to mount the file share with changed mount options.
+	values = mergeValues(values, getMountOptionsPodAnnotationValues(getValuesMountOptions(values)))
+	valuesYaml, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, "", err
+	}
+	chartSpec := gohelmclient.ChartSpec{
+		ReleaseName: releaseName,
+		ChartName:   state.chart.Name,
 		Namespace:   provisioner.Namespace,
-		ValuesOptions: values.Options{
-			Values: []string{
-				fmt.Sprintf("nfs.server=%s", nfsServer),
-				fmt.Sprintf("nfs.path=%s", nfsPath),
-				fmt.Sprintf("storageClass.name=nfs-%s", fileShare.ID),
-				"storageClass.accessModes=ReadWriteMany",
-				"storageClass.defaultClass=false",
-				"nfs.mountOptions={soft}", // Options allow unmount volume when file share was deleted
-				fmt.Sprintf("image.tag=%s", provisioner.Spec.ImageVersion),
-				fmt.Sprintf("labels.%s=%s", NfsProvisionerIDLabelName, provisioner.UID),
-				fmt.Sprintf("labels.%s=%s", FileShareIDLabelName, fileShare.ID),
-				fmt.Sprintf("labels.%s=%s", FileShareNameLabelName, fileShare.Name),
-			},
-		}},
-		nil)
+		ValuesYaml:  string(valuesYaml),
+	}
+	desiredHash, err := getDesiredStateHash(provisioner.Spec.HelmRepository, state.chart.Digest, &chartSpec)
+	if err != nil {
+		return nil, "", err
+	}
+	if currentRelease != nil && !chartVersionChanged && desiredHash == appliedHash {
+		drift, err := r.getReleaseDrift(ctx, currentRelease)
+		if err != nil {
+			return nil, "", err
+		}
+		if drift == "" {
+			log.V(1).Info("Release is up to date", "release", releaseName)
+			if err := r.applyStorageClassLabels(ctx, r.getStorageClassName(provisioner, fileShare), storageClassParameters.StorageClassLabels); err != nil {
+				return nil, "", err
+			}
+			return currentRelease, desiredHash, nil
+		}
+		log.Info("Release has drifted", "release", releaseName, "drift", drift)
+	}
+	helmOperation := helmOperationInstall
+	if currentRelease != nil {
+		helmOperation = helmOperationUpgrade
+		if err := r.deleteChangedStorageClass(ctx, r.getStorageClassName(provisioner, fileShare), values); err != nil {
+			return nil, "", err
+		}
+	}
+	helmStart := time.Now()
+	release, err := r.HelmClient.InstallOrUpgradeChart(ctx, &chartSpec, helmOptions)
+	observeHelmOperation(helmOperation, helmStart, err)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
-	return release.Name, nil
+	if err := r.applyStorageClassLabels(ctx, r.getStorageClassName(provisioner, fileShare), storageClassParameters.StorageClassLabels); err != nil {
+		return nil, "", err
+	}
+	if release.Version == 1 {
+		r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, ReleaseInstalledEventReason,
+			"Installed release %s of chart %s version %s", release.Name, chartSpec.ChartName, release.Chart.Metadata.Version)
+	} else {
+		r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, ReleaseUpgradedEventReason,
+			"Upgraded release %s of chart %s version %s to revision %d", release.Name, chartSpec.ChartName, release.Chart.Metadata.Version, release.Version)
+	}
+	return release, desiredHash, nil
 }
 
 func (r *NfsProvisionerReconciler) reconcileDelete(ctx context.Context, provisioner *crdv1.NfsProvisioner) (ctrl.Result, error) {
-	currentReleaseNameSet, err := r.getCurrentReleaseNameSet(ctx, provisioner)
+	// FileShares delete their file shares with the credentials of the provisioner, the finalizer is kept until
+	// they are all deleted. The provisioner is reconciled again when a FileShare is deleted.
+	fileShares, err := r.getReferencingFileShares(ctx, provisioner)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(fileShares) > 0 {
+		log.FromContext(ctx).Info("Waiting for FileShares referencing the provisioner to be deleted", "fileShares", fileShares)
+		r.setCondition(provisioner, crdv1.DeletionBlockedCondition, metav1.ConditionTrue, crdv1.FileSharesReferencedReason,
+			fmt.Sprintf("FileShares still reference the provisioner: %s", strings.Join(fileShares, ", ")))
+		return ctrl.Result{}, nil
+	}
+	currentFileShareIDSet, err := r.getCurrentFileShareIDSet(ctx, provisioner)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	for releaseName := range currentReleaseNameSet {
-		err := r.HelmClient.UninstallReleaseByName(releaseName)
-		if err != nil {
+	fileShareIDs := make([]string, 0, len(currentFileShareIDSet))
+	for fileShareID := range currentFileShareIDSet {
+		fileShareIDs = append(fileShareIDs, fileShareID)
+	}
+	removedFileShareIDs, deletionBlocked, err := r.applyDeletionPolicy(ctx, provisioner, fileShareIDs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if errs := r.removeFileShareProvisioners(ctx, provisioner, removedFileShareIDs); len(errs) > 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+	// The finalizer is kept until the storage classes of the remaining file shares are no longer used.
+	if deletionBlocked {
+		return ctrl.Result{RequeueAfter: deletionBlockedRequeueDelay}, nil
+	}
+	if provisioner.Spec.DeletionPolicy == crdv1.DeletionPolicyOrphan {
+		// The namespaced native objects would be garbage collected with the provisioner.
+		if err := r.orphanProvisionerObjects(ctx, provisioner); err != nil {
 			return ctrl.Result{}, err
 		}
+		if len(fileShareIDs) > 0 {
+			r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, ProvisionersOrphanedEventReason,
+				"Orphaned provisioners of %d file shares", len(fileShareIDs))
+		}
+	} else if err := r.deleteNativeObjects(ctx, provisioner, nil); err != nil {
+		// Cluster-scoped native objects are not owned by the provisioner and are not garbage collected.
+		return ctrl.Result{}, err
+	} else if err := r.deleteDynamicStorageClasses(ctx, provisioner, ""); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.removeChartCache(provisioner); err != nil {
+		return ctrl.Result{}, err
 	}
 	if controllerutil.ContainsFinalizer(provisioner, crdv1.NfsProvisionerFinalizer) {
 		controllerutil.RemoveFinalizer(provisioner, crdv1.NfsProvisionerFinalizer)
@@ -261,23 +803,182 @@ func (r *NfsProvisionerReconciler) reconcileDelete(ctx context.Context, provisio
 			return ctrl.Result{}, err
 		}
 	}
+	deleteProvisionerMetrics(provisioner)
 
 	return ctrl.Result{}, nil
 }
 
+// removeFileShareProvisioners uninstalls the releases and deletes the native objects of the file shares
+// in parallel and returns the errors of the failed file shares.
+func (r *NfsProvisionerReconciler) removeFileShareProvisioners(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareIDs []string) []error {
+	log := log.FromContext(ctx)
+
+	fileShareErrs := make([]error, len(fileShareIDs))
+	r.runConcurrently(provisioner, len(fileShareIDs), func(i int) {
+		releaseName := r.getReleaseName(provisioner, fileShareIDs[i])
+		if err := r.removeFileShareProvisioner(ctx, provisioner, fileShareIDs[i]); err != nil {
+			log.Error(err, "failed uninstall chart", "namespace", provisioner.Namespace, "release", releaseName)
+			r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, ReleaseUninstallFailedEventReason,
+				"Failed to uninstall release %s: %v", releaseName, err)
+			fileShareErrs[i] = fmt.Errorf("release %s: %w", releaseName, err)
+		}
+	})
+	var errs []error
+	for _, err := range fileShareErrs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// removeFileShareProvisioner uninstalls the releases and deletes the native objects of the file share
+// owned by the provisioner.
+func (r *NfsProvisionerReconciler) removeFileShareProvisioner(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareID string) error {
+	releaseName := r.getReleaseName(provisioner, fileShareID)
+	for _, name := range []string{releaseName, r.getLegacyReleaseName(fileShareID)} {
+		if _, err := r.uninstallOwnedRelease(ctx, provisioner, name); err != nil {
+			return err
+		}
+	}
+	if err := r.deleteNativeObjects(ctx, provisioner, map[string]string{FileShareIDLabelName: fileShareID}); err != nil {
+		return err
+	}
+	// Storage classes retained after a rename are not part of the release.
+	if err := r.Client.DeleteAllOf(ctx, &storagev1.StorageClass{}, client.MatchingLabels{
+		NfsProvisionerIDLabelName: string(provisioner.UID),
+		FileShareIDLabelName:      fileShareID,
+	}); err != nil {
+		return err
+	}
+	if provisioner.Spec.DeploymentMode == crdv1.DeploymentModeNative {
+		r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, ProvisionerObjectsDeletedEventReason,
+			"Deleted provisioner objects %s of file share %s", releaseName, fileShareID)
+	}
+	return nil
+}
+
+func (r *NfsProvisionerReconciler) uninstallRelease(releaseName string) error {
+	start := time.Now()
+	err := r.HelmClient.UninstallReleaseByName(releaseName)
+	observeHelmOperation(helmOperationUninstall, start, err)
+	return err
+}
+
+// getHelmConcurrency returns the number of Helm releases of the provisioner processed in parallel.
+func (r *NfsProvisionerReconciler) getHelmConcurrency(provisioner *crdv1.NfsProvisioner) int {
+	if provisioner.Spec.HelmConcurrency != nil {
+		return int(*provisioner.Spec.HelmConcurrency)
+	}
+	if r.HelmConcurrency > 0 {
+		return r.HelmConcurrency
+	}
+	return DefaultHelmConcurrency
+}
+
+// runConcurrently calls fn for every index in [0, n) using at most the Helm concurrency of the provisioner.
+// fn must not modify the provisioner, results are returned through slices indexed by i.
+func (r *NfsProvisionerReconciler) runConcurrently(provisioner *crdv1.NfsProvisioner, n int, fn func(i int)) {
+	group := errgroup.Group{}
+	group.SetLimit(r.getHelmConcurrency(provisioner))
+	for i := 0; i < n; i++ {
+		i := i
+		group.Go(func() error {
+			fn(i)
+			return nil
+		})
+	}
+	_ = group.Wait()
+}
+
 func (r *NfsProvisionerReconciler) getNfsServerAndPath(fileShare *file_shares.FileShare) (string, string, error) {
+	return splitConnectionPoint(fileShare.ConnectionPoint)
+}
+
+func splitConnectionPoint(connectionPoint string) (string, string, error) {
 	// Connection point  "10.33.20.241:/shares/share-e1dca5e4-257d-47c2-82ac-980fa43e0da9"
-	ServerAndPath := strings.Split(fileShare.ConnectionPoint, ":")
+	ServerAndPath := strings.Split(connectionPoint, ":")
 	//nolint: gomnd
 	if len(ServerAndPath) != 2 {
-		return "", "", fmt.Errorf("incorrect file share connection point %v", fileShare.ConnectionPoint)
+		return "", "", fmt.Errorf("incorrect file share connection point %v", connectionPoint)
 	}
 	return ServerAndPath[0], ServerAndPath[1], nil
 }
 
+// findProvisionersForRef returns a function mapping a referenced object to the NfsProvisioners
+// referencing it through the index, so changes of the object trigger a reconcile.
+func (r *NfsProvisionerReconciler) findProvisionersForRef(index string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		provisionerList := crdv1.NfsProvisionerList{}
+		objName := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+		if err := r.Client.List(ctx, &provisionerList, client.MatchingFields{index: objName.String()}); err != nil {
+			log.FromContext(ctx).Error(err, "failed list provisioners for referenced object", "index", index, "object", objName)
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(provisionerList.Items))
+		for _, provisioner := range provisionerList.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Name},
+			})
+		}
+		return requests
+	}
+}
+
+// findProvisionerForFileShare maps a FileShare to the NfsProvisioner it references, so the provisioner
+// of the file share is deployed as soon as the file share is available.
+func (r *NfsProvisionerReconciler) findProvisionerForFileShare(ctx context.Context, obj client.Object) []reconcile.Request {
+	fileShare := obj.(*crdv1.FileShare)
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: fileShare.Namespace, Name: fileShare.Spec.ProvisionerRef.Name},
+	}}
+}
+
+func getSecretRefs(obj client.Object) []string {
+	provisioner := obj.(*crdv1.NfsProvisioner)
+	refs := []string{}
+	if provisioner.Spec.APITokenSecretRef != nil {
+		refs = append(refs, provisioner.APITokenSecretName().String())
+	}
+	if provisioner.Spec.HelmRepositorySecretRef != nil {
+		refs = append(refs, types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Spec.HelmRepositorySecretRef.Name}.String())
+	}
+	for _, valuesRef := range provisioner.Spec.ValuesFrom {
+		if valuesRef.Kind == crdv1.ValuesKindSecret {
+			refs = append(refs, types.NamespacedName{Namespace: provisioner.Namespace, Name: valuesRef.Name}.String())
+		}
+	}
+	return refs
+}
+
+func getConfigMapRefs(obj client.Object) []string {
+	provisioner := obj.(*crdv1.NfsProvisioner)
+	refs := []string{}
+	if provisioner.Spec.ChartConfigMapRef != nil {
+		refs = append(refs, types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Spec.ChartConfigMapRef.Name}.String())
+	}
+	for _, valuesRef := range provisioner.Spec.ValuesFrom {
+		if valuesRef.Kind == crdv1.ValuesKindConfigMap {
+			refs = append(refs, types.NamespacedName{Namespace: provisioner.Namespace, Name: valuesRef.Name}.String())
+		}
+	}
+	return refs
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *NfsProvisionerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &crdv1.NfsProvisioner{}, secretRefIndex, getSecretRefs); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &crdv1.NfsProvisioner{}, configMapRefIndex, getConfigMapRefs); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&crdv1.NfsProvisioner{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findProvisionersForRef(secretRefIndex))).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.findProvisionersForRef(configMapRefIndex))).
+		Watches(&crdv1.FileShare{}, handler.EnqueueRequestsFromMapFunc(r.findProvisionerForFileShare)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.findProvisionersForNode), builder.WithPredicates(nodeAccessChangedPredicate)).
 		Complete(r)
 }
@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"sync/atomic"
+	"time"
+
 	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
 	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
 	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
@@ -8,8 +11,10 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -61,14 +66,16 @@ var _ = Describe("NfsProvisioner Reconciler", func() {
 			ProjectID:       1,
 			RegionID:        1,
 		}
-		fileShareLiseter := gcoreclient.MockFileShareClient{
+		fileShareLiseter := &gcoreclient.MockFileShareManager{
 			FileShares: []file_shares.FileShare{
 				fileShare,
 			},
 		}
 
+		recorder := record.NewFakeRecorder(100)
 		reconciler := NfsProvisionerReconciler{
 			Client:          k8sClient,
+			Recorder:        recorder,
 			HelmClient:      helmClient,
 			FileShareClient: fileShareLiseter,
 		}
@@ -80,6 +87,8 @@ var _ = Describe("NfsProvisioner Reconciler", func() {
 					Name:      testNfsProvisionerName,
 				}})
 		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareDiscoveredEventReason)))
+		Expect(recorder.Events).To(Receive(ContainSubstring(ReleaseInstalledEventReason)))
 
 		storageClassList := storagev1.StorageClassList{}
 		err = k8sClient.List(ctx, &storageClassList)
@@ -91,6 +100,38 @@ var _ = Describe("NfsProvisioner Reconciler", func() {
 		Expect(storageClass.Labels["fileShareName"]).To(Equal(fileShare.Name))
 		Expect(storageClass.Labels["fileShareID"]).To(Equal(fileShare.ID))
 
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: DefaultNamespace, Name: testNfsProvisionerName}, &provisioner)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provisioner.Status.ObservedGeneration).To(Equal(provisioner.Generation))
+		Expect(provisioner.Status.LastSyncTime).NotTo(BeNil())
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.CloudAPIReachableCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.HelmReleasesSyncedCondition)).To(BeTrue())
+		Expect(provisioner.Status.FileShares).To(HaveLen(1))
+		fileShareStatus := provisioner.Status.FileShares[0]
+		Expect(fileShareStatus.ID).To(Equal(fileShare.ID))
+		Expect(fileShareStatus.ConnectionPoint).To(Equal(fileShare.ConnectionPoint))
+		Expect(fileShareStatus.StorageClassName).To(Equal("nfs-" + fileShare.ID))
+		Expect(fileShareStatus.ReleaseName).To(Equal(reconciler.getReleaseName(&provisioner, fileShare.ID)))
+		Expect(fileShareStatus.AppliedHash).NotTo(BeEmpty())
+
+		// Reconcile without changes must not upgrade the release
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: DefaultNamespace, Name: testNfsProvisionerName}}
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		currentRelease, err := helmClient.GetRelease(fileShareStatus.ReleaseName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(currentRelease.Version).To(Equal(1))
+
+		// Storage class deleted out-of-band must be restored by an upgrade
+		Expect(k8sClient.Delete(ctx, &storageClass)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		currentRelease, err = helmClient.GetRelease(fileShareStatus.ReleaseName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(currentRelease.Version).To(Equal(2))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storagev1.StorageClass{})).To(Succeed())
+		Expect(k8sClient.Get(ctx, request.NamespacedName, &provisioner)).To(Succeed())
+
 		// Remove provisioner and check that resources are deleted after reconciliation
 		err = k8sClient.Delete(ctx, &provisioner)
 		Expect(err).NotTo(HaveOccurred())
@@ -110,4 +151,122 @@ var _ = Describe("NfsProvisioner Reconciler", func() {
 		Expect(len(storageClassList.Items)).To(Equal(0))
 
 	})
+	It("Failed file share should not block other file shares", func() {
+		provisionerName := types.NamespacedName{Namespace: DefaultNamespace, Name: "test-provisioner-failures"}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      provisionerName.Name,
+				Namespace: provisionerName.Namespace,
+			},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:       "faketoken",
+				APIURL:         "http://127.0.0.1",
+				RegionID:       2,
+				ProjectID:      5,
+				HelmRepository: "https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner",
+				ChartName:      "nfs-subdir-external-provisioner",
+				ImageVersion:   "v4.0.2",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+
+		helmClient, err := gohelmclient.NewClientFromRestConf(
+			&gohelmclient.RestConfClientOptions{
+				Options:    &gohelmclient.Options{},
+				RestConfig: cfg,
+			})
+		Expect(err).NotTo(HaveOccurred())
+		brokenFileShare := file_shares.FileShare{
+			Name:            "broken_file_share",
+			ID:              "5b0f3a52-2d7e-4f61-9a51-0c3c2b1f9e77",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            2,
+			ConnectionPoint: "10.33.20.92",
+		}
+		fileShare := file_shares.FileShare{
+			Name:            "healthy_file_share",
+			ID:              "8e6c1f0a-4b1d-4f3e-8a7c-2d9e5b3a1c44",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            2,
+			ConnectionPoint: "10.33.20.93:/shares/share-8e6c1f0a-4b1d-4f3e-8a7c-2d9e5b3a1c44",
+		}
+		reconciler := NfsProvisionerReconciler{
+			Client:     k8sClient,
+			Recorder:   record.NewFakeRecorder(100),
+			HelmClient: helmClient,
+			FileShareClient: &gcoreclient.MockFileShareManager{
+				FileShares: []file_shares.FileShare{brokenFileShare, fileShare},
+			},
+		}
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+		storageClass := storagev1.StorageClass{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "nfs-" + fileShare.ID}, &storageClass)).To(Succeed())
+
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(provisioner.Status.Conditions, crdv1.HelmReleasesSyncedCondition)).To(BeTrue())
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.DegradedCondition)).To(BeTrue())
+		Expect(provisioner.Status.FileShares).To(HaveLen(2))
+		brokenStatus := provisioner.Status.FileShares[0]
+		Expect(brokenStatus.Failures).To(Equal(int32(1)))
+		Expect(brokenStatus.NextRetryTime).NotTo(BeNil())
+		Expect(brokenStatus.Message).To(ContainSubstring("incorrect file share connection point"))
+		Expect(provisioner.Status.FileShares[1].ReleaseName).To(Equal(reconciler.getReleaseName(&provisioner, fileShare.ID)))
+
+		// The failed file share is skipped until its next retry
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(provisioner.Status.FileShares[0].Failures).To(Equal(int32(1)))
+
+		// A spec change retries the failed file share right away
+		helmConcurrency := int32(2)
+		provisioner.Spec.HelmConcurrency = &helmConcurrency
+		Expect(k8sClient.Update(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(provisioner.Status.FileShares[0].Failures).To(Equal(int32(2)))
+
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("File share retry delay should grow exponentially", func() {
+		Expect(getFileShareRetryDelay(1)).To(Equal(fileShareRetryBaseDelay))
+		Expect(getFileShareRetryDelay(2)).To(Equal(2 * fileShareRetryBaseDelay))
+		Expect(getFileShareRetryDelay(3)).To(Equal(4 * fileShareRetryBaseDelay))
+		Expect(getFileShareRetryDelay(100)).To(Equal(fileShareRetryMaxDelay))
+	})
+	It("Helm operations should run concurrently up to the helm concurrency", func() {
+		reconciler := NfsProvisionerReconciler{HelmConcurrency: 2}
+		provisioner := crdv1.NfsProvisioner{}
+		Expect(reconciler.getHelmConcurrency(&provisioner)).To(Equal(2))
+
+		var running, maxRunning int32
+		calls := make([]bool, 10)
+		reconciler.runConcurrently(&provisioner, len(calls), func(i int) {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				observed := atomic.LoadInt32(&maxRunning)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			calls[i] = true
+			atomic.AddInt32(&running, -1)
+		})
+		Expect(calls).NotTo(ContainElement(false))
+		Expect(maxRunning).To(Equal(int32(2)))
+
+		helmConcurrency := int32(3)
+		provisioner.Spec.HelmConcurrency = &helmConcurrency
+		Expect(reconciler.getHelmConcurrency(&provisioner)).To(Equal(3))
+		Expect((&NfsProvisionerReconciler{}).getHelmConcurrency(&crdv1.NfsProvisioner{})).To(Equal(DefaultHelmConcurrency))
+	})
 })
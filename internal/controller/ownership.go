@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// helmReleaseNameMaxLength is the maximum length of a Helm release name.
+const helmReleaseNameMaxLength = 53
+
+// fileShareClaimedError reports an object of a file share which is already owned by another NfsProvisioner
+// or another file share.
+type fileShareClaimedError struct {
+	kind      string
+	name      string
+	claimedBy string
+}
+
+func (e *fileShareClaimedError) Error() string {
+	return fmt.Sprintf("%s %s is already claimed by %s", e.kind, e.name, e.claimedBy)
+}
+
+// getProvisionerHash returns a short hash of the provisioner namespace and name,
+// it identifies the provisioner in the names of the objects it deploys.
+func (r *NfsProvisionerReconciler) getProvisionerHash(provisioner *crdv1.NfsProvisioner) string {
+	sum := sha256.Sum256([]byte(types.NamespacedName{Namespace: provisioner.Namespace, Name: provisioner.Name}.String()))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// getReleaseName returns the provisioner release name of the file share. It is unique across provisioners,
+// so releases and the cluster-scoped objects named after them do not collide between namespaces.
+func (r *NfsProvisionerReconciler) getReleaseName(provisioner *crdv1.NfsProvisioner, fileShareID string) string {
+	releaseName := fmt.Sprintf("nfs-%s-%s", r.getProvisionerHash(provisioner), fileShareID)
+	if len(releaseName) > helmReleaseNameMaxLength {
+		sum := sha256.Sum256([]byte(fileShareID))
+		releaseName = fmt.Sprintf("nfs-%s-%s", r.getProvisionerHash(provisioner), hex.EncodeToString(sum[:])[:16])
+	}
+	return releaseName
+}
+
+// getLegacyReleaseName returns the release name of the file share used before release names
+// included the provisioner identity.
+func (r *NfsProvisionerReconciler) getLegacyReleaseName(fileShareID string) string {
+	return fmt.Sprintf("nfsprovisioner-%s", fileShareID)
+}
+
+// getLegacyProvisionerName returns the provisioner name of the file share deployed under the legacy release name
+// when its StorageClass or a PersistentVolume of the StorageClass still uses it, or "" otherwise. The name is
+// recorded in the pv.kubernetes.io/provisioned-by annotation of the volumes, which are only deleted by a
+// provisioner of that name, and the provisioner of a StorageClass is immutable, so it is kept after the rename.
+func (r *NfsProvisionerReconciler) getLegacyProvisionerName(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) (string, error) {
+	legacyReleaseName := r.getLegacyReleaseName(fileShare.ID)
+	storageClassName := r.getStorageClassName(provisioner, fileShare)
+	storageClass := storagev1.StorageClass{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: storageClassName}, &storageClass)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil && strings.Contains(storageClass.Provisioner, legacyReleaseName) {
+		return storageClass.Provisioner, nil
+	}
+	volumeList := corev1.PersistentVolumeList{}
+	if err := r.Client.List(ctx, &volumeList); err != nil {
+		return "", err
+	}
+	for _, volume := range volumeList.Items {
+		provisionedBy := volume.Annotations[provisionedByAnnotationName]
+		if volume.Spec.StorageClassName == storageClassName && strings.Contains(provisionedBy, legacyReleaseName) {
+			return provisionedBy, nil
+		}
+	}
+	return "", nil
+}
+
+// getReleaseOwnerID returns the UID of the provisioner which installed the release, taken from the managed values.
+func getReleaseOwnerID(currentRelease *release.Release) string {
+	labels, _ := currentRelease.Config["labels"].(map[string]interface{})
+	ownerID, _ := labels[NfsProvisionerIDLabelName].(string)
+	return ownerID
+}
+
+// findProvisioner returns the provisioner with the UID, or nil when it does not exist.
+func (r *NfsProvisionerReconciler) findProvisioner(ctx context.Context, ownerID string) (*crdv1.NfsProvisioner, error) {
+	provisionerList := crdv1.NfsProvisionerList{}
+	if err := r.Client.List(ctx, &provisionerList); err != nil {
+		return nil, err
+	}
+	for i := range provisionerList.Items {
+		if string(provisionerList.Items[i].UID) == ownerID {
+			return &provisionerList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// getOwnerName describes the provisioner with the UID for status messages.
+func (r *NfsProvisionerReconciler) getOwnerName(ctx context.Context, ownerID string) (string, error) {
+	if ownerID == "" {
+		return "an object not managed by an NfsProvisioner", nil
+	}
+	owner, err := r.findProvisioner(ctx, ownerID)
+	if err != nil {
+		return "", err
+	}
+	if owner != nil {
+		return fmt.Sprintf("NfsProvisioner %s/%s", owner.Namespace, owner.Name), nil
+	}
+	return fmt.Sprintf("NfsProvisioner with UID %s", ownerID), nil
+}
+
+// checkFileShareOwnership returns a fileShareClaimedError when the StorageClass or the release of the file share
+// exists and is owned by another provisioner, or the StorageClass name is taken by another file share.
+// Claimed objects are never upgraded or deleted by the provisioner. Objects orphaned by a former provisioner with
+// the same namespace and name are adopted.
+func (r *NfsProvisionerReconciler) checkFileShareOwnership(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, storageClassOwners map[string]*file_shares.FileShare) error {
+	storageClassName := r.getStorageClassName(provisioner, fileShare)
+	if owner := storageClassOwners[storageClassName]; owner != nil && owner.ID != fileShare.ID {
+		return &fileShareClaimedError{kind: "StorageClass", name: storageClassName, claimedBy: fmt.Sprintf("file share %s", owner.Name)}
+	}
+	storageClass := storagev1.StorageClass{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: storageClassName}, &storageClass)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	ownerID := storageClass.Labels[NfsProvisionerIDLabelName]
+	adoptable := ownerID == "" && r.isAdoptable(&storageClass, provisioner)
+	if err == nil && ownerID != string(provisioner.UID) && !adoptable {
+		claimedBy, err := r.getOwnerName(ctx, storageClass.Labels[NfsProvisionerIDLabelName])
+		if err != nil {
+			return err
+		}
+		return &fileShareClaimedError{kind: "StorageClass", name: storageClassName, claimedBy: claimedBy}
+	}
+	if err == nil && storageClass.Labels[FileShareIDLabelName] != fileShare.ID {
+		claimedBy := fmt.Sprintf("file share %s", storageClass.Labels[FileShareIDLabelName])
+		return &fileShareClaimedError{kind: "StorageClass", name: storageClassName, claimedBy: claimedBy}
+	}
+	releaseName := r.getReleaseName(provisioner, fileShare.ID)
+	currentRelease, err := r.getCurrentRelease(releaseName)
+	if err != nil {
+		return err
+	}
+	if currentRelease != nil && getReleaseOwnerID(currentRelease) != string(provisioner.UID) {
+		// The release name includes the hash of the provisioner namespace and name, a release of a deleted
+		// owner was orphaned by a former provisioner with the same namespace and name.
+		owner, err := r.findProvisioner(ctx, getReleaseOwnerID(currentRelease))
+		if err != nil {
+			return err
+		}
+		if owner != nil || getReleaseOwnerID(currentRelease) == "" {
+			claimedBy, err := r.getOwnerName(ctx, getReleaseOwnerID(currentRelease))
+			if err != nil {
+				return err
+			}
+			return &fileShareClaimedError{kind: "Release", name: releaseName, claimedBy: claimedBy}
+		}
+	}
+	return nil
+}
+
+// checkDynamicStorageClassOwnership returns a fileShareClaimedError when the storage class of
+// spec.dynamicProvisioning exists and is not managed by the provisioner. Storage classes orphaned by a former
+// provisioner with the same namespace and name are adopted.
+func (r *NfsProvisionerReconciler) checkDynamicStorageClassOwnership(ctx context.Context, provisioner *crdv1.NfsProvisioner) error {
+	if provisioner.Spec.DynamicProvisioning == nil {
+		return nil
+	}
+	storageClass := storagev1.StorageClass{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: provisioner.Spec.DynamicProvisioning.StorageClassName}, &storageClass)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ownerID := storageClass.Labels[DynamicNfsProvisionerIDLabelName]
+	if storageClass.Labels[managedByLabelName] == NativeFieldManager &&
+		(ownerID == string(provisioner.UID) || ownerID == "" && r.isAdoptable(&storageClass, provisioner)) {
+		return nil
+	}
+	if ownerID == "" {
+		ownerID = storageClass.Labels[NfsProvisionerIDLabelName]
+	}
+	claimedBy, err := r.getOwnerName(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	return &fileShareClaimedError{kind: "StorageClass", name: storageClass.Name, claimedBy: claimedBy}
+}
+
+// uninstallOwnedRelease uninstalls the release when it is installed by the provisioner
+// and reports whether it was uninstalled. Releases of other provisioners are left intact.
+func (r *NfsProvisionerReconciler) uninstallOwnedRelease(ctx context.Context, provisioner *crdv1.NfsProvisioner, releaseName string) (bool, error) {
+	currentRelease, err := r.getCurrentRelease(releaseName)
+	if err != nil || currentRelease == nil {
+		return false, err
+	}
+	if getReleaseOwnerID(currentRelease) != string(provisioner.UID) {
+		log.FromContext(ctx).Info("Skip uninstalling release of another provisioner", "release", releaseName)
+		return false, nil
+	}
+	if err := r.uninstallRelease(releaseName); err != nil {
+		return false, err
+	}
+	r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, ReleaseUninstalledEventReason,
+		"Uninstalled release %s", releaseName)
+	return true, nil
+}
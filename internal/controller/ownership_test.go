@@ -0,0 +1,181 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	gohelmclient "github.com/mittwald/go-helm-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("File share ownership", func() {
+	It("Release names should be unique per provisioner", func() {
+		reconciler := NfsProvisionerReconciler{}
+		fileShareID := "d918f840-29a2-4d54-a67e-5c9d4e34a408"
+		first := crdv1.NfsProvisioner{ObjectMeta: metav1.ObjectMeta{Name: "provisioner", Namespace: "first"}}
+		second := crdv1.NfsProvisioner{ObjectMeta: metav1.ObjectMeta{Name: "provisioner", Namespace: "second"}}
+		Expect(reconciler.getReleaseName(&first, fileShareID)).To(Equal(reconciler.getReleaseName(&first, fileShareID)))
+		Expect(reconciler.getReleaseName(&first, fileShareID)).NotTo(Equal(reconciler.getReleaseName(&second, fileShareID)))
+		Expect(len(reconciler.getReleaseName(&first, fileShareID))).To(BeNumerically("<=", helmReleaseNameMaxLength))
+		Expect(len(reconciler.getReleaseName(&first, fileShareID+fileShareID))).To(BeNumerically("<=", helmReleaseNameMaxLength))
+	})
+
+	It("File share claimed by another provisioner should be reported as a conflict", func() {
+		fileShare := file_shares.FileShare{
+			Name:            "claimed_file_share",
+			ID:              "7e1f0c2a-5b3d-4a8e-9f6c-2d4b8a1e3c57",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            2,
+			ConnectionPoint: "10.33.20.95:/shares/share-7e1f0c2a-5b3d-4a8e-9f6c-2d4b8a1e3c57",
+		}
+		claimedStorageClass := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "nfs-" + fileShare.ID,
+				Labels: map[string]string{NfsProvisionerIDLabelName: "other-provisioner-uid", FileShareIDLabelName: fileShare.ID},
+			},
+			Provisioner: "cluster.local/other",
+		}
+		Expect(k8sClient.Create(ctx, &claimedStorageClass)).To(Succeed())
+
+		provisionerName := types.NamespacedName{Namespace: DefaultNamespace, Name: "test-provisioner-conflict"}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: provisionerName.Name, Namespace: provisionerName.Namespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:       "faketoken",
+				APIURL:         "http://127.0.0.1",
+				RegionID:       2,
+				ProjectID:      5,
+				DeploymentMode: crdv1.DeploymentModeNative,
+				ImageVersion:   "v4.0.2",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		helmClient, err := gohelmclient.NewClientFromRestConf(
+			&gohelmclient.RestConfClientOptions{
+				Options:    &gohelmclient.Options{},
+				RestConfig: cfg,
+			})
+		Expect(err).NotTo(HaveOccurred())
+		recorder := record.NewFakeRecorder(100)
+		reconciler := NfsProvisionerReconciler{
+			Client:          k8sClient,
+			Recorder:        recorder,
+			HelmClient:      helmClient,
+			FileShareClient: &gcoreclient.MockFileShareManager{FileShares: []file_shares.FileShare{fileShare}},
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareDiscoveredEventReason)))
+		Expect(recorder.Events).To(Receive(ContainSubstring(FileShareConflictEventReason)))
+
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.ConflictCondition)).To(BeTrue())
+		Expect(provisioner.Status.FileShares).To(HaveLen(1))
+		Expect(provisioner.Status.FileShares[0].ClaimedBy).To(Equal("NfsProvisioner with UID other-provisioner-uid"))
+		Expect(provisioner.Status.FileShares[0].Ready).To(BeFalse())
+		deploymentName := types.NamespacedName{Namespace: DefaultNamespace, Name: reconciler.getReleaseName(&provisioner, fileShare.ID)}
+		err = k8sClient.Get(ctx, deploymentName, &appsv1.Deployment{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// The claimed StorageClass is left intact when the provisioner is deleted
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: claimedStorageClass.Name}, &claimedStorageClass)).To(Succeed())
+		Expect(claimedStorageClass.Labels).To(HaveKeyWithValue(NfsProvisionerIDLabelName, "other-provisioner-uid"))
+		Expect(k8sClient.Delete(ctx, &claimedStorageClass)).To(Succeed())
+	})
+
+	It("Volumes provisioned before the release rename should keep their provisioner", func() {
+		fileShare := file_shares.FileShare{
+			Name:            "legacy_file_share",
+			ID:              "4a9c2e7b-1d3f-4b6a-8e2c-5f7d9b1a3c64",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            2,
+			ConnectionPoint: "10.33.20.95:/shares/share-4a9c2e7b-1d3f-4b6a-8e2c-5f7d9b1a3c64",
+		}
+		provisionerName := types.NamespacedName{Namespace: DefaultNamespace, Name: "test-provisioner-legacy"}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: provisionerName.Name, Namespace: provisionerName.Namespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:       "faketoken",
+				APIURL:         "http://127.0.0.1",
+				RegionID:       2,
+				ProjectID:      5,
+				DeploymentMode: crdv1.DeploymentModeNative,
+				ImageVersion:   "v4.0.2",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		legacyProvisionerName := "cluster.local/nfsprovisioner-" + fileShare.ID
+		legacyStorageClass := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "nfs-" + fileShare.ID,
+				Labels: map[string]string{NfsProvisionerIDLabelName: string(provisioner.UID), FileShareIDLabelName: fileShare.ID},
+			},
+			Provisioner: legacyProvisionerName,
+		}
+		Expect(k8sClient.Create(ctx, &legacyStorageClass)).To(Succeed())
+		volume := corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "legacy-volume",
+				Annotations: map[string]string{provisionedByAnnotationName: legacyProvisionerName},
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				StorageClassName: legacyStorageClass.Name,
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					NFS: &corev1.NFSVolumeSource{Server: "10.33.20.95", Path: "/shares/share/legacy-volume"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &volume)).To(Succeed())
+
+		helmClient, err := gohelmclient.NewClientFromRestConf(
+			&gohelmclient.RestConfClientOptions{
+				Options:    &gohelmclient.Options{},
+				RestConfig: cfg,
+			})
+		Expect(err).NotTo(HaveOccurred())
+		reconciler := NfsProvisionerReconciler{
+			Client:          k8sClient,
+			Recorder:        record.NewFakeRecorder(100),
+			HelmClient:      helmClient,
+			FileShareClient: &gcoreclient.MockFileShareManager{FileShares: []file_shares.FileShare{fileShare}},
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+
+		// The provisioner deployed under the new name keeps serving the legacy provisioner name
+		storageClass := storagev1.StorageClass{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: legacyStorageClass.Name}, &storageClass)).To(Succeed())
+		Expect(storageClass.Provisioner).To(Equal(legacyProvisionerName))
+		deployment := appsv1.Deployment{}
+		deploymentName := types.NamespacedName{Namespace: DefaultNamespace, Name: reconciler.getReleaseName(&provisioner, fileShare.ID)}
+		Expect(k8sClient.Get(ctx, deploymentName, &deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "PROVISIONER_NAME", Value: legacyProvisionerName}))
+
+		// The legacy provisioner name is kept while a volume uses it, even without the StorageClass
+		Expect(k8sClient.Delete(ctx, &storageClass)).To(Succeed())
+		Expect(reconciler.getLegacyProvisionerName(ctx, &provisioner, &fileShare)).To(Equal(legacyProvisionerName))
+		Expect(k8sClient.Delete(ctx, &volume)).To(Succeed())
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
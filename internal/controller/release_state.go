@@ -0,0 +1,188 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	gohelmclient "github.com/mittwald/go-helm-client"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// desiredState is the input of a Helm install or upgrade, a release is upgraded only when its hash changes.
+type desiredState struct {
+	Repository  string `json:"repository"`
+	ChartName   string `json:"chartName"`
+	ChartDigest string `json:"chartDigest"`
+	Version     string `json:"version"`
+	Namespace   string `json:"namespace"`
+	ReleaseName string `json:"releaseName"`
+	Values      string `json:"values"`
+}
+
+// getDesiredStateHash returns the hash of the chart reference, chart version and rendered values of the chart spec,
+// chartDigest is the digest of a local chart archive.
+func getDesiredStateHash(repository string, chartDigest string, chartSpec *gohelmclient.ChartSpec) (string, error) {
+	data, err := json.Marshal(desiredState{
+		Repository:  repository,
+		ChartName:   chartSpec.ChartName,
+		ChartDigest: chartDigest,
+		Version:     chartSpec.Version,
+		Namespace:   chartSpec.Namespace,
+		ReleaseName: chartSpec.ReleaseName,
+		Values:      chartSpec.ValuesYaml,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// getReleaseDrift returns why the release differs from its last applied state, it is empty when the release
+// is deployed and all objects of its manifest exist with the fields and labels of the manifest.
+func (r *NfsProvisionerReconciler) getReleaseDrift(ctx context.Context, currentRelease *release.Release) (string, error) {
+	if currentRelease.Info == nil || currentRelease.Info.Status != release.StatusDeployed {
+		status := release.StatusUnknown
+		if currentRelease.Info != nil {
+			status = currentRelease.Info.Status
+		}
+		return fmt.Sprintf("release is %s", status), nil
+	}
+	for _, manifest := range releaseutil.SplitManifests(currentRelease.Manifest) {
+		obj := unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(manifest), &obj.Object); err != nil {
+			return "", fmt.Errorf("parse manifest of release %s: %w", currentRelease.Name, err)
+		}
+		if obj.Object == nil || obj.GetKind() == "" {
+			continue
+		}
+		kind := obj.GetKind()
+		namespaced, err := r.Client.IsObjectNamespaced(&obj)
+		if err != nil {
+			return "", err
+		}
+		name := types.NamespacedName{Name: obj.GetName()}
+		if namespaced {
+			name.Namespace = obj.GetNamespace()
+			if name.Namespace == "" {
+				name.Namespace = currentRelease.Namespace
+			}
+		}
+		live := unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := r.Client.Get(ctx, name, &live); err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Sprintf("%s %s is missing", kind, name), nil
+			}
+			return "", err
+		}
+		if path := getManifestObjectDiff(&obj, &live); path != "" {
+			return fmt.Sprintf("%s %s was changed at %s", kind, name, path), nil
+		}
+	}
+	return "", nil
+}
+
+// getManifestObjectDiff returns the path of the first field of the manifest object which differs in the live
+// object, or "" when the live object has all fields of the manifest. Fields added by the API server or other
+// controllers are ignored, only the labels and annotations of the metadata are compared.
+func getManifestObjectDiff(obj *unstructured.Unstructured, live *unstructured.Unstructured) string {
+	for key, value := range obj.Object {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			for _, field := range []string{"labels", "annotations"} {
+				desired, _, _ := unstructured.NestedFieldNoCopy(obj.Object, key, field)
+				actual, _, _ := unstructured.NestedFieldNoCopy(live.Object, key, field)
+				if path := getManifestValueDiff(desired, actual, key+"."+field); path != "" {
+					return path
+				}
+			}
+		default:
+			if path := getManifestValueDiff(value, live.Object[key], key); path != "" {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// getManifestValueDiff returns the path of the first value of desired which differs in actual, or "".
+// Empty maps and lists of desired may be omitted by the API server and quantities are compared by their value.
+func getManifestValueDiff(desired interface{}, actual interface{}, path string) string {
+	switch desired := desired.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}:
+		if len(desired) == 0 {
+			return ""
+		}
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return path
+		}
+		for key, value := range desired {
+			if diff := getManifestValueDiff(value, actual[key], path+"."+key); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	case []interface{}:
+		if len(desired) == 0 {
+			return ""
+		}
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(desired) {
+			return path
+		}
+		for i := range desired {
+			if diff := getManifestValueDiff(desired[i], actual[i], fmt.Sprintf("%s[%d]", path, i)); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	default:
+		if actual == nil {
+			// Zero values of optional fields are omitted by the API server.
+			if zero := fmt.Sprint(desired); zero == "" || zero == "false" || zero == "0" {
+				return ""
+			}
+			return path
+		}
+		if fmt.Sprint(desired) == fmt.Sprint(actual) {
+			return ""
+		}
+		desiredQuantity, desiredErr := resource.ParseQuantity(fmt.Sprint(desired))
+		actualQuantity, actualErr := resource.ParseQuantity(fmt.Sprint(actual))
+		if desiredErr == nil && actualErr == nil && desiredQuantity.Cmp(actualQuantity) == 0 {
+			return ""
+		}
+		return path
+	}
+}
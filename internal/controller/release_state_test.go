@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"strings"
+
+	gohelmclient "github.com/mittwald/go-helm-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Release state", func() {
+	It("Desired state hash should change only with the chart spec", func() {
+		chartSpec := gohelmclient.ChartSpec{
+			ReleaseName: "nfsprovisioner-d918f840-29a2-4d54-a67e-5c9d4e34a408",
+			ChartName:   "nfs-subdir-external-provisioner/nfs-subdir-external-provisioner",
+			Version:     "4.0.18",
+			Namespace:   DefaultNamespace,
+			ValuesYaml:  "nfs:\n  server: 10.33.20.91\n",
+		}
+		hash, err := getDesiredStateHash("https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner", "", &chartSpec)
+		Expect(err).NotTo(HaveOccurred())
+		sameHash, err := getDesiredStateHash("https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner", "", &chartSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sameHash).To(Equal(hash))
+
+		changedSpec := chartSpec
+		changedSpec.ValuesYaml = "nfs:\n  server: 10.33.20.92\n"
+		changedHash, err := getDesiredStateHash("https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner", "", &changedSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changedHash).NotTo(Equal(hash))
+
+		changedSpec = chartSpec
+		changedSpec.Version = "4.0.17"
+		changedHash, err = getDesiredStateHash("https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner", "", &changedSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changedHash).NotTo(Equal(hash))
+
+		changedHash, err = getDesiredStateHash("https://example.com/charts", "", &chartSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changedHash).NotTo(Equal(hash))
+
+		changedHash, err = getDesiredStateHash("https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner", "digest", &chartSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changedHash).NotTo(Equal(hash))
+	})
+
+	It("Release drift should detect out-of-band changes of the live objects", func() {
+		manifest := `---
+# Source: nfs-subdir-external-provisioner/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: drift-provisioner
+  labels:
+    app: nfs-subdir-external-provisioner
+spec:
+  replicas: 1
+  strategy:
+    type: Recreate
+  selector:
+    matchLabels:
+      app: nfs-subdir-external-provisioner
+  template:
+    metadata:
+      labels:
+        app: nfs-subdir-external-provisioner
+    spec:
+      securityContext: {}
+      containers:
+        - name: nfs-subdir-external-provisioner
+          image: registry.k8s.io/sig-storage/nfs-subdir-external-provisioner:v4.0.2
+          resources:
+            limits:
+              cpu: 0.1
+`
+		deployment := appsv1.Deployment{}
+		Expect(yaml.Unmarshal([]byte(strings.TrimPrefix(manifest, "---\n")), &deployment)).To(Succeed())
+		deployment.Namespace = DefaultNamespace
+		Expect(k8sClient.Create(ctx, &deployment)).To(Succeed())
+		currentRelease := release.Release{
+			Name:      "drift",
+			Namespace: DefaultNamespace,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Manifest:  manifest,
+		}
+		reconciler := NfsProvisionerReconciler{Client: k8sClient}
+		Expect(reconciler.getReleaseDrift(ctx, &currentRelease)).To(BeEmpty())
+
+		// The image of the live Deployment is changed out-of-band
+		deployment.Spec.Template.Spec.Containers[0].Image = "registry.k8s.io/sig-storage/nfs-subdir-external-provisioner:v4.0.1"
+		Expect(k8sClient.Update(ctx, &deployment)).To(Succeed())
+		Eventually(func() (string, error) {
+			return reconciler.getReleaseDrift(ctx, &currentRelease)
+		}).Should(ContainSubstring("spec.template.spec.containers[0].image"))
+
+		// A label removed out-of-band is a drift too, labels added by others are not
+		deployment.Spec.Template.Spec.Containers[0].Image = "registry.k8s.io/sig-storage/nfs-subdir-external-provisioner:v4.0.2"
+		deployment.Labels = map[string]string{"team": "storage"}
+		Expect(k8sClient.Update(ctx, &deployment)).To(Succeed())
+		Eventually(func() (string, error) {
+			return reconciler.getReleaseDrift(ctx, &currentRelease)
+		}).Should(ContainSubstring("metadata.labels.app"))
+		Expect(k8sClient.Delete(ctx, &deployment)).To(Succeed())
+	})
+})
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// helmResourcePolicyAnnotation keeps an object removed from the release manifest on upgrade when set to keep.
+	helmResourcePolicyAnnotation = "helm.sh/resource-policy"
+	helmKeepPolicy               = "keep"
+)
+
+var (
+	invalidStorageClassNameChars = regexp.MustCompile(`[^a-z0-9.]+`)
+	storageClassNameDots         = regexp.MustCompile(`-*\.[-.]*`)
+)
+
+// sanitizeStorageClassName converts the name to a DNS-1123 subdomain: it is lowercased, invalid characters
+// are replaced with dashes and the name is trimmed to start and end with an alphanumeric character.
+func sanitizeStorageClassName(name string) string {
+	name = invalidStorageClassNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = storageClassNameDots.ReplaceAllString(name, ".")
+	name = strings.Trim(name, "-.")
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = strings.Trim(name[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+	return name
+}
+
+// getDefaultStorageClassName returns the storage class name of the file share without an override,
+// rendered from spec.storageClassNameTemplate. It falls back to nfs-<fileShareID> when the template
+// can not be rendered to a valid name.
+func (r *NfsProvisionerReconciler) getDefaultStorageClassName(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) string {
+	defaultName := fmt.Sprintf("nfs-%s", fileShare.ID)
+	if provisioner.Spec.StorageClassNameTemplate == "" {
+		return defaultName
+	}
+	tmpl, err := crdv1.ParseStorageClassNameTemplate(provisioner.Spec.StorageClassNameTemplate)
+	if err != nil {
+		return defaultName
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, crdv1.StorageClassNameData{
+		Name:    fileShare.Name,
+		ID:      fileShare.ID,
+		Region:  provisioner.Spec.RegionID,
+		Project: provisioner.Spec.ProjectID,
+		Size:    fileShare.Size,
+	}); err != nil {
+		return defaultName
+	}
+	storageClassName := sanitizeStorageClassName(rendered.String())
+	if len(validation.IsDNS1123Subdomain(storageClassName)) > 0 {
+		return defaultName
+	}
+	return storageClassName
+}
+
+// getStorageClassName returns the storage class name of the file share,
+// the last matching file share override with a storage class name takes precedence.
+func (r *NfsProvisionerReconciler) getStorageClassName(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) string {
+	storageClassName := r.getDefaultStorageClassName(provisioner, fileShare)
+	for _, override := range provisioner.Spec.FileShareOverrides {
+		if override.StorageClassName != "" && override.Matches(fileShare.ID, fileShare.Name) {
+			storageClassName = override.StorageClassName
+		}
+	}
+	return storageClassName
+}
+
+// getStorageClassOwners returns the file share which gets each storage class name. When several file shares
+// get the same name, the file share already owning the storage class keeps it, otherwise the first one in the
+// list gets it. The other file shares are reported as conflicting by checkFileShareOwnership.
+func (r *NfsProvisionerReconciler) getStorageClassOwners(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShares []file_shares.FileShare) (map[string]*file_shares.FileShare, error) {
+	storageClassList := storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, &storageClassList, client.MatchingLabels{NfsProvisionerIDLabelName: string(provisioner.UID)}); err != nil {
+		return nil, err
+	}
+	currentOwnerIDs := map[string]string{}
+	for _, storageClass := range storageClassList.Items {
+		currentOwnerIDs[storageClass.Name] = storageClass.Labels[FileShareIDLabelName]
+	}
+	owners := map[string]*file_shares.FileShare{}
+	for i := range fileShares {
+		storageClassName := r.getStorageClassName(provisioner, &fileShares[i])
+		if currentOwnerIDs[storageClassName] == fileShares[i].ID {
+			owners[storageClassName] = &fileShares[i]
+		}
+	}
+	for i := range fileShares {
+		storageClassName := r.getStorageClassName(provisioner, &fileShares[i])
+		if owners[storageClassName] == nil {
+			owners[storageClassName] = &fileShares[i]
+		}
+	}
+	return owners, nil
+}
+
+// retainStorageClasses handles the former storage classes of a renamed file share storage class. A former
+// storage class still used by a PersistentVolume or PersistentVolumeClaim is kept, so the provisioner can
+// still delete its volumes, and the Helm upgrade is told to keep it. Unused ones are deleted.
+// It returns the names of the retained storage classes.
+func (r *NfsProvisionerReconciler) retainStorageClasses(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare, storageClassName string) ([]string, error) {
+	log := log.FromContext(ctx)
+
+	storageClassList := storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, &storageClassList, client.MatchingLabels{
+		NfsProvisionerIDLabelName: string(provisioner.UID),
+		FileShareIDLabelName:      fileShare.ID,
+	}); err != nil {
+		return nil, err
+	}
+	var retainedNames []string
+	for i := range storageClassList.Items {
+		storageClass := &storageClassList.Items[i]
+		keep := storageClass.Annotations[helmResourcePolicyAnnotation] == helmKeepPolicy
+		if storageClass.Name == storageClassName {
+			// A storage class renamed back is managed by the release again.
+			if keep {
+				patch := client.MergeFrom(storageClass.DeepCopy())
+				delete(storageClass.Annotations, helmResourcePolicyAnnotation)
+				if err := r.Client.Patch(ctx, storageClass, patch); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		inUse, err := r.isStorageClassInUse(ctx, storageClass.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !inUse {
+			log.Info("Deleting renamed storage class", "storageClass", storageClass.Name, "fileShare", fileShare.Name)
+			if err := r.Client.Delete(ctx, storageClass); client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			continue
+		}
+		retainedNames = append(retainedNames, storageClass.Name)
+		if !keep {
+			patch := client.MergeFrom(storageClass.DeepCopy())
+			if storageClass.Annotations == nil {
+				storageClass.Annotations = map[string]string{}
+			}
+			storageClass.Annotations[helmResourcePolicyAnnotation] = helmKeepPolicy
+			// The retained storage class is never the default one.
+			storageClass.Annotations[crdv1.IsDefaultStorageClassAnnotation] = "false"
+			if err := r.Client.Patch(ctx, storageClass, patch); err != nil {
+				return nil, err
+			}
+			r.Recorder.Eventf(provisioner, corev1.EventTypeNormal, StorageClassRetainedEventReason,
+				"Retained storage class %s of file share %s renamed to %s while it is in use", storageClass.Name, fileShare.Name, storageClassName)
+		}
+	}
+	sort.Strings(retainedNames)
+	return retainedNames, nil
+}
+
+// isStorageClassInUse reports whether a PersistentVolume or PersistentVolumeClaim uses the storage class.
+func (r *NfsProvisionerReconciler) isStorageClassInUse(ctx context.Context, storageClassName string) (bool, error) {
+	volumes, claims, err := r.getStorageClassUsers(ctx, StringSet{storageClassName: true})
+	if err != nil {
+		return false, err
+	}
+	return len(volumes) > 0 || len(claims) > 0, nil
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Storage class names", func() {
+	It("Storage class names should be rendered from the template and sanitized", func() {
+		Expect(sanitizeStorageClassName("SFS-DB_Main")).To(Equal("sfs-db-main"))
+		Expect(sanitizeStorageClassName("-sfs..db-.-main_")).To(Equal("sfs.db.main"))
+
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				RegionID:                 2,
+				ProjectID:                5,
+				StorageClassNameTemplate: "sfs-{{ .Name }}-{{ .Region }}-{{ .Size }}g",
+				FileShareOverrides: []crdv1.FileShareOverride{
+					{Name: "db-*", StorageClassName: "nfs-db"},
+				},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{}
+		fileShare := file_shares.FileShare{ID: "d918f840-29a2-4d54-a67e-5c9d4e34a408", Name: "Web_Data", Size: 10}
+		Expect(reconciler.getStorageClassName(&provisioner, &fileShare)).To(Equal("sfs-web-data-2-10g"))
+		dbFileShare := file_shares.FileShare{ID: "9c6a4e3c-5f0e-4d1c-9d0d-0b5f1f7a1d11", Name: "db-main"}
+		Expect(reconciler.getStorageClassName(&provisioner, &dbFileShare)).To(Equal("nfs-db"))
+
+		provisioner.Spec.StorageClassNameTemplate = "{{ .Zone }}"
+		Expect(reconciler.getStorageClassName(&provisioner, &fileShare)).To(Equal("nfs-" + fileShare.ID))
+	})
+
+	It("File shares with the same storage class name should keep the current owner", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "same-name", Namespace: DefaultNamespace, UID: "same-name-uid"},
+			Spec:       crdv1.NfsProvisionerSpec{StorageClassNameTemplate: "sfs-shared"},
+		}
+		fileShares := []file_shares.FileShare{
+			{ID: "0b8f5a7e-3c1d-4e2f-8a6b-9d0c1e2f3a4b", Name: "first"},
+			{ID: "5d2e7f1a-8b3c-4d6e-9f0a-1b2c3d4e5f6a", Name: "second"},
+		}
+		reconciler := NfsProvisionerReconciler{Client: k8sClient}
+		owners, err := reconciler.getStorageClassOwners(ctx, &provisioner, fileShares)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(owners["sfs-shared"].ID).To(Equal(fileShares[0].ID))
+
+		storageClass := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "sfs-shared",
+				Labels: map[string]string{NfsProvisionerIDLabelName: string(provisioner.UID), FileShareIDLabelName: fileShares[1].ID},
+			},
+			Provisioner: "cluster.local/second",
+		}
+		Expect(k8sClient.Create(ctx, &storageClass)).To(Succeed())
+		owners, err = reconciler.getStorageClassOwners(ctx, &provisioner, fileShares)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(owners["sfs-shared"].ID).To(Equal(fileShares[1].ID))
+		Expect(k8sClient.Delete(ctx, &storageClass)).To(Succeed())
+	})
+
+	It("Renamed storage class should be retained while a volume uses it", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "renamed", Namespace: DefaultNamespace, UID: "renamed-uid"},
+		}
+		fileShare := file_shares.FileShare{ID: "3f9a2b1c-6d4e-4f8a-b2c1-7e5d9a0b3c6f", Name: "renamed"}
+		storageClass := storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "nfs-" + fileShare.ID,
+				Labels: map[string]string{NfsProvisionerIDLabelName: string(provisioner.UID), FileShareIDLabelName: fileShare.ID},
+			},
+			Provisioner: "cluster.local/renamed",
+		}
+		Expect(k8sClient.Create(ctx, &storageClass)).To(Succeed())
+		volume := corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "renamed-volume"},
+			Spec: corev1.PersistentVolumeSpec{
+				StorageClassName: storageClass.Name,
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					NFS: &corev1.NFSVolumeSource{Server: "10.33.20.96", Path: "/shares/renamed"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &volume)).To(Succeed())
+
+		recorder := record.NewFakeRecorder(10)
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: recorder}
+		retainedNames, err := reconciler.retainStorageClasses(ctx, &provisioner, &fileShare, "sfs-renamed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(retainedNames).To(Equal([]string{storageClass.Name}))
+		Expect(recorder.Events).To(Receive(ContainSubstring(StorageClassRetainedEventReason)))
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)).To(Succeed())
+		Expect(storageClass.Annotations).To(HaveKeyWithValue(helmResourcePolicyAnnotation, helmKeepPolicy))
+
+		// The storage class is deleted once no volume uses it
+		Expect(k8sClient.Delete(ctx, &volume)).To(Succeed())
+		// No controller removes the volume protection finalizer in the test environment
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: volume.Name}, &volume); err == nil {
+			volume.Finalizers = nil
+			Expect(k8sClient.Update(ctx, &volume)).To(Succeed())
+		}
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: volume.Name}, &corev1.PersistentVolume{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+		retainedNames, err = reconciler.retainStorageClasses(ctx, &provisioner, &fileShare, "sfs-renamed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(retainedNames).To(BeEmpty())
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storagev1.StorageClass{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
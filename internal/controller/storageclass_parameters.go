@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// StorageClassLabelsAnnotationName lists the keys of the storage class labels applied to the storage class
+// of a Helm release.
+const StorageClassLabelsAnnotationName = "gcore-sfs-controller.io/storage-class-labels"
+
+const (
+	defaultReclaimPolicy     = corev1.PersistentVolumeReclaimDelete
+	defaultVolumeBindingMode = storagev1.VolumeBindingImmediate
+)
+
+// getStorageClassParameters returns the storage class parameters of the file share: the spec parameters
+// overridden field by field by the matching file share overrides in the listed order.
+func (r *NfsProvisionerReconciler) getStorageClassParameters(provisioner *crdv1.NfsProvisioner, fileShare *file_shares.FileShare) crdv1.StorageClassParameters {
+	parameters := *provisioner.Spec.StorageClassParameters.DeepCopy()
+	for _, override := range provisioner.Spec.FileShareOverrides {
+		if override.Matches(fileShare.ID, fileShare.Name) {
+			mergeStorageClassParameters(&parameters, &override.StorageClassParameters)
+		}
+	}
+	return parameters
+}
+
+// mergeStorageClassParameters sets the parameters of src over dst, labels and annotations are merged by key.
+func mergeStorageClassParameters(dst *crdv1.StorageClassParameters, src *crdv1.StorageClassParameters) {
+	if src.ReclaimPolicy != nil {
+		reclaimPolicy := *src.ReclaimPolicy
+		dst.ReclaimPolicy = &reclaimPolicy
+	}
+	if src.ArchiveOnDelete != nil {
+		archiveOnDelete := *src.ArchiveOnDelete
+		dst.ArchiveOnDelete = &archiveOnDelete
+	}
+	if src.OnDelete != "" {
+		dst.OnDelete = src.OnDelete
+	}
+	if src.PathPattern != "" {
+		dst.PathPattern = src.PathPattern
+	}
+	if src.VolumeBindingMode != nil {
+		volumeBindingMode := *src.VolumeBindingMode
+		dst.VolumeBindingMode = &volumeBindingMode
+	}
+	dst.StorageClassLabels = mergeStringMaps(dst.StorageClassLabels, src.StorageClassLabels)
+	dst.StorageClassAnnotations = mergeStringMaps(dst.StorageClassAnnotations, src.StorageClassAnnotations)
+}
+
+// mergeStringMaps returns a copy of dst with the keys of src set over it, it is nil when both are empty.
+func mergeStringMaps(dst map[string]string, src map[string]string) map[string]string {
+	if len(dst) == 0 && len(src) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(dst)+len(src))
+	for key, value := range dst {
+		merged[key] = value
+	}
+	for key, value := range src {
+		merged[key] = value
+	}
+	return merged
+}
+
+// getStorageClassParameterValues returns the Helm values of the set storage class parameters.
+func getStorageClassParameterValues(parameters *crdv1.StorageClassParameters) map[string]interface{} {
+	storageClassValues := map[string]interface{}{}
+	if parameters.ReclaimPolicy != nil {
+		storageClassValues["reclaimPolicy"] = string(*parameters.ReclaimPolicy)
+	}
+	if parameters.ArchiveOnDelete != nil {
+		storageClassValues["archiveOnDelete"] = *parameters.ArchiveOnDelete
+	}
+	if parameters.OnDelete != "" {
+		storageClassValues["onDelete"] = parameters.OnDelete
+	}
+	if parameters.PathPattern != "" {
+		storageClassValues["pathPattern"] = parameters.PathPattern
+	}
+	if parameters.VolumeBindingMode != nil {
+		storageClassValues["volumeBindingMode"] = string(*parameters.VolumeBindingMode)
+	}
+	if len(parameters.StorageClassAnnotations) > 0 {
+		annotations := map[string]interface{}{}
+		for key, value := range parameters.StorageClassAnnotations {
+			annotations[key] = value
+		}
+		storageClassValues["annotations"] = annotations
+	}
+	// The labels value of the chart applies to all provisioner objects, the storage class labels are applied
+	// by applyStorageClassLabels instead.
+	return map[string]interface{}{"storageClass": storageClassValues}
+}
+
+// applyStorageClassLabels sets the storage class labels on the storage class of a Helm release. The keys are
+// recorded in the StorageClassLabelsAnnotationName annotation, so labels removed from the spec are removed from
+// the storage class. The labels managed by the controller are not overridden.
+func (r *NfsProvisionerReconciler) applyStorageClassLabels(ctx context.Context, storageClassName string, labels map[string]string) error {
+	storageClass := storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: storageClassName}, &storageClass); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	managedLabelNames := StringSet{NfsProvisionerIDLabelName: true, FileShareIDLabelName: true, FileShareNameLabelName: true}
+	keys := []string{}
+	for key := range labels {
+		if !managedLabelNames[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	original := storageClass.DeepCopy()
+	if appliedKeys := storageClass.Annotations[StorageClassLabelsAnnotationName]; appliedKeys != "" {
+		for _, key := range strings.Split(appliedKeys, ",") {
+			if _, found := labels[key]; !found && !managedLabelNames[key] {
+				delete(storageClass.Labels, key)
+			}
+		}
+	}
+	if len(keys) > 0 && storageClass.Labels == nil {
+		storageClass.Labels = map[string]string{}
+	}
+	for _, key := range keys {
+		storageClass.Labels[key] = labels[key]
+	}
+	if len(keys) > 0 {
+		if storageClass.Annotations == nil {
+			storageClass.Annotations = map[string]string{}
+		}
+		storageClass.Annotations[StorageClassLabelsAnnotationName] = strings.Join(keys, ",")
+	} else {
+		delete(storageClass.Annotations, StorageClassLabelsAnnotationName)
+	}
+	if reflect.DeepEqual(original.Labels, storageClass.Labels) && reflect.DeepEqual(original.Annotations, storageClass.Annotations) {
+		return nil
+	}
+	return r.Client.Patch(ctx, &storageClass, client.MergeFrom(original))
+}
+
+// getStorageClassParametersFromValues returns the storage class parameters rendered by the chart from the values.
+func getStorageClassParametersFromValues(values map[string]interface{}) map[string]string {
+	storageClassValues, _ := values["storageClass"].(map[string]interface{})
+	parameters := map[string]string{
+		"archiveOnDelete": fmt.Sprint(storageClassValues["archiveOnDelete"]),
+	}
+	for _, key := range []string{"pathPattern", "onDelete"} {
+		if value, ok := storageClassValues[key]; ok && value != nil && value != "" {
+			parameters[key] = fmt.Sprint(value)
+		}
+	}
+	return parameters
+}
+
+// deleteChangedStorageClass deletes the storage class of the file share when the values change its immutable
+// fields, the parameters, mount options, reclaim policy or volume binding mode, so the Helm upgrade creates it
+// again. Bound volumes keep working, they reference the storage class by name.
+func (r *NfsProvisionerReconciler) deleteChangedStorageClass(ctx context.Context, storageClassName string, values map[string]interface{}) error {
+	storageClass := storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: storageClassName}, &storageClass); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	storageClassValues, _ := values["storageClass"].(map[string]interface{})
+	mountOptions := getValuesMountOptions(values)
+	liveMountOptions := append([]string{}, storageClass.MountOptions...)
+	changed := !reflect.DeepEqual(storageClass.Parameters, getStorageClassParametersFromValues(values)) ||
+		!reflect.DeepEqual(liveMountOptions, mountOptions)
+	if storageClass.ReclaimPolicy != nil && string(*storageClass.ReclaimPolicy) != fmt.Sprint(storageClassValues["reclaimPolicy"]) {
+		changed = true
+	}
+	if storageClass.VolumeBindingMode != nil && string(*storageClass.VolumeBindingMode) != fmt.Sprint(storageClassValues["volumeBindingMode"]) {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	log.FromContext(ctx).Info("Deleting storage class with changed immutable fields", "storageClass", storageClassName)
+	if err := r.Client.Delete(ctx, &storageClass); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// getNativeStorageClassParameters returns the parameters of the storage class in Native deployment mode.
+func getNativeStorageClassParameters(parameters *crdv1.StorageClassParameters) map[string]string {
+	archiveOnDelete := defaultArchiveOnDelete
+	if parameters.ArchiveOnDelete != nil {
+		archiveOnDelete = *parameters.ArchiveOnDelete
+	}
+	storageClassParameters := map[string]string{
+		"archiveOnDelete": strconv.FormatBool(archiveOnDelete),
+	}
+	if parameters.PathPattern != "" {
+		storageClassParameters["pathPattern"] = parameters.PathPattern
+	}
+	if parameters.OnDelete != "" {
+		storageClassParameters["onDelete"] = parameters.OnDelete
+	}
+	return storageClassParameters
+}
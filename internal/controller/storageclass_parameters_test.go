@@ -0,0 +1,165 @@
+package controller
+
+import (
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	"github.com/G-Core/gcore-sfs-controller/pkg/gcoreclient"
+	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	gohelmclient "github.com/mittwald/go-helm-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Storage class parameters", func() {
+	It("File share overrides should override the spec parameters field by field", func() {
+		retain := corev1.PersistentVolumeReclaimRetain
+		waitForFirstConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+		archiveOnDelete := false
+		provisioner := crdv1.NfsProvisioner{
+			Spec: crdv1.NfsProvisionerSpec{
+				StorageClassParameters: crdv1.StorageClassParameters{
+					ArchiveOnDelete:    &archiveOnDelete,
+					PathPattern:        "${.PVC.namespace}/${.PVC.name}",
+					StorageClassLabels: map[string]string{"team": "storage", "tier": "standard"},
+				},
+				FileShareOverrides: []crdv1.FileShareOverride{
+					{Name: "db-*", StorageClassParameters: crdv1.StorageClassParameters{
+						ReclaimPolicy:      &retain,
+						VolumeBindingMode:  &waitForFirstConsumer,
+						StorageClassLabels: map[string]string{"tier": "database"},
+					}},
+				},
+			},
+		}
+		reconciler := NfsProvisionerReconciler{}
+		fileShare := file_shares.FileShare{ID: "d918f840-29a2-4d54-a67e-5c9d4e34a408", Name: "db-main"}
+		parameters := reconciler.getStorageClassParameters(&provisioner, &fileShare)
+		Expect(*parameters.ReclaimPolicy).To(Equal(retain))
+		Expect(*parameters.ArchiveOnDelete).To(BeFalse())
+		Expect(parameters.StorageClassLabels).To(Equal(map[string]string{"team": "storage", "tier": "database"}))
+		Expect(provisioner.Spec.StorageClassLabels).To(HaveKeyWithValue("tier", "standard"))
+
+		values := getStorageClassParameterValues(&parameters)
+		Expect(values["storageClass"]).To(Equal(map[string]interface{}{
+			"reclaimPolicy":     "Retain",
+			"archiveOnDelete":   false,
+			"pathPattern":       "${.PVC.namespace}/${.PVC.name}",
+			"volumeBindingMode": "WaitForFirstConsumer",
+		}))
+		Expect(values).NotTo(HaveKey("labels"))
+
+		otherFileShare := file_shares.FileShare{ID: "9c6a4e3c-5f0e-4d1c-9d0d-0b5f1f7a1d11", Name: "web"}
+		parameters = reconciler.getStorageClassParameters(&provisioner, &otherFileShare)
+		Expect(parameters.ReclaimPolicy).To(BeNil())
+		Expect(getNativeStorageClassParameters(&parameters)).To(Equal(map[string]string{
+			"archiveOnDelete": "false",
+			"pathPattern":     "${.PVC.namespace}/${.PVC.name}",
+		}))
+	})
+
+	It("Storage class with changed immutable fields should be deleted before the upgrade", func() {
+		reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+		volumeBindingMode := storagev1.VolumeBindingImmediate
+		storageClass := storagev1.StorageClass{
+			ObjectMeta:        metav1.ObjectMeta{Name: "nfs-changed-parameters"},
+			Provisioner:       "cluster.local/changed",
+			ReclaimPolicy:     &reclaimPolicy,
+			VolumeBindingMode: &volumeBindingMode,
+			MountOptions:      []string{"soft"},
+			Parameters:        map[string]string{"archiveOnDelete": "true"},
+		}
+		Expect(k8sClient.Create(ctx, &storageClass)).To(Succeed())
+		values := map[string]interface{}{
+			"nfs": map[string]interface{}{"mountOptions": []interface{}{"soft"}},
+			"storageClass": map[string]interface{}{
+				"reclaimPolicy":     "Delete",
+				"archiveOnDelete":   true,
+				"volumeBindingMode": "Immediate",
+			},
+		}
+		reconciler := NfsProvisionerReconciler{Client: k8sClient}
+		Expect(reconciler.deleteChangedStorageClass(ctx, storageClass.Name, values)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)).To(Succeed())
+
+		values["storageClass"].(map[string]interface{})["reclaimPolicy"] = "Retain"
+		Expect(reconciler.deleteChangedStorageClass(ctx, storageClass.Name, values)).To(Succeed())
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Storage class labels should only be set on the storage class of the release", func() {
+		fileShare := file_shares.FileShare{
+			Name:            "labeled_file_share",
+			ID:              "2c7e9a1f-3b5d-4e8c-9a2f-6d1b4c8e7a35",
+			Protocol:        "nfs",
+			Status:          "available",
+			Size:            2,
+			ConnectionPoint: "10.33.20.94:/shares/share-2c7e9a1f-3b5d-4e8c-9a2f-6d1b4c8e7a35",
+		}
+		provisionerName := types.NamespacedName{Namespace: DefaultNamespace, Name: "test-provisioner-labels"}
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: provisionerName.Name, Namespace: provisionerName.Namespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:       "faketoken",
+				APIURL:         "http://127.0.0.1",
+				RegionID:       2,
+				ProjectID:      5,
+				HelmRepository: "https://kubernetes-sigs.github.io/nfs-subdir-external-provisioner",
+				ChartName:      "nfs-subdir-external-provisioner",
+				ImageVersion:   "v4.0.2",
+				StorageClassParameters: crdv1.StorageClassParameters{
+					StorageClassLabels: map[string]string{"team": "storage"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		helmClient, err := gohelmclient.NewClientFromRestConf(
+			&gohelmclient.RestConfClientOptions{
+				Options:    &gohelmclient.Options{},
+				RestConfig: cfg,
+			})
+		Expect(err).NotTo(HaveOccurred())
+		reconciler := NfsProvisionerReconciler{
+			Client:          k8sClient,
+			Recorder:        record.NewFakeRecorder(100),
+			HelmClient:      helmClient,
+			FileShareClient: &gcoreclient.MockFileShareManager{FileShares: []file_shares.FileShare{fileShare}},
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+
+		storageClass := storagev1.StorageClass{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "nfs-" + fileShare.ID}, &storageClass)).To(Succeed())
+		Expect(storageClass.Labels).To(HaveKeyWithValue("team", "storage"))
+		Expect(storageClass.Labels).To(HaveKeyWithValue(FileShareIDLabelName, fileShare.ID))
+		deploymentList := appsv1.DeploymentList{}
+		Expect(k8sClient.List(ctx, &deploymentList, client.InNamespace(DefaultNamespace),
+			client.MatchingLabels{FileShareIDLabelName: fileShare.ID})).To(Succeed())
+		Expect(deploymentList.Items).To(HaveLen(1))
+		Expect(deploymentList.Items[0].Labels).NotTo(HaveKey("team"))
+		Expect(deploymentList.Items[0].Spec.Template.Labels).NotTo(HaveKey("team"))
+
+		// The labels removed from the spec are removed from the storage class
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		provisioner.Spec.StorageClassLabels = nil
+		Expect(k8sClient.Update(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: storageClass.Name}, &storageClass)).To(Succeed())
+		Expect(storageClass.Labels).NotTo(HaveKey("team"))
+		Expect(storageClass.Annotations).NotTo(HaveKey(StorageClassLabelsAnnotationName))
+
+		Expect(k8sClient.Get(ctx, provisionerName, &provisioner)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: provisionerName})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
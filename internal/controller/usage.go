@@ -0,0 +1,348 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DefaultUsageProbeImage is the default spec.usageMonitoring.image.
+	DefaultUsageProbeImage = "busybox:1.36"
+	// defaultUsageProbeInterval is the default spec.usageMonitoring.interval.
+	defaultUsageProbeInterval = 10 * time.Minute
+	// defaultUsageThresholdPercent is the default spec.usageMonitoring.thresholdPercent.
+	defaultUsageThresholdPercent = 90
+	// usageProbePollInterval is the delay before checking again a running usage probe, Jobs are not watched.
+	usageProbePollInterval = 15 * time.Second
+	// usageProbeDeadlineSeconds is the time a usage probe may run before it fails, e.g. on a hanging mount.
+	usageProbeDeadlineSeconds = 120
+
+	usageProbeAppName    = "gcore-sfs-usage-probe"
+	usageProbeVolumeName = "file-share"
+	usageProbeMountPath  = "/file-share"
+	// usageProbeCommand writes the block size, total blocks, free blocks and available blocks of the
+	// mounted file share to the termination message of the probe container.
+	usageProbeCommand = "stat -f -c '%S %b %f %a' " + usageProbeMountPath + " > /dev/termination-log"
+)
+
+// Event reasons of the file share usage emitted on NfsProvisioner resources.
+const (
+	UsageProbeFailedEventReason       = "UsageProbeFailed"
+	UsageThresholdExceededEventReason = "UsageThresholdExceeded"
+)
+
+// getUsageProbeJobName returns the name of the usage probe Job of the file share.
+func (r *NfsProvisionerReconciler) getUsageProbeJobName(provisioner *crdv1.NfsProvisioner, fileShareID string) string {
+	return fmt.Sprintf("%s-usage", r.getReleaseName(provisioner, fileShareID))
+}
+
+// buildUsageProbeJob returns the Job mounting the file share read-only and reporting its statfs.
+func (r *NfsProvisionerReconciler) buildUsageProbeJob(provisioner *crdv1.NfsProvisioner, fileShareStatus *crdv1.FileShareStatus) (*batchv1.Job, error) {
+	nfsServer, nfsPath, err := splitConnectionPoint(fileShareStatus.ConnectionPoint)
+	if err != nil {
+		return nil, err
+	}
+	image := provisioner.Spec.UsageMonitoring.Image
+	if image == "" {
+		image = DefaultUsageProbeImage
+	}
+	name := r.getUsageProbeJobName(provisioner, fileShareStatus.ID)
+	labels := map[string]string{
+		managedByLabelName:        NativeFieldManager,
+		appNameLabelName:          usageProbeAppName,
+		appInstanceLabelName:      name,
+		NfsProvisionerIDLabelName: string(provisioner.UID),
+		FileShareIDLabelName:      fileShareStatus.ID,
+	}
+	backoffLimit := int32(0)
+	activeDeadlineSeconds := int64(usageProbeDeadlineSeconds)
+	allowPrivilegeEscalation := false
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: provisioner.Namespace, Labels: labels},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &activeDeadlineSeconds,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "probe",
+						Image:   image,
+						Command: []string{"sh", "-c", usageProbeCommand},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      usageProbeVolumeName,
+							MountPath: usageProbeMountPath,
+							ReadOnly:  true,
+						}},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("16Mi"),
+							},
+							Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("32Mi")},
+						},
+						SecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &allowPrivilegeEscalation},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: usageProbeVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							NFS: &corev1.NFSVolumeSource{Server: nfsServer, Path: nfsPath, ReadOnly: true},
+						},
+					}},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(provisioner, job, r.Client.Scheme()); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// parseUsageProbeResult returns the used and available bytes and the total bytes of the file system
+// from the termination message of a usage probe.
+func parseUsageProbeResult(message string) (int64, int64, int64, error) {
+	fields := strings.Fields(message)
+	if len(fields) != 4 {
+		return 0, 0, 0, fmt.Errorf("unexpected usage probe result %q", message)
+	}
+	values := make([]int64, len(fields))
+	for i, f := range fields {
+		value, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || value < 0 {
+			return 0, 0, 0, fmt.Errorf("unexpected usage probe result %q", message)
+		}
+		values[i] = value
+	}
+	blockSize, blocks, freeBlocks, availableBlocks := values[0], values[1], values[2], values[3]
+	return (blocks - freeBlocks) * blockSize, availableBlocks * blockSize, blocks * blockSize, nil
+}
+
+// getUsagePercent returns the used bytes in percent of the capacity rounded up.
+func getUsagePercent(used int64, capacity int64) int32 {
+	if capacity <= 0 {
+		return 0
+	}
+	return int32((used*100 + capacity - 1) / capacity)
+}
+
+// getJobFinishTime returns the time the Job completed or failed and whether it succeeded,
+// or a nil time while it is running.
+func getJobFinishTime(job *batchv1.Job) (*metav1.Time, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return &condition.LastTransitionTime, true
+		case batchv1.JobFailed:
+			return &condition.LastTransitionTime, false
+		}
+	}
+	return nil, false
+}
+
+// reconcileUsage reports the capacity of the file shares and measures their usage with a probe Job per file share
+// when spec.usageMonitoring is set. It returns the delay before the probes are checked again. A probe which cannot
+// be run is reported in the usageMessage of its file share.
+func (r *NfsProvisionerReconciler) reconcileUsage(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareStatuses []crdv1.FileShareStatus, previousFileShareStatuses map[string]crdv1.FileShareStatus) (time.Duration, error) {
+	for i := range fileShareStatuses {
+		if fileShareStatuses[i].Size > 0 {
+			fileShareStatuses[i].Capacity = resource.NewQuantity(int64(fileShareStatuses[i].Size)<<30, resource.BinarySI)
+		}
+	}
+	usageMonitoring := provisioner.Spec.UsageMonitoring
+	if usageMonitoring == nil {
+		meta.RemoveStatusCondition(&provisioner.Status.Conditions, crdv1.UsageThresholdExceededCondition)
+		return 0, r.deleteUsageProbeJobs(ctx, provisioner, StringSet{})
+	}
+	interval := defaultUsageProbeInterval
+	if usageMonitoring.Interval != nil {
+		interval = usageMonitoring.Interval.Duration
+	}
+	thresholdPercent := usageMonitoring.ThresholdPercent
+	if thresholdPercent == 0 {
+		thresholdPercent = defaultUsageThresholdPercent
+	}
+	requeueAfter := interval
+	probedFileShareIDs := StringSet{}
+	var exceeded []string
+	for i := range fileShareStatuses {
+		fileShareStatus := &fileShareStatuses[i]
+		previousStatus := previousFileShareStatuses[fileShareStatus.ID]
+		fileShareStatus.Usage = previousStatus.Usage.DeepCopy()
+		fileShareStatus.UsageMessage = previousStatus.UsageMessage
+		if fileShareStatus.ConnectionPoint == "" || fileShareStatus.ClaimedBy != "" || fileShareStatus.CloudStatus != fileShareAvailableStatus {
+			continue
+		}
+		probedFileShareIDs[fileShareStatus.ID] = true
+		probeAfter, err := r.probeFileShareUsage(ctx, provisioner, fileShareStatus, interval)
+		if err != nil {
+			// The failure is reported in the file share status, the other file shares are still probed.
+			log.FromContext(ctx).Error(err, "failed probe usage of file share", "fileShare", fileShareStatus.Name)
+			r.recordUsageProbeFailure(provisioner, fileShareStatus, err.Error())
+			probeAfter = usageProbePollInterval
+		}
+		if probeAfter < requeueAfter {
+			requeueAfter = probeAfter
+		}
+		if fileShareStatus.Usage == nil || fileShareStatus.Usage.UsedPercent <= thresholdPercent {
+			continue
+		}
+		exceeded = append(exceeded, fmt.Sprintf("%s (%d%%)", fileShareStatus.Name, fileShareStatus.Usage.UsedPercent))
+		if previousStatus.Usage == nil || previousStatus.Usage.UsedPercent <= thresholdPercent {
+			r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, UsageThresholdExceededEventReason,
+				"Usage of file share %s is %d%%, above the threshold of %d%%", fileShareStatus.Name, fileShareStatus.Usage.UsedPercent, thresholdPercent)
+		}
+	}
+	if err := r.deleteUsageProbeJobs(ctx, provisioner, probedFileShareIDs); err != nil {
+		return 0, err
+	}
+	if len(exceeded) > 0 {
+		r.setCondition(provisioner, crdv1.UsageThresholdExceededCondition, metav1.ConditionTrue, crdv1.UsageAboveThresholdReason,
+			fmt.Sprintf("Usage of file shares is above %d%%: %s", thresholdPercent, strings.Join(exceeded, ", ")))
+	} else {
+		r.setCondition(provisioner, crdv1.UsageThresholdExceededCondition, metav1.ConditionFalse, crdv1.UsageBelowThresholdReason, "")
+	}
+	return requeueAfter, nil
+}
+
+// probeFileShareUsage starts the usage probe of the file share, records the result of the finished probe in the
+// file share status and replaces it once the interval has passed. It returns the delay before the probe is
+// checked again.
+func (r *NfsProvisionerReconciler) probeFileShareUsage(ctx context.Context, provisioner *crdv1.NfsProvisioner, fileShareStatus *crdv1.FileShareStatus, interval time.Duration) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	job := batchv1.Job{}
+	jobName := types.NamespacedName{Namespace: provisioner.Namespace, Name: r.getUsageProbeJobName(provisioner, fileShareStatus.ID)}
+	err := r.Client.Get(ctx, jobName, &job)
+	if apierrors.IsNotFound(err) {
+		newJob, err := r.buildUsageProbeJob(provisioner, fileShareStatus)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.Client.Create(ctx, newJob); err != nil && !apierrors.IsAlreadyExists(err) {
+			return 0, err
+		}
+		log.V(1).Info("Started usage probe", "fileShare", fileShareStatus.Name, "job", jobName.Name)
+		return usageProbePollInterval, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	finishTime, succeeded := getJobFinishTime(&job)
+	if finishTime == nil {
+		return usageProbePollInterval, nil
+	}
+	if succeeded {
+		if fileShareStatus.Usage == nil || !fileShareStatus.Usage.ProbeTime.Equal(finishTime) {
+			if err := r.recordUsageProbeResult(ctx, &job, fileShareStatus, *finishTime); err != nil {
+				r.recordUsageProbeFailure(provisioner, fileShareStatus, err.Error())
+			}
+		}
+	} else {
+		message := fmt.Sprintf("Usage probe Job %s failed", job.Name)
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed && condition.Message != "" {
+				message = fmt.Sprintf("Usage probe Job %s failed: %s", job.Name, condition.Message)
+			}
+		}
+		r.recordUsageProbeFailure(provisioner, fileShareStatus, message)
+	}
+	if probeAfter := time.Until(finishTime.Add(interval)); probeAfter > 0 {
+		return probeAfter, nil
+	}
+	// The finished probe is replaced by a new one on the next reconcile.
+	if err := r.Client.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+		return 0, err
+	}
+	return usageProbePollInterval, nil
+}
+
+// recordUsageProbeResult records the usage reported by the pod of the succeeded probe Job in the file share status.
+func (r *NfsProvisionerReconciler) recordUsageProbeResult(ctx context.Context, job *batchv1.Job, fileShareStatus *crdv1.FileShareStatus, probeTime metav1.Time) error {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	podList := corev1.PodList{}
+	if err := r.Client.List(ctx, &podList, client.InNamespace(job.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.State.Terminated == nil {
+				continue
+			}
+			used, available, total, err := parseUsageProbeResult(containerStatus.State.Terminated.Message)
+			if err != nil {
+				return err
+			}
+			capacity := total
+			if fileShareStatus.Capacity != nil {
+				capacity = fileShareStatus.Capacity.Value()
+			}
+			fileShareStatus.Usage = &crdv1.FileShareUsage{
+				Used:        *resource.NewQuantity(used, resource.BinarySI),
+				Available:   *resource.NewQuantity(available, resource.BinarySI),
+				UsedPercent: getUsagePercent(used, capacity),
+				ProbeTime:   probeTime,
+			}
+			fileShareStatus.UsageMessage = ""
+			return nil
+		}
+	}
+	return fmt.Errorf("no succeeded pod found for usage probe Job %s", job.Name)
+}
+
+// recordUsageProbeFailure reports the failed probe in the file share status, the last measured usage is kept.
+func (r *NfsProvisionerReconciler) recordUsageProbeFailure(provisioner *crdv1.NfsProvisioner, fileShareStatus *crdv1.FileShareStatus, message string) {
+	if fileShareStatus.UsageMessage == message {
+		return
+	}
+	fileShareStatus.UsageMessage = message
+	r.Recorder.Eventf(provisioner, corev1.EventTypeWarning, UsageProbeFailedEventReason,
+		"Failed to probe usage of file share %s: %s", fileShareStatus.Name, message)
+}
+
+// deleteUsageProbeJobs deletes the usage probe Jobs of the provisioner except those of the probed file shares.
+func (r *NfsProvisionerReconciler) deleteUsageProbeJobs(ctx context.Context, provisioner *crdv1.NfsProvisioner, probedFileShareIDs StringSet) error {
+	jobList := batchv1.JobList{}
+	err := r.Client.List(ctx, &jobList, client.InNamespace(provisioner.Namespace), client.MatchingLabels{
+		appNameLabelName:          usageProbeAppName,
+		NfsProvisionerIDLabelName: string(provisioner.UID),
+	})
+	if err != nil {
+		return err
+	}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if probedFileShareIDs[job.Labels[FileShareIDLabelName]] {
+			continue
+		}
+		if err := r.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"time"
+
+	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Usage monitoring", func() {
+	It("Usage probe result should be parsed from the statfs of the file share", func() {
+		used, available, total, err := parseUsageProbeResult("4096 2621440 262144 196608\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(used).To(Equal(int64(9 << 30)))
+		Expect(available).To(Equal(int64(768 << 20)))
+		Expect(total).To(Equal(int64(10 << 30)))
+		Expect(getUsagePercent(used, 10<<30)).To(Equal(int32(90)))
+		Expect(getUsagePercent(used+1, 10<<30)).To(Equal(int32(91)))
+		_, _, _, err = parseUsageProbeResult("stat: can't read file system information")
+		Expect(err).To(HaveOccurred())
+	})
+	It("File share usage should be measured by a probe Job", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "usage", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:        "faketoken",
+				RegionID:        2,
+				ProjectID:       5,
+				UsageMonitoring: &crdv1.UsageMonitoring{ThresholdPercent: 80},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		recorder := record.NewFakeRecorder(10)
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: recorder}
+		fileShareID := "6e7f8a9b-0c1d-4e2f-8a3b-4c5d6e7f8a9b"
+		fileShareStatuses := func() []crdv1.FileShareStatus {
+			return []crdv1.FileShareStatus{{
+				ID:              fileShareID,
+				Name:            "usage",
+				ConnectionPoint: "10.33.20.96:/shares/share-6e7f8a9b-0c1d-4e2f-8a3b-4c5d6e7f8a9b",
+				Size:            10,
+				CloudStatus:     fileShareAvailableStatus,
+			}}
+		}
+
+		// The probe Job mounting the file share is started
+		statuses := fileShareStatuses()
+		requeueAfter, err := reconciler.reconcileUsage(ctx, &provisioner, statuses, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(usageProbePollInterval))
+		Expect(statuses[0].Capacity.String()).To(Equal("10Gi"))
+		Expect(statuses[0].Usage).To(BeNil())
+		jobName := types.NamespacedName{Namespace: DefaultNamespace, Name: reconciler.getUsageProbeJobName(&provisioner, fileShareID)}
+		job := batchv1.Job{}
+		Expect(k8sClient.Get(ctx, jobName, &job)).To(Succeed())
+		Expect(job.OwnerReferences).To(HaveLen(1))
+		Expect(job.Spec.Template.Spec.Volumes[0].NFS.Server).To(Equal("10.33.20.96"))
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal(DefaultUsageProbeImage))
+
+		// The usage is read from the termination message of the succeeded probe
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: jobName.Name, Namespace: DefaultNamespace, Labels: job.Spec.Selector.MatchLabels},
+			Spec:       *job.Spec.Template.Spec.DeepCopy(),
+		}
+		Expect(k8sClient.Create(ctx, &pod)).To(Succeed())
+		pod.Status.Phase = corev1.PodSucceeded
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "probe",
+			Image: DefaultUsageProbeImage,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "4096 2621440 262144 196608\n"}},
+		}}
+		Expect(k8sClient.Status().Update(ctx, &pod)).To(Succeed())
+		now := metav1.Now()
+		job.Status.StartTime = &now
+		job.Status.CompletionTime = &now
+		job.Status.Succeeded = 1
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now}}
+		Expect(k8sClient.Status().Update(ctx, &job)).To(Succeed())
+		statuses = fileShareStatuses()
+		requeueAfter, err = reconciler.reconcileUsage(ctx, &provisioner, statuses, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeNumerically("~", defaultUsageProbeInterval, time.Minute))
+		Expect(statuses[0].Usage).NotTo(BeNil())
+		Expect(statuses[0].Usage.Used.String()).To(Equal("9Gi"))
+		Expect(statuses[0].Usage.UsedPercent).To(Equal(int32(90)))
+		Expect(recorder.Events).To(Receive(ContainSubstring(UsageThresholdExceededEventReason)))
+		Expect(meta.IsStatusConditionTrue(provisioner.Status.Conditions, crdv1.UsageThresholdExceededCondition)).To(BeTrue())
+
+		// The probe Jobs are deleted without spec.usageMonitoring
+		provisioner.Spec.UsageMonitoring = nil
+		_, err = reconciler.reconcileUsage(ctx, &provisioner, fileShareStatuses(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(meta.FindStatusCondition(provisioner.Status.Conditions, crdv1.UsageThresholdExceededCondition)).To(BeNil())
+		err = k8sClient.Get(ctx, jobName, &job)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Delete(ctx, &pod)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+	})
+
+	It("Usage probe failure of a file share should not stop the others", func() {
+		provisioner := crdv1.NfsProvisioner{
+			ObjectMeta: metav1.ObjectMeta{Name: "usage-failure", Namespace: DefaultNamespace},
+			Spec: crdv1.NfsProvisionerSpec{
+				APIToken:        "faketoken",
+				RegionID:        2,
+				ProjectID:       5,
+				UsageMonitoring: &crdv1.UsageMonitoring{},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &provisioner)).To(Succeed())
+		recorder := record.NewFakeRecorder(10)
+		reconciler := NfsProvisionerReconciler{Client: k8sClient, Recorder: recorder}
+		statuses := []crdv1.FileShareStatus{
+			{
+				ID:              "0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d",
+				Name:            "usage-invalid",
+				ConnectionPoint: "invalid",
+				Size:            10,
+				CloudStatus:     fileShareAvailableStatus,
+			},
+			{
+				ID:              "1b2c3d4e-5f6a-4b7c-8d9e-9f0a1b2c3d4e",
+				Name:            "usage-valid",
+				ConnectionPoint: "10.33.20.97:/shares/share-1b2c3d4e-5f6a-4b7c-8d9e-9f0a1b2c3d4e",
+				Size:            10,
+				CloudStatus:     fileShareAvailableStatus,
+			},
+		}
+		requeueAfter, err := reconciler.reconcileUsage(ctx, &provisioner, statuses, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(usageProbePollInterval))
+		Expect(statuses[0].UsageMessage).NotTo(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring(UsageProbeFailedEventReason)))
+		Expect(statuses[1].UsageMessage).To(BeEmpty())
+		job := batchv1.Job{}
+		jobName := types.NamespacedName{Namespace: DefaultNamespace, Name: reconciler.getUsageProbeJobName(&provisioner, statuses[1].ID)}
+		Expect(k8sClient.Get(ctx, jobName, &job)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &job)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &provisioner)).To(Succeed())
+	})
+})
@@ -1,26 +1,93 @@
 package gcoreclient
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
 
 	crdv1 "github.com/G-Core/gcore-sfs-controller/api/v1"
 	gcorecloud "github.com/G-Core/gcorelabscloud-go"
 	cloudclient "github.com/G-Core/gcorelabscloud-go/gcore"
 	"github.com/G-Core/gcorelabscloud-go/gcore/file_share/v1/file_shares"
+	"github.com/G-Core/gcorelabscloud-go/gcore/task/v1/tasks"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const NfsProtocolName = "nfs"
 
 type FileShareLister interface {
-	ListFileShares(provisioner *crdv1.NfsProvisioner) ([]file_shares.FileShare, error)
+	ListFileShares(ctx context.Context, provisioner *crdv1.NfsProvisioner) ([]file_shares.FileShare, error)
 }
 
-type FileShareClient struct{}
+// FileShareManager creates, extends and deletes file shares and manages their access rules. The methods
+// changing a file share return the ID of the Gcore Cloud task doing the change, it is polled with GetTask.
+type FileShareManager interface {
+	FileShareLister
+	GetFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) (*file_shares.FileShare, error)
+	CreateFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, opts CreateFileShareOpts) (string, error)
+	ExtendFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, size int) (string, error)
+	DeleteFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) (string, error)
+	GetTask(ctx context.Context, provisioner *crdv1.NfsProvisioner, taskID string) (*tasks.Task, error)
+	ListAccessRules(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) ([]file_shares.AccessRule, error)
+	CreateAccessRule(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, opts file_shares.CreateAccessRuleOpts) (*file_shares.AccessRule, error)
+	DeleteAccessRule(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, ruleID string) error
+}
+
+// CreateFileShareOpts adds the volume type, which file_shares.CreateOpts does not have, to the create request.
+type CreateFileShareOpts struct {
+	file_shares.CreateOpts
+	VolumeType string
+}
+
+// ToFileShareCreateMap builds a request body from CreateFileShareOpts.
+func (opts CreateFileShareOpts) ToFileShareCreateMap() (map[string]interface{}, error) {
+	body, err := opts.CreateOpts.ToFileShareCreateMap()
+	if err != nil {
+		return nil, err
+	}
+	if opts.VolumeType != "" {
+		body["volume_type"] = opts.VolumeType
+	}
+	return body, nil
+}
+
+// IsNotFound returns whether the Gcore Cloud API responded with 404.
+func IsNotFound(err error) bool {
+	return errors.As(err, &gcorecloud.ErrDefault404{})
+}
+
+type FileShareClient struct {
+	// Client is used to read the Secret referenced by the provisioner API token.
+	Client client.Reader
+}
+
+func (c FileShareClient) getAPIToken(ctx context.Context, provisioner *crdv1.NfsProvisioner) (string, error) {
+	if provisioner.Spec.APITokenSecretRef == nil {
+		return provisioner.Spec.APIToken, nil
+	}
+	secretName := provisioner.APITokenSecretName()
+	secret := corev1.Secret{}
+	if err := c.Client.Get(ctx, secretName, &secret); err != nil {
+		return "", fmt.Errorf("get api token secret %s: %w", secretName, err)
+	}
+	token, found := secret.Data[provisioner.Spec.APITokenSecretRef.Key]
+	if !found || len(token) == 0 {
+		return "", fmt.Errorf("api token secret %s has no key %s", secretName, provisioner.Spec.APITokenSecretRef.Key)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
 
-func newApiTokenClient(provisioner *crdv1.NfsProvisioner, endpoint string, version string) (*gcorecloud.ServiceClient, error) {
+func (c FileShareClient) newApiTokenClient(ctx context.Context, provisioner *crdv1.NfsProvisioner, endpoint string, version string) (*gcorecloud.ServiceClient, error) {
+	apiToken, err := c.getAPIToken(ctx, provisioner)
+	if err != nil {
+		return nil, err
+	}
 	settings := gcorecloud.APITokenAPISettings{
 		APIURL:   provisioner.Spec.APIURL,
-		APIToken: provisioner.Spec.APIToken,
+		APIToken: apiToken,
 		Type:     "",
 		Name:     endpoint,
 		Region:   provisioner.Spec.RegionID,
@@ -31,8 +98,8 @@ func newApiTokenClient(provisioner *crdv1.NfsProvisioner, endpoint string, versi
 	return cloudclient.APITokenClientServiceWithDebug(settings.ToAPITokenOptions(), settings.ToEndpointOptions(), settings.Debug)
 }
 
-func (c FileShareClient) ListFileShares(provisioner *crdv1.NfsProvisioner) ([]file_shares.FileShare, error) {
-	fileShareClient, err := newApiTokenClient(provisioner, "file_shares", "v1")
+func (c FileShareClient) ListFileShares(ctx context.Context, provisioner *crdv1.NfsProvisioner) ([]file_shares.FileShare, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
 	if err != nil {
 		return []file_shares.FileShare{}, err
 	}
@@ -49,10 +116,270 @@ func (c FileShareClient) ListFileShares(provisioner *crdv1.NfsProvisioner) ([]fi
 	return nfsFileShares, nil
 }
 
-type MockFileShareClient struct {
-	FileShares []file_shares.FileShare
+func (c FileShareClient) GetFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) (*file_shares.FileShare, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return nil, err
+	}
+	return file_shares.Get(fileShareClient, id).Extract()
+}
+
+func (c FileShareClient) CreateFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, opts CreateFileShareOpts) (string, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return "", err
+	}
+	return extractTaskID(file_shares.Create(fileShareClient, opts))
+}
+
+func (c FileShareClient) ExtendFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, size int) (string, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return "", err
+	}
+	return extractTaskID(file_shares.Extend(fileShareClient, id, file_shares.ExtendOpts{Size: size}))
+}
+
+func (c FileShareClient) DeleteFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) (string, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return "", err
+	}
+	return extractTaskID(file_shares.Delete(fileShareClient, id))
+}
+
+func (c FileShareClient) GetTask(ctx context.Context, provisioner *crdv1.NfsProvisioner, taskID string) (*tasks.Task, error) {
+	taskClient, err := c.newApiTokenClient(ctx, provisioner, "tasks", "v1")
+	if err != nil {
+		return nil, err
+	}
+	return tasks.Get(taskClient, taskID).Extract()
+}
+
+func (c FileShareClient) ListAccessRules(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) ([]file_shares.AccessRule, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return nil, err
+	}
+	page, err := file_shares.ListAccessRules(fileShareClient, id).AllPages()
+	if err != nil {
+		return nil, err
+	}
+	return file_shares.ExtractAccessRule(page)
+}
+
+func (c FileShareClient) CreateAccessRule(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, opts file_shares.CreateAccessRuleOpts) (*file_shares.AccessRule, error) {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return nil, err
+	}
+	return file_shares.CreateAccessRule(fileShareClient, id, opts).Extract()
+}
+
+func (c FileShareClient) DeleteAccessRule(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, ruleID string) error {
+	fileShareClient, err := c.newApiTokenClient(ctx, provisioner, "file_shares", "v1")
+	if err != nil {
+		return err
+	}
+	return file_shares.DeleteAccessRule(fileShareClient, id, ruleID).ExtractErr()
+}
+
+func extractTaskID(result tasks.Result) (string, error) {
+	taskResults, err := result.Extract()
+	if err != nil {
+		return "", err
+	}
+	if len(taskResults.Tasks) == 0 {
+		return "", fmt.Errorf("no task in response")
+	}
+	return string(taskResults.Tasks[0]), nil
+}
+
+// MockFileShareManager keeps the file shares in memory, its tasks finish immediately
+// unless KeepTasksRunning is set.
+type MockFileShareManager struct {
+	mu               sync.Mutex
+	FileShares       []file_shares.FileShare
+	Tasks            map[string]*tasks.Task
+	KeepTasksRunning bool
+	// AccessRules are the access rules by file share ID.
+	AccessRules map[string][]file_shares.AccessRule
+	// CreateAccessRuleErr is returned by CreateAccessRule when set.
+	CreateAccessRuleErr error
+	// taskActions apply the changes of the tasks when they finish.
+	taskActions  map[string]func()
+	lastID       int
+	lastAccessID int
+}
+
+func (m *MockFileShareManager) ListFileShares(ctx context.Context, provisioner *crdv1.NfsProvisioner) ([]file_shares.FileShare, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]file_shares.FileShare{}, m.FileShares...), nil
+}
+
+func (m *MockFileShareManager) GetFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) (*file_shares.FileShare, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, fileShare := range m.FileShares {
+		if fileShare.ID == id {
+			return &fileShare, nil
+		}
+	}
+	return nil, gcorecloud.ErrDefault404{}
+}
+
+func (m *MockFileShareManager) CreateFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, opts CreateFileShareOpts) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	id := fmt.Sprintf("00000000-0000-4000-8000-%012d", m.lastID)
+	metadata := map[string]interface{}{}
+	for key, value := range opts.Metadata {
+		metadata[key] = value
+	}
+	m.FileShares = append(m.FileShares, file_shares.FileShare{
+		ID:         id,
+		Name:       opts.Name,
+		Protocol:   opts.Protocol,
+		Status:     "creating",
+		Size:       opts.Size,
+		VolumeType: opts.VolumeType,
+		Metadata:   metadata,
+	})
+	host := m.lastID%250 + 1
+	taskID := m.addTask(func() {
+		m.updateFileShare(id, func(fileShare *file_shares.FileShare) {
+			fileShare.Status = "available"
+			fileShare.ConnectionPoint = fmt.Sprintf("10.33.20.%d:/shares/share-%s", host, id)
+		})
+	})
+	m.Tasks[taskID].CreatedResources = &map[string]interface{}{"file_shares": []interface{}{id}}
+	return taskID, nil
+}
+
+func (m *MockFileShareManager) ExtendFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, size int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.updateFileShare(id, func(fileShare *file_shares.FileShare) { fileShare.Status = "extending" }) {
+		return "", gcorecloud.ErrDefault404{}
+	}
+	return m.addTask(func() {
+		m.updateFileShare(id, func(fileShare *file_shares.FileShare) {
+			fileShare.Status = "available"
+			fileShare.Size = size
+		})
+	}), nil
+}
+
+func (m *MockFileShareManager) DeleteFileShare(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.updateFileShare(id, func(fileShare *file_shares.FileShare) { fileShare.Status = "deleting" }) {
+		return "", gcorecloud.ErrDefault404{}
+	}
+	return m.addTask(func() {
+		for i, fileShare := range m.FileShares {
+			if fileShare.ID == id {
+				m.FileShares = append(m.FileShares[:i], m.FileShares[i+1:]...)
+				return
+			}
+		}
+	}), nil
+}
+
+func (m *MockFileShareManager) GetTask(ctx context.Context, provisioner *crdv1.NfsProvisioner, taskID string) (*tasks.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, found := m.Tasks[taskID]
+	if !found {
+		return nil, gcorecloud.ErrDefault404{}
+	}
+	taskCopy := *task
+	return &taskCopy, nil
 }
 
-func (m MockFileShareClient) ListFileShares(provisioner *crdv1.NfsProvisioner) ([]file_shares.FileShare, error) {
-	return m.FileShares, nil
+func (m *MockFileShareManager) ListAccessRules(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string) ([]file_shares.AccessRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.updateFileShare(id, func(fileShare *file_shares.FileShare) {}) {
+		return nil, gcorecloud.ErrDefault404{}
+	}
+	return append([]file_shares.AccessRule{}, m.AccessRules[id]...), nil
+}
+
+func (m *MockFileShareManager) CreateAccessRule(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, opts file_shares.CreateAccessRuleOpts) (*file_shares.AccessRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.updateFileShare(id, func(fileShare *file_shares.FileShare) {}) {
+		return nil, gcorecloud.ErrDefault404{}
+	}
+	if m.CreateAccessRuleErr != nil {
+		return nil, m.CreateAccessRuleErr
+	}
+	if m.AccessRules == nil {
+		m.AccessRules = map[string][]file_shares.AccessRule{}
+	}
+	m.lastAccessID++
+	rule := file_shares.AccessRule{
+		ID:          fmt.Sprintf("rule-%d", m.lastAccessID),
+		State:       "active",
+		AccessTo:    opts.IPAddress,
+		AccessLevel: opts.AccessMode,
+	}
+	m.AccessRules[id] = append(m.AccessRules[id], rule)
+	return &rule, nil
+}
+
+func (m *MockFileShareManager) DeleteAccessRule(ctx context.Context, provisioner *crdv1.NfsProvisioner, id string, ruleID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, rule := range m.AccessRules[id] {
+		if rule.ID == ruleID {
+			m.AccessRules[id] = append(m.AccessRules[id][:i], m.AccessRules[id][i+1:]...)
+			return nil
+		}
+	}
+	return gcorecloud.ErrDefault404{}
+}
+
+// FinishTasks finishes the running tasks and applies their changes.
+func (m *MockFileShareManager) FinishTasks() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for taskID, task := range m.Tasks {
+		if task.State == tasks.TaskStateRunning {
+			m.finishTask(taskID)
+		}
+	}
+}
+
+func (m *MockFileShareManager) addTask(action func()) string {
+	if m.Tasks == nil {
+		m.Tasks = map[string]*tasks.Task{}
+		m.taskActions = map[string]func(){}
+	}
+	taskID := fmt.Sprintf("task-%d", len(m.Tasks)+1)
+	m.Tasks[taskID] = &tasks.Task{ID: taskID, State: tasks.TaskStateRunning}
+	m.taskActions[taskID] = action
+	if !m.KeepTasksRunning {
+		m.finishTask(taskID)
+	}
+	return taskID
+}
+
+func (m *MockFileShareManager) finishTask(taskID string) {
+	m.Tasks[taskID].State = tasks.TaskStateFinished
+	m.taskActions[taskID]()
+	delete(m.taskActions, taskID)
+}
+
+func (m *MockFileShareManager) updateFileShare(id string, update func(fileShare *file_shares.FileShare)) bool {
+	for i := range m.FileShares {
+		if m.FileShares[i].ID == id {
+			update(&m.FileShares[i])
+			return true
+		}
+	}
+	return false
 }